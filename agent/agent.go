@@ -28,44 +28,207 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/joyent/freebsd-vpc/db"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	uuid "github.com/satori/go.uuid"
 )
 
 type Agent struct {
-	config Config
+	cnID    uuid.UUID
+	started time.Time
 
-	dbPool *db.Pool
+	// lock protects config and dbPool, which change when the configuration
+	// is reloaded.
+	lock     sync.Mutex
+	config   Config
+	dbPool   *db.Pool
+	stopping bool
 
 	rpcListener net.Listener
 	rpcServer   *http.Server
+
+	registrar  *registrar
+	reconciler *reconciler
+
+	metrics         *agentMetrics
+	metricsListener net.Listener
+	metricsServer   *http.Server
+
+	signalCh    chan os.Signal
+	terminateCh chan os.Signal
+	signalWG    sync.WaitGroup
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
 func New(config Config) (agent *Agent, err error) {
+	agentCfg := config.AgentConfig
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+
+	cnID, err := loadCNID(agentCfg.CNID, agentCfg.CNIDFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to determine CN ID")
+	}
+
+	reconciler := newReconciler(cnID, agentCfg.Reconcile.Interval, agentCfg.Reconcile.DryRun, nil)
+	if err := reconciler.loadOwned(agentCfg.Reconcile.OwnedFile); err != nil {
+		return nil, errors.Wrap(err, "unable to load owned VPC objects")
+	}
+
 	dbPool, err := db.New(config.DBConfig)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create database pool")
 	}
+	reconciler.load = dbLoader(dbPool, cnID)
 
-	rpcListener, err := net.Listen("unix", config.AgentConfig.Addresses.Internal)
+	rpcListener, err := listenInternal(agentCfg.Addresses.Internal, agentCfg.Addresses.InternalMode, agentCfg.Addresses.InternalGroup)
 	if err != nil {
+		dbPool.Close()
 		return nil, errors.Wrap(err, "error creating RPC listener")
 	}
 
-	rpcServer := &http.Server{
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(200)
-			w.Write([]byte("Hello World"))
-		}),
-	}
-
-	return &Agent{
+	a := &Agent{
+		cnID:        cnID,
+		config:      config,
 		dbPool:      dbPool,
 		rpcListener: rpcListener,
-		rpcServer:   rpcServer,
-	}, nil
+		terminateCh: make(chan os.Signal, 2),
+		registrar:   newRegistrar(dbPool, cnID, agentCfg.Facility, agentCfg.UnderlayInterfaces),
+		reconciler:  reconciler,
+	}
+
+	a.rpcServer = &http.Server{
+		Handler: newRPCHandler(a),
+	}
+
+	a.metrics = newAgentMetrics()
+	a.metrics.registry.OnCollect(a.collectMetrics)
+	a.reconciler.metrics = a.metrics
+
+	if addr := agentCfg.Addresses.Metrics; addr != "" {
+		a.metricsListener, err = net.Listen("tcp", addr)
+		if err != nil {
+			rpcListener.Close()
+			dbPool.Close()
+			return nil, errors.Wrap(err, "error creating metrics listener")
+		}
+
+		serveMux := http.NewServeMux()
+		serveMux.Handle("/metrics", a.metrics.registry)
+		a.metricsServer = &http.Server{
+			Handler: serveMux,
+		}
+	}
+
+	return a, nil
+}
+
+// listenInternal listens on the unix socket at path, replacing a stale socket
+// left behind by an agent that did not shut down cleanly, and restricts access
+// to the socket to mode and, if set, group.  Access to the socket grants full
+// control over the VPC objects of the host.
+func listenInternal(path, mode, group string) (net.Listener, error) {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || os.FileMode(perm)&^os.ModePerm != 0 {
+		return nil, errors.Errorf("invalid socket mode %q", mode)
+	}
+
+	gid := -1
+	if group != "" {
+		if gid, err = lookupGID(group); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to listen on %q", path)
+	}
+
+	if err := os.Chmod(path, os.FileMode(perm)); err != nil {
+		l.Close()
+		return nil, errors.Wrapf(err, "unable to change the mode of %q", path)
+	}
+
+	if err := os.Chown(path, -1, gid); err != nil {
+		l.Close()
+		return nil, errors.Wrapf(err, "unable to change the group of %q", path)
+	}
+
+	return l, nil
+}
+
+// removeStaleSocket removes the unix socket at path unless an agent is still
+// listening on it.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return errors.Wrapf(err, "unable to stat %q", path)
+	case fi.Mode()&os.ModeSocket == 0:
+		return errors.Errorf("%q exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return errors.Errorf("another agent is listening on %q", path)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return errors.Wrapf(err, "unable to remove stale socket %q", path)
+	}
+
+	return nil
+}
+
+// lookupGID returns the GID of group, which is either a group name or a
+// numeric GID.
+func lookupGID(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to look up group %q", group)
+	}
+
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to parse GID of group %q", group)
+	}
+
+	return gid, nil
+}
+
+// validateConfig checks the settings that can change while the agent runs.
+func validateConfig(config Config) error {
+	agentCfg := config.AgentConfig
+	if agentCfg.Reconcile.Interval <= 0 {
+		return errors.Errorf("invalid reconcile interval %s", agentCfg.Reconcile.Interval)
+	}
+
+	if agentCfg.ShutdownTimeout <= 0 {
+		return errors.Errorf("invalid shutdown timeout %s", agentCfg.ShutdownTimeout)
+	}
+
+	return nil
 }
 
 func (a *Agent) Start() error {
@@ -73,12 +236,98 @@ func (a *Agent) Start() error {
 		return errors.Wrap(err, "unable to ping database")
 	}
 
+	a.started = time.Now()
 	go a.rpcServer.Serve(a.rpcListener)
 
+	a.metrics.install()
+	if a.metricsServer != nil {
+		log.Info().Str("addr", a.metricsListener.Addr().String()).Msg("serving metrics")
+		go a.metricsServer.Serve(a.metricsListener)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+
+	if a.config.AgentConfig.Facility == "" {
+		log.Warn().Msg("no facility configured, CN will not be registered")
+	} else {
+		if err := a.registrar.Register(ctx); err != nil {
+			log.Error().Err(err).Msg("unable to register CN, will retry")
+		}
+
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.registrar.Run(ctx, a.reconciler.Notify)
+		}()
+	}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.reconciler.Run(ctx)
+	}()
+
+	if err := a.startSignalHandler(); err != nil {
+		return errors.Wrap(err, "unable to start signal handler")
+	}
+
 	return nil
 }
 
+// Wait blocks until the agent receives SIGINT or SIGTERM and then shuts it
+// down.  An error is returned if the shutdown does not complete within the
+// shutdown timeout or a second signal is received in the meantime.
+func (a *Agent) Wait() error {
+	sig := <-a.terminateCh
+	log.Info().Str("signal", sig.String()).Msg("caught signal, initiating graceful shutdown of agent")
+
+	a.lock.Lock()
+	timeout := a.config.AgentConfig.ShutdownTimeout
+	a.lock.Unlock()
+
+	shutdownCh := make(chan error, 1)
+	go func() {
+		shutdownCh <- a.Shutdown()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case sig := <-a.terminateCh:
+		return errors.Errorf("caught second signal %s during graceful shutdown", sig)
+	case <-timer.C:
+		return errors.Errorf("graceful shutdown did not complete within %s", timeout)
+	case err := <-shutdownCh:
+		return err
+	}
+}
+
+// collectMetrics samples the gauges of the database pool and the VPC object
+// counts before the metrics are written.
+func (a *Agent) collectMetrics() {
+	a.lock.Lock()
+	stat := a.dbPool.Pool().Stat()
+	a.lock.Unlock()
+
+	a.metrics.dbMaxConns.Set(float64(stat.MaxConnections))
+	a.metrics.dbCurrentConns.Set(float64(stat.CurrentConnections))
+	a.metrics.dbAvailableConns.Set(float64(stat.AvailableConnections))
+
+	a.metrics.collectObjects()
+}
+
 func (a *Agent) Shutdown() error {
+	a.lock.Lock()
+	a.stopping = true
+	a.lock.Unlock()
+
+	if a.cancel != nil {
+		a.cancel()
+		a.wg.Wait()
+	}
+
 	if err := a.rpcListener.Close(); err != nil {
 		log.Warn().Err(err).Msg("error during RPC listener shutdown")
 	}
@@ -87,9 +336,26 @@ func (a *Agent) Shutdown() error {
 		log.Warn().Err(err).Msg("error during RPC server shutdown")
 	}
 
+	if a.metricsServer != nil {
+		if err := a.metricsListener.Close(); err != nil {
+			log.Warn().Err(err).Msg("error during metrics listener shutdown")
+		}
+
+		if err := a.metricsServer.Shutdown(context.Background()); err != nil {
+			log.Warn().Err(err).Msg("error during metrics server shutdown")
+		}
+	}
+	a.metrics.uninstall()
+
+	a.lock.Lock()
 	if err := a.dbPool.Close(); err != nil {
 		log.Warn().Err(err).Msg("error closing database pool")
 	}
+	a.lock.Unlock()
+
+	if err := a.stopSignalHandler(); err != nil {
+		log.Warn().Err(err).Msg("error stopping signal handler")
+	}
 
 	return nil
 }
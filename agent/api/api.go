@@ -0,0 +1,245 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Package api contains the versioned HTTP/JSON API served by the vpc agent on
+// its internal unix socket.  Every operation is a POST of a JSON encoded
+// request to one of the Path constants and returns a JSON encoded response.
+// Failed operations return a non-2xx status and an ErrorResponse: 400 for a
+// malformed request, 404 when a VPC object does not exist, 409 when a VPC
+// object already exists or is in use, and 500 otherwise.  The unversioned
+// health endpoints PathHealthz and PathReadyz are probed with a GET instead.
+package api
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Version is the version of the API served by the agent.
+const Version = "v1"
+
+const (
+	PathPing           = "/" + Version + "/ping"
+	PathList           = "/" + Version + "/list"
+	PathSwitchCreate   = "/" + Version + "/switch/create"
+	PathSwitchOpen     = "/" + Version + "/switch/open"
+	PathSwitchDestroy  = "/" + Version + "/switch/destroy"
+	PathPortAdd        = "/" + Version + "/switch/port/add"
+	PathPortRemove     = "/" + Version + "/switch/port/remove"
+	PathPortConnect    = "/" + Version + "/switch/port/connect"
+	PathPortDisconnect = "/" + Version + "/switch/port/disconnect"
+	PathVMNICCreate    = "/" + Version + "/vmnic/create"
+	PathVMNICSet       = "/" + Version + "/vmnic/set"
+	PathVMNICDestroy   = "/" + Version + "/vmnic/destroy"
+	PathMuxListen      = "/" + Version + "/mux/listen"
+	PathMuxConnect     = "/" + Version + "/mux/connect"
+	PathReconcile      = "/" + Version + "/reconcile"
+	PathStatus         = "/" + Version + "/status"
+)
+
+const (
+	// PathHealthz reports whether the agent process is alive.
+	PathHealthz = "/healthz"
+
+	// PathReadyz reports whether the agent is able to do its job: the
+	// database is reachable, the kernel supports VPC, and the VPC objects
+	// have been reconciled.
+	PathReadyz = "/readyz"
+)
+
+// ErrorResponse is returned with a non-2xx status when an operation fails.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// EmptyResponse is returned by operations that have no output.
+type EmptyResponse struct{}
+
+// PingResponse describes the agent answering the request.
+type PingResponse struct {
+	Version string `json:"version"`
+}
+
+// ListRequest selects the VPC objects returned by PathList.  An empty Type
+// returns objects of every type.
+type ListRequest struct {
+	Type string `json:"type,omitempty"`
+}
+
+// Object describes a VPC object.
+type Object struct {
+	Type     string `json:"type"`
+	ID       string `json:"id"`
+	UnitName string `json:"unit-name,omitempty"`
+}
+
+// ListResponse is the list of VPC objects returned by PathList.
+type ListResponse struct {
+	Objects []Object `json:"objects"`
+}
+
+// ObjectRequest identifies the VPC object of an operation.
+type ObjectRequest struct {
+	ID string `json:"id"`
+}
+
+// SwitchCreateRequest is the input to PathSwitchCreate.
+type SwitchCreateRequest struct {
+	ID  string `json:"id"`
+	MAC string `json:"mac,omitempty"`
+	VNI uint32 `json:"vni"`
+}
+
+// PortRequest is the input to PathPortAdd and PathPortRemove.
+type PortRequest struct {
+	SwitchID string `json:"switch-id"`
+	PortID   string `json:"port-id"`
+	MAC      string `json:"mac,omitempty"`
+}
+
+// ConnectRequest is the input to PathPortConnect, PathPortDisconnect, and
+// PathMuxConnect.  ID is the VPC Switch Port or VPC Mux.
+type ConnectRequest struct {
+	ID          string `json:"id"`
+	InterfaceID string `json:"interface-id"`
+}
+
+// VMNICCreateRequest is the input to PathVMNICCreate.
+type VMNICCreateRequest struct {
+	ID  string `json:"id"`
+	MAC string `json:"mac,omitempty"`
+}
+
+// VMNICSetRequest is the input to PathVMNICSet.  A NumQueues of zero leaves
+// the number of hardware queues unchanged.
+type VMNICSetRequest struct {
+	ID        string `json:"id"`
+	Freeze    bool   `json:"freeze,omitempty"`
+	NumQueues uint16 `json:"num-queues,omitempty"`
+	Unfreeze  bool   `json:"unfreeze,omitempty"`
+}
+
+// VMNICDestroyResponse is returned by PathVMNICDestroy.  MAC is the MAC
+// address the VM NIC had, so that callers can release it.
+type VMNICDestroyResponse struct {
+	MAC string `json:"mac"`
+}
+
+// MuxListenRequest is the input to PathMuxListen.
+type MuxListenRequest struct {
+	ID   string `json:"id"`
+	Addr string `json:"addr"`
+}
+
+// States of an object reported in ReconcileStatus.
+const (
+	// ObjectInSync objects match the desired state.
+	ObjectInSync = "in-sync"
+
+	// ObjectDrift objects do not match the desired state and were left
+	// untouched because the agent is running in dry-run mode.
+	ObjectDrift = "drift"
+
+	// ObjectConverged objects were changed by the last reconciliation.
+	ObjectConverged = "converged"
+
+	// ObjectPending objects were not changed because the last reconciliation
+	// failed.
+	ObjectPending = "pending"
+
+	// ObjectError objects could not be changed by the last reconciliation.
+	ObjectError = "error"
+)
+
+// ReconcileRequest is the input to PathReconcile.  When Trigger is set a
+// reconciliation is started without waiting for the next interval.
+type ReconcileRequest struct {
+	Trigger bool `json:"trigger,omitempty"`
+}
+
+// ObjectStatus is the outcome of the last reconciliation of a VPC object.
+// Actions lists the changes that were required to converge the object.
+type ObjectStatus struct {
+	ID      string   `json:"id"`
+	Type    string   `json:"type"`
+	State   string   `json:"state"`
+	Actions []string `json:"actions,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// ReconcileStatus is returned by PathReconcile and describes the last
+// reconciliation of the VPC objects of this CN.
+type ReconcileStatus struct {
+	CNID      string         `json:"cn-id"`
+	DryRun    bool           `json:"dry-run"`
+	Runs      uint64         `json:"runs"`
+	LastRun   time.Time      `json:"last-run"`
+	LastError string         `json:"last-error,omitempty"`
+	Objects   []ObjectStatus `json:"objects"`
+}
+
+// RegistrationStatus describes the CN row and underlay IPs the agent
+// registered in the database.
+type RegistrationStatus struct {
+	CNID           string    `json:"cn-id"`
+	Facility       string    `json:"facility,omitempty"`
+	FacilityID     string    `json:"facility-id,omitempty"`
+	Interfaces     []string  `json:"underlay-interfaces,omitempty"`
+	UnderlayIPs    []string  `json:"underlay-ips"`
+	Registered     bool      `json:"registered"`
+	LastRegistered time.Time `json:"last-registered"`
+	LastError      string    `json:"last-error,omitempty"`
+}
+
+// StatusResponse is returned by PathStatus.
+type StatusResponse struct {
+	Version      string             `json:"version"`
+	Registration RegistrationStatus `json:"registration"`
+	Reconcile    ReconcileStatus    `json:"reconcile"`
+}
+
+// Health states of a HealthCheck and a HealthResponse.
+const (
+	HealthOK   = "ok"
+	HealthFail = "fail"
+)
+
+// HealthCheck is the outcome of a single check of a health endpoint.
+type HealthCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// ErrUnhealthy is returned by clients when a health check of the agent failed.
+var ErrUnhealthy = errors.New("agent is unhealthy")
+
+// HealthResponse is returned by PathHealthz and PathReadyz.  Status is
+// HealthOK and the HTTP status 200 when every check passed, otherwise Status is
+// HealthFail and the HTTP status 503.
+type HealthResponse struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+}
@@ -0,0 +1,247 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeout is the default timeout of a request made by a Client.
+const DefaultTimeout = 30 * time.Second
+
+// Client calls the API of a vpc agent listening on a unix socket.
+type Client struct {
+	socketPath string
+	http       *http.Client
+}
+
+// NewClient creates a Client for the agent listening on socketPath.
+func NewClient(socketPath string) *Client {
+	return &Client{
+		socketPath: socketPath,
+		http: &http.Client{
+			Timeout: DefaultTimeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// Ping verifies the agent is reachable and returns the API version it serves.
+func (c *Client) Ping() (PingResponse, error) {
+	var resp PingResponse
+	if err := c.call(PathPing, struct{}{}, &resp); err != nil {
+		return PingResponse{}, err
+	}
+
+	return resp, nil
+}
+
+// List returns the VPC objects of the given type.  An empty objType returns
+// objects of every type.
+func (c *Client) List(objType string) ([]Object, error) {
+	var resp ListResponse
+	if err := c.call(PathList, ListRequest{Type: objType}, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Objects, nil
+}
+
+// SwitchCreate creates and commits a VPC Switch.
+func (c *Client) SwitchCreate(req SwitchCreateRequest) error {
+	return c.call(PathSwitchCreate, req, &EmptyResponse{})
+}
+
+// SwitchOpen verifies the VPC Switch exists.
+func (c *Client) SwitchOpen(id string) (Object, error) {
+	var resp Object
+	if err := c.call(PathSwitchOpen, ObjectRequest{ID: id}, &resp); err != nil {
+		return Object{}, err
+	}
+
+	return resp, nil
+}
+
+// SwitchDestroy destroys a VPC Switch.
+func (c *Client) SwitchDestroy(id string) error {
+	return c.call(PathSwitchDestroy, ObjectRequest{ID: id}, &EmptyResponse{})
+}
+
+// PortAdd adds a VPC Switch Port to a VPC Switch.
+func (c *Client) PortAdd(req PortRequest) error {
+	return c.call(PathPortAdd, req, &EmptyResponse{})
+}
+
+// PortRemove removes a VPC Switch Port from a VPC Switch.
+func (c *Client) PortRemove(req PortRequest) error {
+	return c.call(PathPortRemove, req, &EmptyResponse{})
+}
+
+// PortConnect connects a VPC Interface to a VPC Switch Port.
+func (c *Client) PortConnect(req ConnectRequest) error {
+	return c.call(PathPortConnect, req, &EmptyResponse{})
+}
+
+// PortDisconnect disconnects a VPC Interface from a VPC Switch Port.
+func (c *Client) PortDisconnect(req ConnectRequest) error {
+	return c.call(PathPortDisconnect, req, &EmptyResponse{})
+}
+
+// VMNICCreate creates and commits a VM NIC.
+func (c *Client) VMNICCreate(req VMNICCreateRequest) error {
+	return c.call(PathVMNICCreate, req, &EmptyResponse{})
+}
+
+// VMNICSet changes the attributes of a VM NIC.
+func (c *Client) VMNICSet(req VMNICSetRequest) error {
+	return c.call(PathVMNICSet, req, &EmptyResponse{})
+}
+
+// VMNICDestroy destroys a VM NIC and returns the MAC address it had.
+func (c *Client) VMNICDestroy(id string) (VMNICDestroyResponse, error) {
+	var resp VMNICDestroyResponse
+	if err := c.call(PathVMNICDestroy, ObjectRequest{ID: id}, &resp); err != nil {
+		return VMNICDestroyResponse{}, err
+	}
+
+	return resp, nil
+}
+
+// MuxListen sets the underlay address of a VPC Mux.
+func (c *Client) MuxListen(req MuxListenRequest) error {
+	return c.call(PathMuxListen, req, &EmptyResponse{})
+}
+
+// MuxConnect connects a VPC Interface to a VPC Mux.
+func (c *Client) MuxConnect(req ConnectRequest) error {
+	return c.call(PathMuxConnect, req, &EmptyResponse{})
+}
+
+// Reconcile returns the status of the last reconciliation.  If trigger is
+// true, a new reconciliation is started.
+func (c *Client) Reconcile(trigger bool) (ReconcileStatus, error) {
+	var resp ReconcileStatus
+	if err := c.call(PathReconcile, ReconcileRequest{Trigger: trigger}, &resp); err != nil {
+		return ReconcileStatus{}, err
+	}
+
+	return resp, nil
+}
+
+// Status returns the registration and reconciliation status of the agent.
+func (c *Client) Status() (StatusResponse, error) {
+	var resp StatusResponse
+	if err := c.call(PathStatus, struct{}{}, &resp); err != nil {
+		return StatusResponse{}, err
+	}
+
+	return resp, nil
+}
+
+// Health returns the outcome of the liveness checks of the agent, or of the
+// readiness checks if ready is true.  Failed checks are reported in the
+// response rather than as an error.
+func (c *Client) Health(ready bool) (HealthResponse, error) {
+	path := PathHealthz
+	if ready {
+		path = PathReadyz
+	}
+
+	httpResp, err := c.http.Get("http://unix" + path)
+	if err != nil {
+		return HealthResponse{}, errors.Wrapf(err, "unable to call agent at %q", c.socketPath)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK && httpResp.StatusCode != http.StatusServiceUnavailable {
+		return HealthResponse{}, errors.Errorf("agent returned %s for %s", httpResp.Status, path)
+	}
+
+	var resp HealthResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return HealthResponse{}, errors.Wrapf(err, "unable to decode response for %s", path)
+	}
+
+	return resp, nil
+}
+
+// call POSTs req to path and decodes the response into resp.
+func (c *Client) call(path string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return errors.Wrapf(err, "unable to encode request for %s", path)
+	}
+
+	httpResp, err := c.http.Post("http://unix"+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "unable to call agent at %q", c.socketPath)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		return responseError(httpResp, path)
+	}
+
+	if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+		return errors.Wrapf(err, "unable to decode response for %s", path)
+	}
+
+	return nil
+}
+
+// responseError returns the error of a failed call to path.  404 and 409
+// replies wrap ENOENT and EEXIST so that vpc.IsNotExist and vpc.IsExist hold
+// for the error as if the operation had been run locally.
+func responseError(httpResp *http.Response, path string) error {
+	msg := fmt.Sprintf("agent returned %s for %s", httpResp.Status, path)
+
+	var errResp ErrorResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&errResp); err == nil && errResp.Error != "" {
+		msg += ": " + errResp.Error
+	}
+
+	switch httpResp.StatusCode {
+	case http.StatusNotFound:
+		return errors.Wrap(syscall.ENOENT, msg)
+	case http.StatusConflict:
+		return errors.Wrap(syscall.EEXIST, msg)
+	default:
+		return errors.New(msg)
+	}
+}
@@ -24,13 +24,61 @@
 
 package agent
 
-import "github.com/joyent/freebsd-vpc/db"
+import (
+	"time"
+
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
 
 type Config struct {
-	DBConfig db.Config `mapstructure:"db"`
+	DBConfig    db.Config `mapstructure:"db"`
 	AgentConfig struct {
+		// CNID is the ID of the compute node in the database.  When it is
+		// not set, the ID persisted in CNIDFile is used, and a new ID is
+		// generated and persisted the first time the agent runs.
+		CNID     string `mapstructure:"cn-id"`
+		CNIDFile string `mapstructure:"cn-id-file"`
+
+		// Facility is the name of the facility the CN is registered in, and
+		// UnderlayInterfaces are the interfaces whose addresses are
+		// registered as the underlay IPs of the CN.
+		Facility           string   `mapstructure:"facility"`
+		UnderlayInterfaces []string `mapstructure:"underlay-interfaces"`
+
+		// Addresses.Internal is the path of the unix socket the RPC API is
+		// served on.  The socket is created with the octal InternalMode and,
+		// when InternalGroup is set, owned by that group (a name or a GID).
+		// Addresses.Metrics is the TCP address the Prometheus metrics are
+		// served on.  Metrics are not served when it is empty.
 		Addresses struct {
-			Internal string `mapstructure:"internal"`
+			Internal      string `mapstructure:"internal"`
+			InternalGroup string `mapstructure:"internal-group"`
+			InternalMode  string `mapstructure:"internal-mode"`
+			Metrics       string `mapstructure:"metrics"`
 		} `mapstructure:"addresses"`
+
+		// Reconcile.OwnedFile is the file the IDs of the VPC objects owned
+		// by the reconciler are persisted in.
+		Reconcile struct {
+			Interval  time.Duration `mapstructure:"interval"`
+			DryRun    bool          `mapstructure:"dry-run"`
+			OwnedFile string        `mapstructure:"owned-file"`
+		} `mapstructure:"reconcile"`
+
+		// ShutdownTimeout bounds the time a graceful shutdown may take
+		// before the agent gives up.
+		ShutdownTimeout time.Duration `mapstructure:"shutdown-timeout"`
 	} `mapstructure:"agent"`
 }
+
+// ViperConfig returns the agent Config stored in Viper.
+func ViperConfig() (Config, error) {
+	var config Config
+	if err := viper.Unmarshal(&config); err != nil {
+		return Config{}, errors.Wrap(err, "unable to decode config into struct")
+	}
+
+	return config, nil
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mgmt"
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// healthCheck is a named check of a health endpoint.  check returns a message
+// describing a passed check or an error describing a failed one.
+type healthCheck struct {
+	name  string
+	check func() (string, error)
+}
+
+// healthHandler serves the outcome of checks.  The checks run in order on
+// every request.
+func healthHandler(path string, checks []healthCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodHead)
+			writeRPCError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		resp := runHealthChecks(checks)
+
+		status := http.StatusOK
+		if resp.Status != api.HealthOK {
+			status = http.StatusServiceUnavailable
+			log.Debug().Str("path", path).Interface("checks", resp.Checks).Msg("health check failed")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("unable to write health response")
+		}
+	})
+}
+
+func runHealthChecks(checks []healthCheck) api.HealthResponse {
+	resp := api.HealthResponse{
+		Status: api.HealthOK,
+		Checks: make([]api.HealthCheck, 0, len(checks)),
+	}
+
+	for _, hc := range checks {
+		result := api.HealthCheck{
+			Name:   hc.name,
+			Status: api.HealthOK,
+		}
+
+		msg, err := hc.check()
+		if err != nil {
+			result.Status = api.HealthFail
+			result.Message = err.Error()
+			resp.Status = api.HealthFail
+		} else {
+			result.Message = msg
+		}
+
+		resp.Checks = append(resp.Checks, result)
+	}
+
+	return resp
+}
+
+// livenessChecks are the checks of api.PathHealthz.  The agent is alive as
+// long as it is able to answer.
+func (a *Agent) livenessChecks() []healthCheck {
+	return []healthCheck{
+		{name: "process", check: a.checkProcess},
+	}
+}
+
+// readinessChecks are the checks of api.PathReadyz.
+func (a *Agent) readinessChecks() []healthCheck {
+	return []healthCheck{
+		{name: "database", check: a.checkDatabase},
+		{name: "vpc", check: checkVPC},
+		{name: "reconcile", check: a.checkReconcile},
+	}
+}
+
+func (a *Agent) checkProcess() (string, error) {
+	msg := fmt.Sprintf("pid %d", os.Getpid())
+	if !a.started.IsZero() {
+		msg += fmt.Sprintf(", up %s", time.Since(a.started).Round(time.Second))
+	}
+
+	return msg, nil
+}
+
+func (a *Agent) checkDatabase() (string, error) {
+	a.lock.Lock()
+	dbPool := a.dbPool
+	host := a.config.DBConfig.Host
+	a.lock.Unlock()
+
+	if err := dbPool.Ping(); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("reachable at %s", host), nil
+}
+
+// checkVPC verifies the kernel supports VPC by opening a VPC Management handle.
+func checkVPC() (string, error) {
+	mgr, err := mgmt.New(nil)
+	if err != nil {
+		return "", errors.Wrap(err, "kernel VPC support unavailable")
+	}
+	mgr.Close()
+
+	return "kernel VPC support present", nil
+}
+
+// checkReconcile verifies the last reconciliation succeeded.  Drift reported in
+// dry-run mode does not make the agent unready.
+func (a *Agent) checkReconcile() (string, error) {
+	status := a.reconciler.Status()
+	if status.Runs == 0 {
+		return "", errors.New("no reconciliation has completed yet")
+	}
+
+	if status.LastError != "" {
+		return "", errors.Errorf("last reconciliation failed: %s", status.LastError)
+	}
+
+	var failed, drifted int
+	for _, obj := range status.Objects {
+		switch obj.State {
+		case api.ObjectError, api.ObjectPending:
+			failed++
+		case api.ObjectDrift:
+			drifted++
+		}
+	}
+	if failed > 0 {
+		return "", errors.Errorf("%d of %d objects not converged", failed, len(status.Objects))
+	}
+
+	msg := fmt.Sprintf("%d objects reconciled at %s", len(status.Objects), status.LastRun.Format(time.RFC3339))
+	if drifted > 0 {
+		msg += fmt.Sprintf(", %d drifted (dry-run)", drifted)
+	}
+
+	return msg, nil
+}
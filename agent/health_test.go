@@ -0,0 +1,133 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package agent
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestHealthHandler_Client(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vpc-agent")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("unable to listen on %q: %v", socketPath, err)
+	}
+
+	dbErr := errors.New("connection refused")
+	serveMux := http.NewServeMux()
+	serveMux.Handle(api.PathHealthz, healthHandler(api.PathHealthz, []healthCheck{
+		{name: "process", check: func() (string, error) { return "alive", nil }},
+	}))
+	serveMux.Handle(api.PathReadyz, healthHandler(api.PathReadyz, []healthCheck{
+		{name: "vpc", check: func() (string, error) { return "present", nil }},
+		{name: "database", check: func() (string, error) { return "", dbErr }},
+	}))
+	server := &http.Server{Handler: serveMux}
+	go server.Serve(l)
+	defer server.Close()
+
+	client := api.NewClient(socketPath)
+
+	live, err := client.Health(false)
+	if err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	if live.Status != api.HealthOK || len(live.Checks) != 1 || live.Checks[0].Message != "alive" {
+		t.Errorf("unexpected liveness %+v", live)
+	}
+
+	ready, err := client.Health(true)
+	if err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	want := []api.HealthCheck{
+		{Name: "vpc", Status: api.HealthOK, Message: "present"},
+		{Name: "database", Status: api.HealthFail, Message: dbErr.Error()},
+	}
+	if ready.Status != api.HealthFail || len(ready.Checks) != len(want) {
+		t.Fatalf("unexpected readiness %+v", ready)
+	}
+	for i := range want {
+		if ready.Checks[i] != want[i] {
+			t.Errorf("check %d = %+v, want %+v", i, ready.Checks[i], want[i])
+		}
+	}
+}
+
+func TestAgent_CheckReconcile(t *testing.T) {
+	a := &Agent{
+		reconciler: newReconciler(uuid.Nil, time.Minute, true, nil),
+	}
+
+	if _, err := a.checkReconcile(); err == nil {
+		t.Errorf("ready before the first reconciliation")
+	}
+
+	a.reconciler.status.Runs = 1
+	a.reconciler.status.LastError = "unable to load desired state"
+	if _, err := a.checkReconcile(); err == nil {
+		t.Errorf("ready after a failed reconciliation")
+	}
+
+	a.reconciler.status.LastError = ""
+	a.reconciler.status.Objects = []api.ObjectStatus{
+		{State: api.ObjectInSync},
+		{State: api.ObjectDrift},
+	}
+	if _, err := a.checkReconcile(); err != nil {
+		t.Errorf("not ready after a dry-run reconciliation with drift: %v", err)
+	}
+
+	a.reconciler.status.Objects = append(a.reconciler.status.Objects, api.ObjectStatus{State: api.ObjectError})
+	if _, err := a.checkReconcile(); err == nil {
+		t.Errorf("ready with an object that failed to converge")
+	}
+}
+
+func TestCheckVPC_Simulator(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	if _, err := checkVPC(); err != nil {
+		t.Errorf("checkVPC: %v", err)
+	}
+}
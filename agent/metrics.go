@@ -0,0 +1,208 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package agent
+
+import (
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mgmt"
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/joyent/freebsd-vpc/internal/metrics"
+	"github.com/joyent/freebsd-vpc/internal/topology"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// agentMetrics holds the metrics exported by the agent.  agentMetrics is a
+// vpc.Tracer that records every call into the VPC subsystem and passes it on
+// to the Tracer that was installed before it.
+type agentMetrics struct {
+	registry *metrics.Registry
+
+	kbiCalls   *metrics.Counter
+	kbiLatency *metrics.Histogram
+	objects    *metrics.Gauge
+
+	dbMaxConns       *metrics.Gauge
+	dbCurrentConns   *metrics.Gauge
+	dbAvailableConns *metrics.Gauge
+
+	reconcileDuration *metrics.Histogram
+	reconcileDrift    *metrics.Counter
+	reconcileObjects  *metrics.Gauge
+
+	lock sync.RWMutex
+	next vpc.Tracer
+}
+
+func newAgentMetrics() *agentMetrics {
+	r := metrics.NewRegistry()
+
+	return &agentMetrics{
+		registry: r,
+
+		kbiCalls: r.NewCounter("vpc_kbi_calls_total",
+			"Calls into the VPC subsystem by call, object type, operation, and result.",
+			"call", "obj_type", "op", "result", "errno"),
+		kbiLatency: r.NewHistogram("vpc_kbi_call_duration_seconds",
+			"Latency of calls into the VPC subsystem by call, object type, and operation.",
+			metrics.DefBuckets, "call", "obj_type", "op"),
+		objects: r.NewGauge("vpc_objects",
+			"VPC objects on this compute node by object type.",
+			"obj_type"),
+
+		dbMaxConns: r.NewGauge("vpc_db_pool_max_connections",
+			"Maximum number of connections of the database pool."),
+		dbCurrentConns: r.NewGauge("vpc_db_pool_current_connections",
+			"Live connections of the database pool."),
+		dbAvailableConns: r.NewGauge("vpc_db_pool_available_connections",
+			"Unused live connections of the database pool."),
+
+		reconcileDuration: r.NewHistogram("vpc_reconcile_duration_seconds",
+			"Duration of reconciliations by result.",
+			metrics.DefBuckets, "result"),
+		reconcileDrift: r.NewCounter("vpc_reconcile_drift_total",
+			"Changes required to converge the VPC objects on the desired state by action.",
+			"action"),
+		reconcileObjects: r.NewGauge("vpc_reconcile_objects",
+			"Declared or changed VPC objects by state after the last reconciliation.",
+			"state"),
+	}
+}
+
+// install makes m the vpc.Tracer and chains the previously installed Tracer
+// behind it.
+func (m *agentMetrics) install() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.next = vpc.SetTracer(m)
+}
+
+// uninstall restores the vpc.Tracer that was installed before m.
+func (m *agentMetrics) uninstall() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	vpc.SetTracer(m.next)
+	m.next = nil
+}
+
+// Trace satisfies the vpc.Tracer interface.
+func (m *agentMetrics) Trace(t *vpc.Trace) {
+	objType := t.ID.ObjType
+	op := string(t.Call)
+	if t.Call == vpc.TraceCtl {
+		objType = t.Cmd.ObjType()
+		op = t.Cmd.Op().String()
+	}
+
+	result, errno := "ok", ""
+	if t.Err != nil {
+		result = "error"
+		if n, ok := errors.Cause(t.Err).(syscall.Errno); ok {
+			errno = strconv.Itoa(int(n))
+		}
+	}
+
+	typeName := objTypeLabel(objType)
+	m.kbiCalls.Inc(string(t.Call), typeName, op, result, errno)
+	m.kbiLatency.Observe(t.Latency.Seconds(), string(t.Call), typeName, op)
+
+	m.lock.RLock()
+	next := m.next
+	m.lock.RUnlock()
+
+	if next != nil {
+		next.Trace(t)
+	}
+}
+
+// objTypeLabel returns the name of objType, which unlike ObjType.String does
+// not panic on object types unknown to the VPC library.
+func objTypeLabel(objType vpc.ObjType) string {
+	if objType > vpc.ObjTypeHostif {
+		return "0x" + strconv.FormatUint(uint64(objType), 16)
+	}
+
+	return objType.String()
+}
+
+// collectObjects counts the VPC objects of every type.  The gauge is left
+// empty when the VPC subsystem is unavailable.
+func (m *agentMetrics) collectObjects() {
+	m.objects.Reset()
+
+	mgr, err := mgmt.New(nil)
+	if err != nil {
+		log.Debug().Err(err).Msg("unable to open VPC management handle for metrics")
+		return
+	}
+	defer mgr.Close()
+
+	for _, objType := range vpc.ObjTypes() {
+		count, err := mgr.CountType(objType)
+		if err != nil {
+			log.Debug().Err(err).Str("obj-type", objType.String()).Msg("unable to count VPC objects for metrics")
+			continue
+		}
+
+		m.objects.Set(float64(count), objType.String())
+	}
+}
+
+// observeReconcile records the duration and outcome of a reconciliation.
+// objects is nil if the reconciliation failed before the Plan was computed.
+func (m *agentMetrics) observeReconcile(d time.Duration, objects []api.ObjectStatus, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.reconcileDuration.Observe(d.Seconds(), result)
+
+	if objects == nil {
+		return
+	}
+
+	m.reconcileObjects.Reset()
+	states := make(map[string]int)
+	for _, obj := range objects {
+		states[obj.State]++
+	}
+	for state, count := range states {
+		m.reconcileObjects.Set(float64(count), state)
+	}
+}
+
+// observeDrift counts the Steps required to converge the VPC objects.
+func (m *agentMetrics) observeDrift(steps []topology.Step) {
+	for _, step := range steps {
+		m.reconcileDrift.Inc(step.Action.String())
+	}
+}
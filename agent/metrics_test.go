@@ -0,0 +1,93 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package agent
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/joyent/freebsd-vpc/internal/topology"
+)
+
+type countingTracer struct {
+	calls int
+}
+
+func (ct *countingTracer) Trace(t *vpc.Trace) {
+	ct.calls++
+}
+
+func TestAgentMetrics_Simulator(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	next := &countingTracer{}
+	prevTracer := vpc.SetTracer(next)
+	defer vpc.SetTracer(prevTracer)
+
+	m := newAgentMetrics()
+	m.install()
+
+	m.collectObjects()
+	m.observeDrift([]topology.Step{{Action: topology.ActionVLANSet}, {Action: topology.ActionVLANSet}})
+	m.observeReconcile(2*time.Millisecond, []api.ObjectStatus{
+		{State: api.ObjectInSync},
+		{State: api.ObjectInSync},
+		{State: api.ObjectDrift},
+	}, nil)
+
+	m.uninstall()
+	if restored := vpc.SetTracer(next); restored != next {
+		t.Errorf("uninstall did not restore the previous tracer")
+	}
+	if next.calls == 0 {
+		t.Errorf("calls were not passed on to the previous tracer")
+	}
+
+	var buf bytes.Buffer
+	if err := m.registry.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	text := buf.String()
+
+	for _, want := range []string{
+		`vpc_kbi_calls_total{call="open",obj_type="mgmt",op="open",result="ok",errno=""} 1`,
+		`vpc_kbi_calls_total{call="close",obj_type="mgmt",op="close",result="ok",errno=""} 1`,
+		`vpc_kbi_call_duration_seconds_count{call="open",obj_type="mgmt",op="open"} 1`,
+		`vpc_objects{obj_type="vpcsw"} 0`,
+		`vpc_reconcile_drift_total{action="vlan-set"} 2`,
+		`vpc_reconcile_duration_seconds_count{result="ok"} 1`,
+		`vpc_reconcile_objects{state="drift"} 1`,
+		`vpc_reconcile_objects{state="in-sync"} 2`,
+	} {
+		if !strings.Contains(text, want+"\n") {
+			t.Errorf("metrics do not contain %q:\n%s", want, text)
+		}
+	}
+}
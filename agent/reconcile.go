@@ -0,0 +1,586 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package agent
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mux"
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/joyent/freebsd-vpc/internal/topology"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// _ReconcileBackoffMin and _ReconcileBackoffMax bound the delay before a
+	// failed reconciliation is retried.
+	_ReconcileBackoffMin = time.Second
+	_ReconcileBackoffMax = 5 * time.Minute
+)
+
+// loadFunc returns the desired topology of the VPC objects on this CN.
+type loadFunc func(ctx context.Context) (*topology.Document, error)
+
+// reconciler converges the VPC objects on this CN on the desired state stored
+// in the database.  A reconciliation runs every interval, when notified, and
+// is retried with exponential backoff when it fails.  In dry-run mode drift
+// is logged and reported but not corrected.
+//
+// Only the VPC objects owned by the reconciler are destroyed once they are no
+// longer declared: the switch, mux, and uplink port of the CN and every object
+// the reconciler has declared.  Objects created by other means, e.g. hostifs,
+// ethlinks, or objects created with vpc apply, are left alone.  The owned
+// objects are persisted in ownedFile so that the objects of a VNIC deleted
+// while the agent was not running are destroyed once it starts.
+type reconciler struct {
+	cnID     uuid.UUID
+	notifyCh chan struct{}
+
+	// metrics records the outcome of every reconciliation unless it is nil.
+	metrics *agentMetrics
+
+	// runLock is held for the duration of a reconciliation.
+	runLock sync.Mutex
+
+	lock     sync.Mutex
+	interval time.Duration
+	dryRun   bool
+	load     loadFunc
+	status   api.ReconcileStatus
+	owned    map[vpc.ID]bool
+
+	// ownedFile is the file owned is persisted in.  owned is not persisted
+	// when it is empty.
+	ownedFile string
+}
+
+func newReconciler(cnID uuid.UUID, interval time.Duration, dryRun bool, load loadFunc) *reconciler {
+	return &reconciler{
+		cnID:     cnID,
+		interval: interval,
+		dryRun:   dryRun,
+		load:     load,
+		notifyCh: make(chan struct{}, 1),
+		status: api.ReconcileStatus{
+			CNID:    cnID.String(),
+			DryRun:  dryRun,
+			Objects: []api.ObjectStatus{},
+		},
+		owned: map[vpc.ID]bool{
+			objID(cnID, vpc.ObjTypeSwitch):     true,
+			objID(cnID, vpc.ObjTypeSwitchPort): true,
+			objID(cnID, vpc.ObjTypeMux):        true,
+		},
+	}
+}
+
+// Notify starts a reconciliation without waiting for the next interval.
+func (r *reconciler) Notify() {
+	select {
+	case r.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// Configure changes the interval and the dry-run mode of the reconciler, and
+// the loader of the desired state unless load is nil.  A reconciliation with
+// the new settings starts without waiting for the current interval to end.
+func (r *reconciler) Configure(interval time.Duration, dryRun bool, load loadFunc) {
+	r.lock.Lock()
+	r.interval = interval
+	r.dryRun = dryRun
+	r.status.DryRun = dryRun
+	if load != nil {
+		r.load = load
+	}
+	r.lock.Unlock()
+
+	r.Notify()
+}
+
+func (r *reconciler) settings() (interval time.Duration, dryRun bool, load loadFunc) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.interval, r.dryRun, r.load
+}
+
+// loadOwned adds the objects persisted in path to the objects owned by the
+// reconciler and persists the owned objects in path from now on.  A missing
+// file owns nothing.
+func (r *reconciler) loadOwned(path string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.ownedFile = path
+
+	b, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return errors.Wrapf(err, "unable to read owned VPC objects from %q", path)
+	}
+
+	for _, line := range strings.Fields(string(b)) {
+		id, err := vpc.ParseID(line)
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse owned VPC object %q in %q", line, path)
+		}
+		r.owned[id] = true
+	}
+
+	return nil
+}
+
+// saveOwned persists the owned objects in ownedFile.  r.lock must be held.
+func (r *reconciler) saveOwned() error {
+	if r.ownedFile == "" {
+		return nil
+	}
+
+	ids := make([]string, 0, len(r.owned))
+	for id := range r.owned {
+		ids = append(ids, id.String()+"\n")
+	}
+	sort.Strings(ids)
+
+	if err := os.MkdirAll(filepath.Dir(r.ownedFile), 0755); err != nil {
+		return errors.Wrapf(err, "unable to create directory for %q", r.ownedFile)
+	}
+
+	// Replace the file atomically so a crash never leaves a partial list.
+	tmp := r.ownedFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strings.Join(ids, "")), 0644); err != nil {
+		return errors.Wrapf(err, "unable to persist owned VPC objects to %q", tmp)
+	}
+
+	if err := os.Rename(tmp, r.ownedFile); err != nil {
+		return errors.Wrapf(err, "unable to persist owned VPC objects to %q", r.ownedFile)
+	}
+
+	return nil
+}
+
+// own records ids as owned by the reconciler.
+func (r *reconciler) own(ids []vpc.ID) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var changed bool
+	for _, id := range ids {
+		if !r.owned[id] {
+			r.owned[id] = true
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return r.saveOwned()
+}
+
+// disown forgets the objects destroyed by the Steps of a Plan.  The objects
+// derived from the CN are always owned.
+func (r *reconciler) disown(steps []topology.Step) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var changed bool
+	for _, step := range steps {
+		if step.Action == topology.ActionDestroy && step.ID != objID(r.cnID, step.ID.ObjType) {
+			delete(r.owned, step.ID)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return r.saveOwned()
+}
+
+// owns returns true if id is owned by the reconciler.
+func (r *reconciler) owns(id vpc.ID) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.owned[id]
+}
+
+// Status returns the outcome of the last reconciliation.
+func (r *reconciler) Status() api.ReconcileStatus {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	status := r.status
+	status.Objects = append([]api.ObjectStatus(nil), r.status.Objects...)
+
+	return status
+}
+
+// Run reconciles until ctx is canceled.
+func (r *reconciler) Run(ctx context.Context) {
+	var backoff time.Duration
+	for {
+		wait, _, _ := r.settings()
+		if err := r.Reconcile(ctx); err != nil {
+			backoff = nextBackoff(backoff)
+			wait = backoff
+			log.Error().Err(err).Dur("retry", wait).Msg("reconciliation failed")
+		} else {
+			backoff = 0
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-r.notifyCh:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// nextBackoff doubles the previous backoff within the bounds of
+// _ReconcileBackoffMin and _ReconcileBackoffMax.
+func nextBackoff(prev time.Duration) time.Duration {
+	switch next := 2 * prev; {
+	case next < _ReconcileBackoffMin:
+		return _ReconcileBackoffMin
+	case next > _ReconcileBackoffMax:
+		return _ReconcileBackoffMax
+	default:
+		return next
+	}
+}
+
+// Wait blocks until the reconciliation in progress, if any, has finished.
+// Resources used by the previous loader, e.g. its database pool, can be
+// released once Configure has replaced it and Wait has returned.
+func (r *reconciler) Wait() {
+	r.runLock.Lock()
+	r.runLock.Unlock()
+}
+
+// Reconcile performs a single reconciliation and records its outcome.
+func (r *reconciler) Reconcile(ctx context.Context) error {
+	r.runLock.Lock()
+	defer r.runLock.Unlock()
+
+	start := time.Now()
+	objects, err := r.reconcile(ctx)
+	if r.metrics != nil {
+		r.metrics.observeReconcile(time.Since(start), objects, err)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.status.Runs++
+	r.status.LastRun = time.Now()
+	r.status.LastError = ""
+	if err != nil {
+		r.status.LastError = err.Error()
+	}
+	if objects != nil {
+		r.status.Objects = objects
+	}
+
+	return err
+}
+
+// reconcile compares the desired and the live state and applies the Steps
+// required to converge them.  The status of every declared or changed object
+// is returned, or nil if the Plan could not be computed.
+func (r *reconciler) reconcile(ctx context.Context) ([]api.ObjectStatus, error) {
+	_, dryRun, load := r.settings()
+
+	doc, err := load(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load desired state")
+	}
+
+	state, err := topology.ReadState()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read VPC objects")
+	}
+
+	// Ownership is persisted before any object is created so that objects
+	// created by a reconciliation are never left behind unowned.
+	if err := r.own(doc.IDs()); err != nil {
+		return nil, err
+	}
+
+	plan, err := topology.NewPrunePlan(doc, state, r.owns)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to plan changes")
+	}
+
+	drift, err := topology.Drift(doc, state)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to detect drift")
+	}
+	plan.Append(drift...)
+
+	if r.metrics != nil {
+		r.metrics.observeDrift(plan.Steps)
+	}
+
+	objects := newObjectStatuses(doc, plan)
+	if plan.Empty() {
+		return objects.list(), nil
+	}
+
+	if dryRun {
+		for _, step := range plan.Steps {
+			log.Warn().Str("action", step.Action.String()).Str("id", step.ID.String()).Str("detail", step.Detail()).Msg("drift detected")
+			objects.set(step.ID, api.ObjectDrift, "")
+		}
+
+		return objects.list(), nil
+	}
+
+	var current int
+	err = plan.Apply(func(i int, step topology.Step) {
+		current = i
+		log.Info().Str("action", step.Action.String()).Str("id", step.ID.String()).Str("detail", step.Detail()).Msg("reconciling")
+	})
+	for i, step := range plan.Steps {
+		switch {
+		case err == nil:
+			objects.set(step.ID, api.ObjectConverged, "")
+		case i == current:
+			objects.set(step.ID, api.ObjectError, errors.Cause(err).Error())
+		default:
+			objects.set(step.ID, api.ObjectPending, "")
+		}
+	}
+	if err != nil {
+		return objects.list(), errors.Wrap(err, "unable to apply changes")
+	}
+
+	if err := r.disown(plan.Steps); err != nil {
+		return objects.list(), err
+	}
+
+	return objects.list(), nil
+}
+
+// objectStatuses tracks the status of the objects of a reconciliation.
+type objectStatuses map[vpc.ID]*api.ObjectStatus
+
+// newObjectStatuses returns the declared objects of doc as in-sync along with
+// the actions of plan required to converge every object.
+func newObjectStatuses(doc *topology.Document, plan *topology.Plan) objectStatuses {
+	objects := make(objectStatuses)
+	for _, id := range doc.IDs() {
+		objects.get(id)
+	}
+
+	for _, step := range plan.Steps {
+		obj := objects.get(step.ID)
+		obj.Actions = append(obj.Actions, step.Action.String())
+	}
+
+	return objects
+}
+
+func (o objectStatuses) get(id vpc.ID) *api.ObjectStatus {
+	obj, found := o[id]
+	if !found {
+		obj = &api.ObjectStatus{
+			ID:    id.String(),
+			Type:  id.ObjType.String(),
+			State: api.ObjectInSync,
+		}
+		o[id] = obj
+	}
+
+	return obj
+}
+
+// set updates the state of id.  An error is never overwritten.
+func (o objectStatuses) set(id vpc.ID, state, errMsg string) {
+	obj := o.get(id)
+	if obj.State == api.ObjectError {
+		return
+	}
+
+	obj.State = state
+	obj.Error = errMsg
+}
+
+// list returns the statuses sorted by type and ID.
+func (o objectStatuses) list() []api.ObjectStatus {
+	objects := make([]api.ObjectStatus, 0, len(o))
+	for _, obj := range o {
+		objects = append(objects, *obj)
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].Type != objects[j].Type {
+			return objects[i].Type < objects[j].Type
+		}
+		return objects[i].ID < objects[j].ID
+	})
+
+	return objects
+}
+
+// dbLoader returns a loadFunc that reads the desired state of cnID from the
+// database.  A MAC address is leased for every VNIC that does not have one yet.
+func dbLoader(pool *db.Pool, cnID uuid.UUID) loadFunc {
+	return func(ctx context.Context) (*topology.Document, error) {
+		q := pool.Pool()
+		vnics, err := db.ListCNVNICs(ctx, q, cnID)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range vnics {
+			if vnics[i].MAC != nil {
+				continue
+			}
+
+			if vnics[i].MAC, err = leaseVNICMAC(ctx, pool, vnics[i]); err != nil {
+				return nil, err
+			}
+		}
+
+		underlayIPs, err := db.ListCNUnderlayIPs(ctx, q, cnID)
+		if err != nil {
+			return nil, err
+		}
+
+		return desiredTopology(cnID, vnics, underlayIPs), nil
+	}
+}
+
+// leaseVNICMAC leases a MAC address in the account of vnic and records it as
+// the MAC address of the VNIC.  The lease is released if it could not be
+// recorded.
+func leaseVNICMAC(ctx context.Context, pool *db.Pool, vnic db.CNVNIC) (net.HardwareAddr, error) {
+	macs := db.NewMACAllocator(pool)
+	mac, err := macs.Lease(ctx, vnic.AccountID, vnic.SubnetID, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to lease a MAC address for VNIC %s", vnic.VNICID)
+	}
+
+	if err := db.SetVNICMAC(ctx, pool.Pool(), vnic.VNICID, mac); err != nil {
+		if err := macs.Release(ctx, vnic.AccountID, mac); err != nil {
+			log.Warn().Err(err).Str("vnic-id", vnic.VNICID.String()).Msg("unable to release MAC address")
+		}
+
+		return nil, err
+	}
+
+	log.Info().Str("vnic-id", vnic.VNICID.String()).Str("mac", mac.String()).Msg("leased VNIC MAC address")
+
+	return mac, nil
+}
+
+// desiredTopology maps the VNICs and underlay IPs of a CN to VPC objects.
+// Every CN has a single VPC Switch.  Each VNIC is a VM NIC with the MAC address
+// of the VNIC connected to a switch port tagged with the VNI and VLAN of its
+// subnet.  When the CN has an
+// underlay IP, a VPC Mux listening on the first underlay IP is connected to
+// the uplink port of the switch.
+func desiredTopology(cnID uuid.UUID, vnics []db.CNVNIC, underlayIPs []db.CNUnderlayIP) *topology.Document {
+	sw := topology.Switch{ID: objID(cnID, vpc.ObjTypeSwitch).String()}
+	doc := &topology.Document{}
+
+	for _, vnic := range vnics {
+		vmnicID := objID(vnic.VNICID, vpc.ObjTypeNICVM)
+		vni := uint32(vnic.VNI)
+		vlan := uint16(vnic.VLANID)
+
+		vmn := topology.VMNIC{ID: vmnicID.String()}
+		if vnic.MAC != nil {
+			vmn.MAC = vnic.MAC.String()
+		}
+		doc.VMNICs = append(doc.VMNICs, vmn)
+		sw.Ports = append(sw.Ports, topology.Port{
+			ID:      objID(vnic.VNICID, vpc.ObjTypeSwitchPort).String(),
+			VNI:     &vni,
+			VLAN:    &vlan,
+			Connect: vmnicID.String(),
+		})
+	}
+
+	if len(underlayIPs) > 0 {
+		muxID := objID(cnID, vpc.ObjTypeMux).String()
+		doc.Muxes = append(doc.Muxes, topology.Mux{
+			ID:     muxID,
+			Listen: net.JoinHostPort(underlayIPs[0].UnderlayIP.String(), strconv.Itoa(mux.UnderlayPort)),
+		})
+		sw.Ports = append(sw.Ports, topology.Port{
+			ID:      objID(cnID, vpc.ObjTypeSwitchPort).String(),
+			Uplink:  true,
+			Connect: muxID,
+		})
+	}
+
+	doc.Switches = append(doc.Switches, sw)
+
+	return doc
+}
+
+// objID derives the VPC ID of an object of objType from the database ID u so
+// that a row always maps to the same VPC object.  The multicast bit of the
+// Node is cleared as required by vpc.ParseID.
+func objID(u uuid.UUID, objType vpc.ObjType) vpc.ID {
+	b := u.Bytes()
+	id := vpc.ID{
+		TimeLow:    binary.LittleEndian.Uint32(b[0:]),
+		TimeMid:    binary.LittleEndian.Uint16(b[4:]),
+		TimeHi:     binary.LittleEndian.Uint16(b[6:]),
+		ClockSeqHi: b[8],
+		ObjType:    objType,
+	}
+	copy(id.Node[:], b[10:])
+	id.Node[0] &^= 0x01
+
+	return id
+}
@@ -0,0 +1,329 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/ethlink"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/hostif"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vmnic"
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/joyent/freebsd-vpc/internal/topology"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestObjID(t *testing.T) {
+	u := uuid.Must(uuid.FromString("ffffffff-ffff-ffff-ffff-ffffffffffff"))
+
+	for _, objType := range []vpc.ObjType{vpc.ObjTypeSwitch, vpc.ObjTypeSwitchPort, vpc.ObjTypeMux, vpc.ObjTypeNICVM} {
+		id := objID(u, objType)
+		parsed, err := vpc.ParseID(id.String())
+		if err != nil {
+			t.Fatalf("unable to parse derived %s ID %s: %v", objType, id, err)
+		}
+
+		if parsed != id || parsed.ObjType != objType {
+			t.Errorf("derived ID mismatch: got %+v, want %+v", parsed, id)
+		}
+
+		if objID(u, objType) != id {
+			t.Errorf("derived %s ID is not stable", objType)
+		}
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	var backoff time.Duration
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if backoff = nextBackoff(backoff); backoff != want {
+			t.Fatalf("expected backoff %s, got %s", want, backoff)
+		}
+	}
+
+	if got := nextBackoff(_ReconcileBackoffMax); got != _ReconcileBackoffMax {
+		t.Fatalf("expected backoff to be capped at %s, got %s", _ReconcileBackoffMax, got)
+	}
+}
+
+func TestReconciler_Simulator(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	cnID := uuid.Must(uuid.NewV4())
+	vnicID := uuid.Must(uuid.NewV4())
+	vnicMAC, _ := net.ParseMAC("02:00:00:00:00:2a")
+	vnics := []db.CNVNIC{{VNICID: vnicID, VMID: uuid.Must(uuid.NewV4()), MAC: vnicMAC, VNI: 4242, VLANID: 42}}
+	underlayIPs := []db.CNUnderlayIP{{CNID: cnID, UnderlayIP: net.ParseIP("192.0.2.1")}}
+	load := func(ctx context.Context) (*topology.Document, error) {
+		return desiredTopology(cnID, vnics, underlayIPs), nil
+	}
+
+	ctx := context.Background()
+	states := func(r *reconciler) map[string]string {
+		states := make(map[string]string)
+		for _, obj := range r.Status().Objects {
+			states[obj.Type] += obj.State + " "
+		}
+		return states
+	}
+
+	// 1) Dry-run only reports drift
+	dryRun := newReconciler(cnID, time.Minute, true, load)
+	if err := dryRun.Reconcile(ctx); err != nil {
+		t.Fatalf("unable to reconcile in dry-run mode: %v", err)
+	}
+
+	state, err := topology.ReadState()
+	if err != nil {
+		t.Fatalf("unable to read state: %v", err)
+	}
+	if len(state) != 0 {
+		t.Fatalf("dry-run created VPC objects: %v", state)
+	}
+
+	if status := dryRun.Status(); len(status.Objects) != 5 || !status.DryRun {
+		t.Fatalf("unexpected dry-run status: %+v", status)
+	}
+	for _, obj := range dryRun.Status().Objects {
+		if obj.State != api.ObjectDrift || len(obj.Actions) == 0 {
+			t.Errorf("expected drift for %+v", obj)
+		}
+	}
+
+	// 2) Converge
+	r := newReconciler(cnID, time.Minute, false, load)
+	if err := r.Reconcile(ctx); err != nil {
+		t.Fatalf("unable to reconcile: %v", err)
+	}
+	for _, obj := range r.Status().Objects {
+		if obj.State != api.ObjectConverged {
+			t.Errorf("expected %+v to be converged", obj)
+		}
+	}
+
+	vmn, err := vmnic.Open(vmnic.Config{ID: objID(vnicID, vpc.ObjTypeNICVM)})
+	if err != nil {
+		t.Fatalf("unable to open VM NIC: %v", err)
+	}
+	mac, err := vmn.MAC()
+	vmn.Close()
+	if err != nil || mac.String() != vnicMAC.String() {
+		t.Errorf("VM NIC MAC = %s, %v; want %s", mac, err, vnicMAC)
+	}
+
+	// 3) Nothing left to do
+	if err := r.Reconcile(ctx); err != nil {
+		t.Fatalf("unable to reconcile: %v", err)
+	}
+	for _, obj := range r.Status().Objects {
+		if obj.State != api.ObjectInSync || len(obj.Actions) != 0 {
+			t.Errorf("expected %+v to be in sync", obj)
+		}
+	}
+
+	// 4) Removing the VNIC destroys its VM NIC and port
+	vnics = nil
+	if err := r.Reconcile(ctx); err != nil {
+		t.Fatalf("unable to reconcile: %v", err)
+	}
+
+	state, err = topology.ReadState()
+	if err != nil {
+		t.Fatalf("unable to read state: %v", err)
+	}
+	if len(state) != 3 || state[objID(vnicID, vpc.ObjTypeNICVM)] || state[objID(vnicID, vpc.ObjTypeSwitchPort)] {
+		t.Fatalf("unexpected VPC objects after removing the VNIC: %v", state)
+	}
+
+	if got := states(r)[vpc.ObjTypeNICVM.String()]; got != api.ObjectConverged+" " {
+		t.Errorf("expected the destroyed VM NIC to be reported as converged, got %q", got)
+	}
+
+	if status := r.Status(); status.Runs != 3 || status.LastError != "" || status.CNID != cnID.String() {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestReconciler_UnownedObjects(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	hifID := vpc.GenID(vpc.ObjTypeHostif)
+	hif, err := hostif.Create(hostif.Config{ID: hifID})
+	if err != nil {
+		t.Fatalf("unable to create hostif: %v", err)
+	}
+	defer hif.Close()
+	if err := hif.Commit(); err != nil {
+		t.Fatalf("unable to commit hostif: %v", err)
+	}
+
+	elID := vpc.GenID(vpc.ObjTypeLinkEth)
+	el, err := ethlink.Create(ethlink.Config{ID: elID})
+	if err != nil {
+		t.Fatalf("unable to create ethlink: %v", err)
+	}
+	defer el.Close()
+	if err := el.Commit(); err != nil {
+		t.Fatalf("unable to commit ethlink: %v", err)
+	}
+
+	cnID := uuid.Must(uuid.NewV4())
+	vnics := []db.CNVNIC{{VNICID: uuid.Must(uuid.NewV4()), VMID: uuid.Must(uuid.NewV4()), VNI: 4242, VLANID: 42}}
+	load := func(ctx context.Context) (*topology.Document, error) {
+		return desiredTopology(cnID, vnics, nil), nil
+	}
+
+	ctx := context.Background()
+	r := newReconciler(cnID, time.Minute, false, load)
+	// Neither converging nor removing the VNIC touches the hostif and ethlink
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			vnics = nil
+		}
+
+		if err := r.Reconcile(ctx); err != nil {
+			t.Fatalf("unable to reconcile: %v", err)
+		}
+
+		state, err := topology.ReadState()
+		if err != nil {
+			t.Fatalf("unable to read state: %v", err)
+		}
+		if !state[hifID] || !state[elID] {
+			t.Fatalf("reconciler destroyed objects it does not own: %v", state)
+		}
+		if !state[objID(cnID, vpc.ObjTypeSwitch)] {
+			t.Fatalf("reconciler did not create the switch: %v", state)
+		}
+	}
+}
+
+func TestReconciler_PersistOwned(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	dir, err := ioutil.TempDir("", "vpc-agent")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ownedFile := filepath.Join(dir, "vpc", "owned")
+
+	cnID := uuid.Must(uuid.NewV4())
+	vnicID := uuid.Must(uuid.NewV4())
+	vnics := []db.CNVNIC{{VNICID: vnicID, VMID: uuid.Must(uuid.NewV4()), VNI: 4242, VLANID: 42}}
+	load := func(ctx context.Context) (*topology.Document, error) {
+		return desiredTopology(cnID, vnics, nil), nil
+	}
+
+	ctx := context.Background()
+	r := newReconciler(cnID, time.Minute, false, load)
+	if err := r.loadOwned(ownedFile); err != nil {
+		t.Fatalf("unable to load owned objects: %v", err)
+	}
+	if err := r.Reconcile(ctx); err != nil {
+		t.Fatalf("unable to reconcile: %v", err)
+	}
+
+	// The VNIC is deleted while the agent is not running
+	vnics = nil
+	restarted := newReconciler(cnID, time.Minute, false, load)
+	if err := restarted.loadOwned(ownedFile); err != nil {
+		t.Fatalf("unable to load owned objects: %v", err)
+	}
+	if !restarted.owns(objID(vnicID, vpc.ObjTypeNICVM)) {
+		t.Fatal("VM NIC is not owned after a restart")
+	}
+	if err := restarted.Reconcile(ctx); err != nil {
+		t.Fatalf("unable to reconcile: %v", err)
+	}
+
+	state, err := topology.ReadState()
+	if err != nil {
+		t.Fatalf("unable to read state: %v", err)
+	}
+	if state[objID(vnicID, vpc.ObjTypeNICVM)] || state[objID(vnicID, vpc.ObjTypeSwitchPort)] {
+		t.Fatalf("objects of the deleted VNIC were not destroyed: %v", state)
+	}
+
+	again := newReconciler(cnID, time.Minute, false, load)
+	if err := again.loadOwned(ownedFile); err != nil {
+		t.Fatalf("unable to load owned objects: %v", err)
+	}
+	if again.owns(objID(vnicID, vpc.ObjTypeNICVM)) {
+		t.Error("destroyed VM NIC is still owned")
+	}
+}
+
+func TestReconciler_Wait(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	load := func(ctx context.Context) (*topology.Document, error) {
+		close(loading)
+		<-release
+		return &topology.Document{}, nil
+	}
+
+	r := newReconciler(uuid.Must(uuid.NewV4()), time.Minute, true, load)
+	go r.Reconcile(context.Background())
+	<-loading
+
+	// Replacing the loader does not wait for the reconciliation in progress
+	r.Configure(time.Minute, true, func(ctx context.Context) (*topology.Document, error) {
+		return &topology.Document{}, nil
+	})
+
+	waited := make(chan struct{})
+	go func() {
+		r.Wait()
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+		t.Fatalf("Wait returned while the previous loader was in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Wait did not return after the reconciliation finished")
+	}
+}
@@ -0,0 +1,374 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	uuid "github.com/satori/go.uuid"
+)
+
+// _AddressPollInterval is how often the addresses of the underlay interfaces
+// are checked for changes.
+const _AddressPollInterval = 10 * time.Second
+
+// loadCNID returns the configured CN ID.  If none is configured, the ID
+// persisted in path is used.  A new ID is generated and persisted in path the
+// first time the agent runs on a host.
+func loadCNID(configured, path string) (uuid.UUID, error) {
+	if configured != "" {
+		id, err := uuid.FromString(configured)
+		if err != nil {
+			return uuid.Nil, errors.Wrapf(err, "unable to parse CN ID %q", configured)
+		}
+
+		return id, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	switch {
+	case err == nil:
+		id, err := uuid.FromString(strings.TrimSpace(string(b)))
+		if err != nil {
+			return uuid.Nil, errors.Wrapf(err, "unable to parse CN ID in %q", path)
+		}
+
+		return id, nil
+	case !os.IsNotExist(err):
+		return uuid.Nil, errors.Wrapf(err, "unable to read CN ID from %q", path)
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return uuid.Nil, errors.Wrap(err, "unable to generate CN ID")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return uuid.Nil, errors.Wrapf(err, "unable to create directory for %q", path)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(id.String()+"\n"), 0644); err != nil {
+		return uuid.Nil, errors.Wrapf(err, "unable to persist CN ID to %q", path)
+	}
+
+	log.Info().Str("cn-id", id.String()).Str("path", path).Msg("generated new CN ID")
+
+	return id, nil
+}
+
+// registrar registers this CN and its underlay IPs in the database and keeps
+// the underlay IPs up to date as the addresses of the underlay interfaces
+// change.
+type registrar struct {
+	cnID         uuid.UUID
+	facilityName string
+	interfaces   []string
+
+	// addrs returns the addresses of the named interface.
+	addrs func(name string) ([]net.Addr, error)
+
+	lock   sync.Mutex
+	pool   *db.Pool
+	status api.RegistrationStatus
+}
+
+func newRegistrar(pool *db.Pool, cnID uuid.UUID, facilityName string, interfaces []string) *registrar {
+	return &registrar{
+		pool:         pool,
+		cnID:         cnID,
+		facilityName: facilityName,
+		interfaces:   interfaces,
+		addrs:        interfaceAddrs,
+		status: api.RegistrationStatus{
+			CNID:        cnID.String(),
+			Facility:    facilityName,
+			Interfaces:  interfaces,
+			UnderlayIPs: []string{},
+		},
+	}
+}
+
+// SetPool changes the database pool subsequent registrations use.
+func (r *registrar) SetPool(pool *db.Pool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.pool = pool
+}
+
+func (r *registrar) getPool() *db.Pool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.pool
+}
+
+// Status returns the outcome of the last registration.
+func (r *registrar) Status() api.RegistrationStatus {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	status := r.status
+	status.UnderlayIPs = append([]string(nil), r.status.UnderlayIPs...)
+
+	return status
+}
+
+// Run re-registers the CN whenever the addresses of its underlay interfaces
+// change or the previous registration failed.  onChange is called after every
+// successful registration that changed the underlay IPs.
+func (r *registrar) Run(ctx context.Context, onChange func()) {
+	ticker := time.NewTicker(_AddressPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ips, err := r.underlayIPs()
+		if err != nil {
+			log.Warn().Err(err).Msg("unable to read underlay addresses")
+			continue
+		}
+
+		status := r.Status()
+		if status.Registered && equalIPs(ips, status.UnderlayIPs) {
+			continue
+		}
+
+		log.Info().Strs("underlay-ips", ipStrings(ips)).Msg("underlay addresses changed, registering CN")
+		if err := r.register(ctx, ips); err != nil {
+			log.Error().Err(err).Msg("unable to register CN")
+			continue
+		}
+
+		if onChange != nil {
+			onChange()
+		}
+	}
+}
+
+// Register registers the CN with the current addresses of its underlay
+// interfaces.
+func (r *registrar) Register(ctx context.Context) error {
+	ips, err := r.underlayIPs()
+	if err != nil {
+		r.setError(err)
+		return errors.Wrap(err, "unable to read underlay addresses")
+	}
+
+	return r.register(ctx, ips)
+}
+
+// register upserts the cn row of this CN and replaces its underlay IPs with
+// ips.  Underlay IPs registered by another CN are moved to this CN.
+func (r *registrar) register(ctx context.Context, ips []net.IP) error {
+	if r.facilityName == "" {
+		err := errors.New("no facility configured")
+		r.setError(err)
+		return err
+	}
+
+	var facility db.Facility
+	err := r.getPool().ExecuteTx(ctx, func(tx *pgx.Tx) error {
+		var err error
+		if facility, err = db.GetFacilityByName(ctx, tx, r.facilityName); err != nil {
+			return err
+		}
+
+		cn, err := db.GetCN(ctx, tx, r.cnID)
+		switch {
+		case db.IsNotFound(err):
+			cn = db.CN{ID: r.cnID, FacilityID: facility.ID}
+			if err := db.CreateCN(ctx, tx, &cn); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case cn.FacilityID != facility.ID:
+			cn.FacilityID = facility.ID
+			if err := db.UpdateCN(ctx, tx, cn); err != nil {
+				return err
+			}
+		}
+
+		registered, err := db.ListCNUnderlayIPs(ctx, tx, r.cnID)
+		if err != nil {
+			return err
+		}
+
+		add, remove := diffUnderlayIPs(registered, ips)
+		for _, ip := range remove {
+			if err := db.DeleteCNUnderlayIP(ctx, tx, ip); err != nil {
+				return err
+			}
+		}
+
+		for _, ip := range add {
+			underlay := db.CNUnderlayIP{CNID: r.cnID, UnderlayIP: ip}
+			_, err := db.GetCNUnderlayIP(ctx, tx, ip)
+			switch {
+			case db.IsNotFound(err):
+				err = db.CreateCNUnderlayIP(ctx, tx, underlay)
+			case err == nil:
+				log.Warn().Str("underlay-ip", ip.String()).Msg("moving underlay IP from another CN")
+				err = db.UpdateCNUnderlayIP(ctx, tx, underlay)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		r.setError(err)
+		return errors.Wrapf(err, "unable to register CN %s", r.cnID)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.status.FacilityID = facility.ID.String()
+	r.status.UnderlayIPs = ipStrings(ips)
+	r.status.Registered = true
+	r.status.LastRegistered = time.Now()
+	r.status.LastError = ""
+
+	log.Info().Str("cn-id", r.cnID.String()).Str("facility", r.facilityName).Strs("underlay-ips", r.status.UnderlayIPs).Msg("registered CN")
+
+	return nil
+}
+
+func (r *registrar) setError(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.status.LastError = err.Error()
+}
+
+// underlayIPs returns the sorted global unicast addresses of the underlay
+// interfaces.
+func (r *registrar) underlayIPs() ([]net.IP, error) {
+	var ips []net.IP
+	for _, name := range r.interfaces {
+		addrs, err := r.addrs(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get addresses of interface %q", name)
+		}
+
+		for _, addr := range addrs {
+			var ip net.IP
+			switch a := addr.(type) {
+			case *net.IPNet:
+				ip = a.IP
+			case *net.IPAddr:
+				ip = a.IP
+			}
+
+			if ip != nil && ip.IsGlobalUnicast() {
+				ips = append(ips, ip)
+			}
+		}
+	}
+
+	sort.Slice(ips, func(i, j int) bool { return bytes.Compare(ips[i].To16(), ips[j].To16()) < 0 })
+
+	return ips, nil
+}
+
+func interfaceAddrs(name string) ([]net.Addr, error) {
+	intf, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return intf.Addrs()
+}
+
+// diffUnderlayIPs returns the IPs in want that are not registered and the
+// registered IPs that are not in want.
+func diffUnderlayIPs(registered []db.CNUnderlayIP, want []net.IP) (add, remove []net.IP) {
+	have := make(map[string]bool, len(registered))
+	for _, ip := range registered {
+		have[ip.UnderlayIP.String()] = true
+	}
+
+	wanted := make(map[string]bool, len(want))
+	for _, ip := range want {
+		wanted[ip.String()] = true
+		if !have[ip.String()] {
+			add = append(add, ip)
+		}
+	}
+
+	for _, ip := range registered {
+		if !wanted[ip.UnderlayIP.String()] {
+			remove = append(remove, ip.UnderlayIP)
+		}
+	}
+
+	return add, remove
+}
+
+func ipStrings(ips []net.IP) []string {
+	strs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		strs = append(strs, ip.String())
+	}
+
+	return strs
+}
+
+func equalIPs(ips []net.IP, strs []string) bool {
+	if len(ips) != len(strs) {
+		return false
+	}
+
+	for i, ip := range ips {
+		if ip.String() != strs[i] {
+			return false
+		}
+	}
+
+	return true
+}
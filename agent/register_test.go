@@ -0,0 +1,140 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package agent
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestLoadCNID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vpc-agent")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "vpc", "cn-id")
+
+	generated, err := loadCNID("", path)
+	if err != nil {
+		t.Fatalf("loadCNID: %v", err)
+	}
+	if uuid.Equal(generated, uuid.Nil) {
+		t.Fatalf("loadCNID generated a nil ID")
+	}
+
+	reloaded, err := loadCNID("", path)
+	if err != nil {
+		t.Fatalf("loadCNID: %v", err)
+	}
+	if !uuid.Equal(reloaded, generated) {
+		t.Errorf("reloaded ID %s, want persisted %s", reloaded, generated)
+	}
+
+	const configured = "9ee1d2e1-41b0-4a0b-9bd1-2ea0be1e8b42"
+	id, err := loadCNID(configured, path)
+	if err != nil {
+		t.Fatalf("loadCNID: %v", err)
+	}
+	if id.String() != configured {
+		t.Errorf("configured ID %s, want %s", id, configured)
+	}
+
+	if _, err := loadCNID("not-a-uuid", path); err == nil {
+		t.Errorf("loadCNID accepted an invalid configured ID")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("garbage\n"), 0644); err != nil {
+		t.Fatalf("unable to write %q: %v", path, err)
+	}
+	if _, err := loadCNID("", path); err == nil {
+		t.Errorf("loadCNID accepted an invalid persisted ID")
+	}
+}
+
+func TestRegistrar_UnderlayIPs(t *testing.T) {
+	addrs := map[string][]net.Addr{
+		"ixl0": {
+			&net.IPNet{IP: net.ParseIP("10.1.0.20"), Mask: net.CIDRMask(24, 32)},
+			&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+		},
+		"ixl1": {
+			&net.IPAddr{IP: net.ParseIP("10.1.0.3")},
+			&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)},
+		},
+	}
+
+	r := newRegistrar(nil, uuid.Nil, "", []string{"ixl0", "ixl1"})
+	r.addrs = func(name string) ([]net.Addr, error) {
+		a, ok := addrs[name]
+		if !ok {
+			return nil, errors.Errorf("no such interface %q", name)
+		}
+
+		return a, nil
+	}
+
+	ips, err := r.underlayIPs()
+	if err != nil {
+		t.Fatalf("underlayIPs: %v", err)
+	}
+	if want := []string{"10.1.0.3", "10.1.0.20"}; !equalIPs(ips, want) {
+		t.Errorf("underlayIPs = %v, want %v", ipStrings(ips), want)
+	}
+
+	r.interfaces = append(r.interfaces, "ixl2")
+	if _, err := r.underlayIPs(); err == nil {
+		t.Errorf("underlayIPs ignored a missing interface")
+	}
+}
+
+func TestDiffUnderlayIPs(t *testing.T) {
+	registered := []db.CNUnderlayIP{
+		{UnderlayIP: net.ParseIP("10.1.0.3")},
+		{UnderlayIP: net.ParseIP("10.1.0.4")},
+	}
+	want := []net.IP{net.ParseIP("10.1.0.4"), net.ParseIP("10.1.0.5")}
+
+	add, remove := diffUnderlayIPs(registered, want)
+	if !equalIPs(add, []string{"10.1.0.5"}) {
+		t.Errorf("add = %v, want [10.1.0.5]", ipStrings(add))
+	}
+	if !equalIPs(remove, []string{"10.1.0.3"}) {
+		t.Errorf("remove = %v, want [10.1.0.3]", ipStrings(remove))
+	}
+
+	add, remove = diffUnderlayIPs(registered, nil)
+	if len(add) != 0 || len(remove) != 2 {
+		t.Errorf("diff against no IPs: add %v, remove %v", ipStrings(add), ipStrings(remove))
+	}
+}
@@ -0,0 +1,588 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package agent
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mgmt"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mux"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vmnic"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcp"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcsw"
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// rpcFunc decodes a request using decode and returns the response to encode.
+type rpcFunc func(decode func(req interface{}) error) (interface{}, error)
+
+// badRequestError is returned by an rpcFunc when the request is malformed.
+type badRequestError struct {
+	err error
+}
+
+func (e badRequestError) Error() string {
+	return e.err.Error()
+}
+
+// newRPCHandler returns the handler for the versioned API served on the
+// internal unix socket.
+func newRPCHandler(a *Agent) http.Handler {
+	handlers := map[string]rpcFunc{
+		api.PathPing:           rpcPing,
+		api.PathList:           rpcList,
+		api.PathSwitchCreate:   rpcSwitchCreate,
+		api.PathSwitchOpen:     rpcSwitchOpen,
+		api.PathSwitchDestroy:  rpcSwitchDestroy,
+		api.PathPortAdd:        rpcPortAdd,
+		api.PathPortRemove:     rpcPortRemove,
+		api.PathPortConnect:    rpcPortConnect,
+		api.PathPortDisconnect: rpcPortDisconnect,
+		api.PathVMNICCreate:    rpcVMNICCreate,
+		api.PathVMNICSet:       rpcVMNICSet,
+		api.PathVMNICDestroy:   rpcVMNICDestroy,
+		api.PathMuxListen:      rpcMuxListen,
+		api.PathMuxConnect:     rpcMuxConnect,
+		api.PathReconcile:      a.rpcReconcile,
+		api.PathStatus:         a.rpcStatus,
+	}
+
+	serveMux := http.NewServeMux()
+	for path, fn := range handlers {
+		serveMux.Handle(path, rpcHandler(path, fn))
+	}
+
+	serveMux.Handle(api.PathHealthz, healthHandler(api.PathHealthz, a.livenessChecks()))
+	serveMux.Handle(api.PathReadyz, healthHandler(api.PathReadyz, a.readinessChecks()))
+
+	return serveMux
+}
+
+func rpcHandler(path string, fn rpcFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeRPCError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		decode := func(req interface{}) error {
+			dec := json.NewDecoder(r.Body)
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(req); err != nil {
+				return badRequestError{err: errors.Wrap(err, "unable to decode request")}
+			}
+
+			return nil
+		}
+
+		resp, err := fn(decode)
+		if err != nil {
+			status := rpcErrorStatus(err)
+			log.Error().Err(err).Str("path", path).Int("status", status).Msg("RPC failed")
+			writeRPCError(w, status, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("unable to write RPC response")
+		}
+	})
+}
+
+// rpcErrorStatus maps the error of an rpcFunc to an HTTP status.
+func rpcErrorStatus(err error) int {
+	if _, ok := errors.Cause(err).(badRequestError); ok {
+		return http.StatusBadRequest
+	}
+
+	switch {
+	case vpc.IsNotExist(err):
+		return http.StatusNotFound
+	case vpc.IsExist(err), vpc.IsBusy(err):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeRPCError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+}
+
+// parseRPCID parses a VPC ID from a request.
+func parseRPCID(name, idStr string) (vpc.ID, error) {
+	if idStr == "" {
+		return vpc.ID{}, badRequestError{err: errors.Errorf("missing %s", name)}
+	}
+
+	id, err := vpc.ParseID(idStr)
+	if err != nil {
+		return vpc.ID{}, badRequestError{err: errors.Wrapf(err, "unable to parse %s", name)}
+	}
+
+	return id, nil
+}
+
+// parseRPCMAC parses an optional MAC address from a request.  An empty MAC
+// uses the Node portion of id.
+func parseRPCMAC(macStr string, id vpc.ID) (net.HardwareAddr, error) {
+	if macStr == "" {
+		return id.Node[:], nil
+	}
+
+	mac, err := net.ParseMAC(macStr)
+	if err != nil {
+		return nil, badRequestError{err: errors.Wrapf(err, "unable to parse MAC %q", macStr)}
+	}
+
+	return mac, nil
+}
+
+func rpcPing(decode func(interface{}) error) (interface{}, error) {
+	return api.PingResponse{Version: api.Version}, nil
+}
+
+func rpcList(decode func(interface{}) error) (interface{}, error) {
+	var req api.ListRequest
+	if err := decode(&req); err != nil {
+		return nil, err
+	}
+
+	objTypes := vpc.ObjTypes()
+	if req.Type != "" && !strings.EqualFold(req.Type, "all") {
+		var found bool
+		for _, objType := range objTypes {
+			if strings.EqualFold(req.Type, objType.String()) {
+				objTypes = []vpc.ObjType{objType}
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, badRequestError{err: errors.Errorf("unsupported VPC Object Type %q", req.Type)}
+		}
+	}
+
+	mgr, err := mgmt.New(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open VPC Management handle")
+	}
+	defer mgr.Close()
+
+	resp := api.ListResponse{Objects: []api.Object{}}
+	for _, objType := range objTypes {
+		objHeaders, err := mgr.GetAllIDs(objType)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get %s VPC objects", objType)
+		}
+
+		for _, hdr := range objHeaders {
+			resp.Objects = append(resp.Objects, api.Object{
+				Type:     hdr.ObjType().String(),
+				ID:       hdr.ID().String(),
+				UnitName: hdr.UnitName(),
+			})
+		}
+	}
+
+	return resp, nil
+}
+
+func rpcSwitchCreate(decode func(interface{}) error) (interface{}, error) {
+	var req api.SwitchCreateRequest
+	if err := decode(&req); err != nil {
+		return nil, err
+	}
+
+	id, err := parseRPCID("switch ID", req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	mac, err := parseRPCMAC(req.MAC, id)
+	if err != nil {
+		return nil, err
+	}
+
+	sw, err := vpcsw.Create(vpcsw.Config{
+		ID:  id,
+		MAC: mac,
+		VNI: vpc.VNI(req.VNI),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create VPC Switch")
+	}
+	defer sw.Close()
+
+	if err := sw.Commit(); err != nil {
+		return nil, errors.Wrap(err, "unable to commit VPC Switch")
+	}
+
+	return api.EmptyResponse{}, nil
+}
+
+func rpcSwitchOpen(decode func(interface{}) error) (interface{}, error) {
+	var req api.ObjectRequest
+	if err := decode(&req); err != nil {
+		return nil, err
+	}
+
+	id, err := parseRPCID("switch ID", req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	sw, err := vpcsw.Open(vpcsw.Config{ID: id})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open VPC Switch")
+	}
+	defer sw.Close()
+
+	return api.Object{
+		Type: vpc.ObjTypeSwitch.String(),
+		ID:   id.String(),
+	}, nil
+}
+
+func rpcSwitchDestroy(decode func(interface{}) error) (interface{}, error) {
+	var req api.ObjectRequest
+	if err := decode(&req); err != nil {
+		return nil, err
+	}
+
+	id, err := parseRPCID("switch ID", req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	sw, err := vpcsw.Open(vpcsw.Config{ID: id, Writeable: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open VPC Switch")
+	}
+	defer sw.Close()
+
+	if err := sw.Destroy(); err != nil {
+		return nil, errors.Wrap(err, "unable to destroy VPC Switch")
+	}
+
+	return api.EmptyResponse{}, nil
+}
+
+// openRPCSwitch decodes a PortRequest and opens its VPC Switch for writing.
+func openRPCSwitch(decode func(interface{}) error) (*vpcsw.VPCSW, api.PortRequest, vpc.ID, error) {
+	var req api.PortRequest
+	if err := decode(&req); err != nil {
+		return nil, req, vpc.ID{}, err
+	}
+
+	switchID, err := parseRPCID("switch ID", req.SwitchID)
+	if err != nil {
+		return nil, req, vpc.ID{}, err
+	}
+
+	portID, err := parseRPCID("port ID", req.PortID)
+	if err != nil {
+		return nil, req, vpc.ID{}, err
+	}
+
+	sw, err := vpcsw.Open(vpcsw.Config{ID: switchID, Writeable: true})
+	if err != nil {
+		return nil, req, vpc.ID{}, errors.Wrap(err, "unable to open VPC Switch")
+	}
+
+	return sw, req, portID, nil
+}
+
+func rpcPortAdd(decode func(interface{}) error) (interface{}, error) {
+	sw, req, portID, err := openRPCSwitch(decode)
+	if err != nil {
+		return nil, err
+	}
+	defer sw.Close()
+
+	mac, err := parseRPCMAC(req.MAC, portID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sw.PortAdd(portID, mac); err != nil {
+		return nil, errors.Wrap(err, "unable to add a port to VPC Switch")
+	}
+
+	return api.EmptyResponse{}, nil
+}
+
+func rpcPortRemove(decode func(interface{}) error) (interface{}, error) {
+	sw, _, portID, err := openRPCSwitch(decode)
+	if err != nil {
+		return nil, err
+	}
+	defer sw.Close()
+
+	if err := sw.PortRemove(portID); err != nil {
+		return nil, errors.Wrap(err, "unable to remove VPC Switch Port")
+	}
+
+	return api.EmptyResponse{}, nil
+}
+
+// openRPCPort decodes a ConnectRequest and opens its VPC Switch Port for
+// writing.
+func openRPCPort(decode func(interface{}) error) (*vpcp.VPCP, vpc.ID, error) {
+	var req api.ConnectRequest
+	if err := decode(&req); err != nil {
+		return nil, vpc.ID{}, err
+	}
+
+	portID, err := parseRPCID("port ID", req.ID)
+	if err != nil {
+		return nil, vpc.ID{}, err
+	}
+
+	interfaceID, err := parseRPCID("interface ID", req.InterfaceID)
+	if err != nil {
+		return nil, vpc.ID{}, err
+	}
+
+	port, err := vpcp.Open(vpcp.Config{ID: portID, Writeable: true})
+	if err != nil {
+		return nil, vpc.ID{}, errors.Wrap(err, "unable to open VPC Switch Port")
+	}
+
+	return port, interfaceID, nil
+}
+
+func rpcPortConnect(decode func(interface{}) error) (interface{}, error) {
+	port, interfaceID, err := openRPCPort(decode)
+	if err != nil {
+		return nil, err
+	}
+	defer port.Close()
+
+	if err := port.Connect(interfaceID); err != nil {
+		return nil, errors.Wrap(err, "unable to connect a VPC Interface to VPC Switch Port")
+	}
+
+	return api.EmptyResponse{}, nil
+}
+
+func rpcPortDisconnect(decode func(interface{}) error) (interface{}, error) {
+	port, interfaceID, err := openRPCPort(decode)
+	if err != nil {
+		return nil, err
+	}
+	defer port.Close()
+
+	if err := port.Disconnect(interfaceID); err != nil {
+		return nil, errors.Wrap(err, "unable to disconnect a VPC Interface from VPC Switch Port")
+	}
+
+	return api.EmptyResponse{}, nil
+}
+
+func rpcVMNICCreate(decode func(interface{}) error) (interface{}, error) {
+	var req api.VMNICCreateRequest
+	if err := decode(&req); err != nil {
+		return nil, err
+	}
+
+	id, err := parseRPCID("VM NIC ID", req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	mac, err := parseRPCMAC(req.MAC, id)
+	if err != nil {
+		return nil, err
+	}
+
+	vmn, err := vmnic.Create(vmnic.Config{ID: id, MAC: mac})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create VM NIC")
+	}
+	defer vmn.Close()
+
+	if err := vmn.Commit(); err != nil {
+		return nil, errors.Wrap(err, "unable to commit VM NIC")
+	}
+
+	return api.EmptyResponse{}, nil
+}
+
+func rpcVMNICSet(decode func(interface{}) error) (interface{}, error) {
+	var req api.VMNICSetRequest
+	if err := decode(&req); err != nil {
+		return nil, err
+	}
+
+	id, err := parseRPCID("VM NIC ID", req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	vmn, err := vmnic.Open(vmnic.Config{ID: id, Writeable: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open VM NIC")
+	}
+	defer vmn.Close()
+
+	if req.Freeze {
+		if err := vmn.Freeze(true); err != nil {
+			return nil, errors.Wrap(err, "unable to freeze the VM NIC")
+		}
+	}
+
+	if req.NumQueues > 0 {
+		if err := vmn.NQueuesSet(req.NumQueues); err != nil {
+			return nil, errors.Wrap(err, "unable to set the number of hardware queues")
+		}
+	}
+
+	if req.Unfreeze {
+		if err := vmn.Freeze(false); err != nil {
+			return nil, errors.Wrap(err, "unable to unfreeze the VM NIC")
+		}
+	}
+
+	return api.EmptyResponse{}, nil
+}
+
+func rpcVMNICDestroy(decode func(interface{}) error) (interface{}, error) {
+	var req api.ObjectRequest
+	if err := decode(&req); err != nil {
+		return nil, err
+	}
+
+	id, err := parseRPCID("VM NIC ID", req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	vmn, err := vmnic.Open(vmnic.Config{ID: id, Writeable: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open VM NIC")
+	}
+	defer vmn.Close()
+
+	mac, err := vmn.MAC()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get VM NIC MAC address")
+	}
+
+	if err := vmn.Destroy(); err != nil {
+		return nil, errors.Wrap(err, "unable to destroy VM NIC")
+	}
+
+	return api.VMNICDestroyResponse{MAC: mac.String()}, nil
+}
+
+func rpcMuxListen(decode func(interface{}) error) (interface{}, error) {
+	var req api.MuxListenRequest
+	if err := decode(&req); err != nil {
+		return nil, err
+	}
+
+	id, err := parseRPCID("mux ID", req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Addr == "" {
+		return nil, badRequestError{err: errors.New("missing listen address")}
+	}
+
+	m, err := mux.Open(mux.Config{ID: id, Writeable: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open VPC Mux")
+	}
+	defer m.Close()
+
+	if err := m.Listen(req.Addr); err != nil {
+		return nil, errors.Wrap(err, "unable to setup VPC Mux listener")
+	}
+
+	return api.EmptyResponse{}, nil
+}
+
+func rpcMuxConnect(decode func(interface{}) error) (interface{}, error) {
+	var req api.ConnectRequest
+	if err := decode(&req); err != nil {
+		return nil, err
+	}
+
+	id, err := parseRPCID("mux ID", req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	interfaceID, err := parseRPCID("interface ID", req.InterfaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := mux.Open(mux.Config{ID: id, Writeable: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open VPC Mux")
+	}
+	defer m.Close()
+
+	if err := m.Connect(interfaceID); err != nil {
+		return nil, errors.Wrap(err, "unable to connect a VPC Interface to VPC Mux")
+	}
+
+	return api.EmptyResponse{}, nil
+}
+
+func (a *Agent) rpcReconcile(decode func(interface{}) error) (interface{}, error) {
+	var req api.ReconcileRequest
+	if err := decode(&req); err != nil {
+		return nil, err
+	}
+
+	if req.Trigger {
+		a.reconciler.Notify()
+	}
+
+	return a.reconciler.Status(), nil
+}
+
+func (a *Agent) rpcStatus(decode func(interface{}) error) (interface{}, error) {
+	return api.StatusResponse{
+		Version:      api.Version,
+		Registration: a.registrar.Status(),
+		Reconcile:    a.reconciler.Status(),
+	}, nil
+}
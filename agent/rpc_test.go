@@ -0,0 +1,324 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package agent
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/joyent/freebsd-vpc/agent/api"
+)
+
+// newRPCClient serves newRPCHandler on a unix socket and returns a client for
+// it along with a function that stops the server.
+func newRPCClient(t *testing.T) (*api.Client, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "vpc-agent")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	socketPath := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("unable to listen on %q: %v", socketPath, err)
+	}
+
+	server := &http.Server{Handler: newRPCHandler(&Agent{})}
+	go server.Serve(l)
+
+	return api.NewClient(socketPath), func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// assertStatus fails the test if err is not an error returned by the client
+// for the given HTTP status, or if a 404 or 409 error does not satisfy
+// vpc.IsNotExist or vpc.IsExist.
+func assertStatus(t *testing.T, err error, status int) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("expected status %d, got no error", status)
+	}
+
+	if want := http.StatusText(status); !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected status %d (%s), got %v", status, want, err)
+	}
+
+	switch {
+	case status == http.StatusNotFound && !vpc.IsNotExist(err):
+		t.Fatalf("expected vpc.IsNotExist(%v)", err)
+	case status == http.StatusConflict && !vpc.IsExist(err):
+		t.Fatalf("expected vpc.IsExist(%v)", err)
+	}
+}
+
+func TestRPCHandler_Client(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	client, stop := newRPCClient(t)
+	defer stop()
+
+	ping, err := client.Ping()
+	if err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if ping.Version != api.Version {
+		t.Errorf("Ping version = %q, want %q", ping.Version, api.Version)
+	}
+
+	switchID := vpc.GenID(vpc.ObjTypeSwitch).String()
+	portID := vpc.GenID(vpc.ObjTypeSwitchPort).String()
+	nicID := vpc.GenID(vpc.ObjTypeNICVM).String()
+
+	if err := client.SwitchCreate(api.SwitchCreateRequest{ID: switchID, VNI: 123}); err != nil {
+		t.Fatalf("SwitchCreate: %v", err)
+	}
+	assertStatus(t, client.SwitchCreate(api.SwitchCreateRequest{ID: switchID, VNI: 123}), http.StatusConflict)
+
+	obj, err := client.SwitchOpen(switchID)
+	if err != nil {
+		t.Fatalf("SwitchOpen: %v", err)
+	}
+	if obj.ID != switchID || obj.Type != vpc.ObjTypeSwitch.String() {
+		t.Errorf("SwitchOpen = %+v", obj)
+	}
+
+	_, err = client.SwitchOpen(vpc.GenID(vpc.ObjTypeSwitch).String())
+	assertStatus(t, err, http.StatusNotFound)
+
+	if err := client.PortAdd(api.PortRequest{SwitchID: switchID, PortID: portID}); err != nil {
+		t.Fatalf("PortAdd: %v", err)
+	}
+
+	const nicMAC = "02:00:00:00:00:2a"
+	if err := client.VMNICCreate(api.VMNICCreateRequest{ID: nicID, MAC: nicMAC}); err != nil {
+		t.Fatalf("VMNICCreate: %v", err)
+	}
+
+	if err := client.PortConnect(api.ConnectRequest{ID: portID, InterfaceID: nicID}); err != nil {
+		t.Fatalf("PortConnect: %v", err)
+	}
+
+	objs, err := client.List("")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	got := make(map[string]string)
+	for _, obj := range objs {
+		got[obj.ID] = obj.Type
+	}
+	want := map[string]string{
+		switchID: vpc.ObjTypeSwitch.String(),
+		portID:   vpc.ObjTypeSwitchPort.String(),
+		nicID:    vpc.ObjTypeNICVM.String(),
+	}
+	for id, objType := range want {
+		if got[id] != objType {
+			t.Errorf("List type of %s = %q, want %q", id, got[id], objType)
+		}
+	}
+
+	objs, err = client.List(vpc.ObjTypeSwitch.String())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objs) != 1 || objs[0].ID != switchID {
+		t.Errorf("List(%s) = %+v", vpc.ObjTypeSwitch, objs)
+	}
+
+	// A connected port and a switch with ports are busy
+	assertStatus(t, client.PortRemove(api.PortRequest{SwitchID: switchID, PortID: portID}), http.StatusConflict)
+	assertStatus(t, client.SwitchDestroy(switchID), http.StatusConflict)
+
+	if err := client.PortDisconnect(api.ConnectRequest{ID: portID, InterfaceID: nicID}); err != nil {
+		t.Fatalf("PortDisconnect: %v", err)
+	}
+	if err := client.PortRemove(api.PortRequest{SwitchID: switchID, PortID: portID}); err != nil {
+		t.Fatalf("PortRemove: %v", err)
+	}
+	if err := client.SwitchDestroy(switchID); err != nil {
+		t.Fatalf("SwitchDestroy: %v", err)
+	}
+	assertStatus(t, client.SwitchDestroy(switchID), http.StatusNotFound)
+
+	destroyed, err := client.VMNICDestroy(nicID)
+	if err != nil {
+		t.Fatalf("VMNICDestroy: %v", err)
+	}
+	if destroyed.MAC != nicMAC {
+		t.Errorf("VMNICDestroy MAC = %q, want %q", destroyed.MAC, nicMAC)
+	}
+	_, err = client.VMNICDestroy(nicID)
+	assertStatus(t, err, http.StatusNotFound)
+}
+
+func TestRPCHandler_Errors(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	handler := newRPCHandler(&Agent{})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{
+			name:   "GET",
+			method: http.MethodGet,
+			path:   api.PathPing,
+			status: http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "malformed JSON",
+			method: http.MethodPost,
+			path:   api.PathSwitchCreate,
+			body:   `{"id":`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown field",
+			method: http.MethodPost,
+			path:   api.PathSwitchCreate,
+			body:   `{"id":"` + vpc.GenID(vpc.ObjTypeSwitch).String() + `","bogus":true}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "missing ID",
+			method: http.MethodPost,
+			path:   api.PathSwitchDestroy,
+			body:   `{}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "malformed ID",
+			method: http.MethodPost,
+			path:   api.PathSwitchOpen,
+			body:   `{"id":"bogus"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "malformed MAC",
+			method: http.MethodPost,
+			path:   api.PathSwitchCreate,
+			body:   `{"id":"` + vpc.GenID(vpc.ObjTypeSwitch).String() + `","mac":"bogus"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unsupported type",
+			method: http.MethodPost,
+			path:   api.PathList,
+			body:   `{"type":"bogus"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "missing switch",
+			method: http.MethodPost,
+			path:   api.PathPortAdd,
+			body:   `{"switch-id":"` + vpc.GenID(vpc.ObjTypeSwitch).String() + `","port-id":"` + vpc.GenID(vpc.ObjTypeSwitchPort).String() + `"}`,
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body.String())
+			}
+
+			if test.status == http.StatusMethodNotAllowed && w.Header().Get("Allow") != http.MethodPost {
+				t.Errorf("Allow = %q, want %q", w.Header().Get("Allow"), http.MethodPost)
+			}
+		})
+	}
+}
+
+func TestListenInternal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vpc-agent")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "agent.sock")
+
+	if _, err := listenInternal(socketPath, "0999", ""); err == nil {
+		t.Fatal("expected an error for an invalid mode")
+	}
+
+	l, err := listenInternal(socketPath, "0600", "")
+	if err != nil {
+		t.Fatalf("listenInternal: %v", err)
+	}
+
+	fi, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("unable to stat socket: %v", err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("socket mode = %o, want 600", perm)
+	}
+
+	if _, err := listenInternal(socketPath, "0600", ""); err == nil {
+		t.Error("expected an error while another listener is active")
+	}
+
+	// Leave the socket file behind the way a crashed agent would.
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	l, err = listenInternal(socketPath, "0660", "")
+	if err != nil {
+		t.Fatalf("listenInternal with a stale socket: %v", err)
+	}
+	defer l.Close()
+
+	notSocket := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(notSocket, nil, 0644); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+	if _, err := listenInternal(notSocket, "0660", ""); err == nil {
+		t.Error("expected an error for a path that is not a socket")
+	}
+}
@@ -24,16 +24,302 @@
 
 package agent
 
+import (
+	"bytes"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/joyent/freebsd-vpc/internal/logger"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"golang.org/x/sys/unix"
+)
+
+// startSignalHandler routes the signals the agent handles to signalHandler.
 func (a *Agent) startSignalHandler() error {
-	// start a go routine to handle signals
+	a.signalCh = make(chan os.Signal, 10)
+	signal.Notify(a.signalCh, unix.SIGHUP, unix.SIGUSR1, unix.SIGINT, unix.SIGTERM, unix.SIGPIPE)
+
+	a.signalWG.Add(1)
+	go a.signalHandler()
+
 	return nil
 }
 
-// Runs the signal handler
+// Runs the signal handler: SIGHUP reloads the configuration, SIGUSR1 dumps the
+// state of the agent to the log, and SIGINT and SIGTERM are passed on to Wait.
 func (a *Agent) signalHandler() {
+	defer a.signalWG.Done()
+
+	for sig := range a.signalCh {
+		switch sig {
+		case unix.SIGPIPE:
+			continue
+		case unix.SIGHUP:
+			log.Info().Str("signal", sig.String()).Msg("caught signal, reloading configuration")
+			if err := a.Reload(); err != nil {
+				log.Error().Err(err).Msg("unable to reload configuration")
+			}
+		case unix.SIGUSR1:
+			log.Info().Str("signal", sig.String()).Msg("caught signal, dumping state")
+			a.DumpState()
+		default:
+			select {
+			case a.terminateCh <- sig:
+			default:
+			}
+		}
+	}
 }
 
 // Terminates the running signal handler
 func (a *Agent) stopSignalHandler() error {
+	if a.signalCh == nil {
+		return nil
+	}
+
+	signal.Stop(a.signalCh)
+	close(a.signalCh)
+	a.signalWG.Wait()
+
 	return nil
 }
+
+// Reload re-reads the configuration file and applies the changes to the log
+// level, the database pool settings, and the reconciler.  Changes to the
+// identity of the CN and to the RPC address require a restart.
+func (a *Agent) Reload() error {
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return errors.Wrap(err, "unable to read config file")
+		}
+	}
+
+	if err := logger.Setup(viper.GetViper()); err != nil {
+		return errors.Wrap(err, "unable to reconfigure logging")
+	}
+
+	config, err := ViperConfig()
+	if err != nil {
+		return err
+	}
+
+	return a.reconfigure(config)
+}
+
+// reconfigure applies config to the running agent.
+func (a *Agent) reconfigure(config Config) error {
+	if err := validateConfig(config); err != nil {
+		return err
+	}
+
+	// The previous database pool is closed once the lock has been released and
+	// the reconciliation using it, if any, has finished.
+	var oldPool *db.Pool
+	defer func() {
+		if oldPool == nil {
+			return
+		}
+
+		a.reconciler.Wait()
+		oldPool.Close()
+	}()
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.stopping {
+		return errors.New("agent is shutting down")
+	}
+
+	old := a.config.AgentConfig
+	agentCfg := &config.AgentConfig
+
+	restartOnly := []struct {
+		name    string
+		changed bool
+	}{
+		{"cn-id", agentCfg.CNID != old.CNID},
+		{"cn-id-file", agentCfg.CNIDFile != old.CNIDFile},
+		{"facility", agentCfg.Facility != old.Facility},
+		{"underlay-interfaces", strings.Join(agentCfg.UnderlayInterfaces, ",") != strings.Join(old.UnderlayInterfaces, ",")},
+		{"addresses.internal", agentCfg.Addresses.Internal != old.Addresses.Internal},
+		{"addresses.internal-group", agentCfg.Addresses.InternalGroup != old.Addresses.InternalGroup},
+		{"addresses.internal-mode", agentCfg.Addresses.InternalMode != old.Addresses.InternalMode},
+		{"addresses.metrics", agentCfg.Addresses.Metrics != old.Addresses.Metrics},
+		{"reconcile.owned-file", agentCfg.Reconcile.OwnedFile != old.Reconcile.OwnedFile},
+	}
+	for _, setting := range restartOnly {
+		if setting.changed {
+			log.Warn().Str("setting", setting.name).Msg("changed setting requires a restart of the agent, ignoring")
+		}
+	}
+	agentCfg.CNID = old.CNID
+	agentCfg.CNIDFile = old.CNIDFile
+	agentCfg.Facility = old.Facility
+	agentCfg.UnderlayInterfaces = old.UnderlayInterfaces
+	agentCfg.Addresses = old.Addresses
+	agentCfg.Reconcile.OwnedFile = old.Reconcile.OwnedFile
+
+	var load loadFunc
+	if config.DBConfig != a.config.DBConfig {
+		dbPool, err := db.New(config.DBConfig)
+		if err != nil {
+			return errors.Wrap(err, "unable to create database pool")
+		}
+
+		// Connections acquired from the old pool are closed as they are
+		// released.
+		oldPool = a.dbPool
+		a.dbPool = dbPool
+		a.registrar.SetPool(dbPool)
+		load = dbLoader(dbPool, a.cnID)
+
+		log.Info().Str("host", config.DBConfig.Host).Uint16("port", config.DBConfig.Port).Msg("database pool reconfigured")
+	}
+
+	a.reconciler.Configure(agentCfg.Reconcile.Interval, agentCfg.Reconcile.DryRun, load)
+	a.config = config
+
+	log.Info().
+		Dur("reconcile-interval", agentCfg.Reconcile.Interval).
+		Bool("dry-run", agentCfg.Reconcile.DryRun).
+		Dur("shutdown-timeout", agentCfg.ShutdownTimeout).
+		Msg("configuration reloaded")
+
+	return nil
+}
+
+// DumpState logs the configuration and the in-memory state of the agent along
+// with a summary of its goroutines.
+func (a *Agent) DumpState() {
+	a.lock.Lock()
+	config := a.config
+	dbStat := a.dbPool.Pool().Stat()
+	a.lock.Unlock()
+
+	agentCfg := config.AgentConfig
+	log.Info().
+		Str("cn-id", a.cnID.String()).
+		Str("facility", agentCfg.Facility).
+		Strs("underlay-interfaces", agentCfg.UnderlayInterfaces).
+		Dur("reconcile-interval", agentCfg.Reconcile.Interval).
+		Bool("dry-run", agentCfg.Reconcile.DryRun).
+		Dur("shutdown-timeout", agentCfg.ShutdownTimeout).
+		Msg("agent configuration")
+
+	log.Info().
+		Str("db-host", config.DBConfig.Host).
+		Uint16("db-port", config.DBConfig.Port).
+		Int("max-connections", dbStat.MaxConnections).
+		Int("current-connections", dbStat.CurrentConnections).
+		Int("available-connections", dbStat.AvailableConnections).
+		Msg("database pool state")
+
+	reg := a.registrar.Status()
+	log.Info().
+		Bool("registered", reg.Registered).
+		Str("facility-id", reg.FacilityID).
+		Strs("underlay-ips", reg.UnderlayIPs).
+		Time("last-registered", reg.LastRegistered).
+		Str("last-error", reg.LastError).
+		Msg("registration state")
+
+	rec := a.reconciler.Status()
+	log.Info().
+		Uint64("runs", rec.Runs).
+		Time("last-run", rec.LastRun).
+		Str("last-error", rec.LastError).
+		Int("objects", len(rec.Objects)).
+		Msg("reconciliation state")
+	for _, obj := range rec.Objects {
+		log.Info().
+			Str("id", obj.ID).
+			Str("type", obj.Type).
+			Str("state", obj.State).
+			Strs("actions", obj.Actions).
+			Str("error", obj.Error).
+			Msg("object state")
+	}
+
+	groups := summarizeGoroutines(goroutineStacks())
+	log.Info().Int("goroutines", runtime.NumGoroutine()).Int("groups", len(groups)).Msg("goroutine summary")
+	for _, g := range groups {
+		log.Info().
+			Int("count", g.count).
+			Str("state", g.state).
+			Str("function", g.function).
+			Msg("goroutines")
+	}
+}
+
+// goroutineGroup counts the goroutines in the same state at the same function.
+type goroutineGroup struct {
+	state    string
+	function string
+	count    int
+}
+
+// goroutineStacks returns the stacks of all goroutines in the format of
+// runtime.Stack.
+func goroutineStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// summarizeGoroutines groups the goroutines in stacks by their state and the
+// function at the top of their stack, largest group first.
+func summarizeGoroutines(stacks []byte) []goroutineGroup {
+	counts := make(map[goroutineGroup]int)
+	for _, block := range bytes.Split(stacks, []byte("\n\n")) {
+		lines := strings.SplitN(strings.TrimSpace(string(block)), "\n", 3)
+		if len(lines) < 2 || !strings.HasPrefix(lines[0], "goroutine ") {
+			continue
+		}
+
+		var g goroutineGroup
+
+		// goroutine 1 [chan receive, 5 minutes]:
+		header := lines[0]
+		if start, end := strings.Index(header, "["), strings.Index(header, "]"); start >= 0 && end > start {
+			g.state = strings.SplitN(header[start+1:end], ",", 2)[0]
+		}
+
+		// pkg.(*T).Method(0xc420010000, ...)
+		g.function = lines[1]
+		if i := strings.LastIndex(g.function, "("); i > 0 {
+			g.function = g.function[:i]
+		}
+
+		counts[g]++
+	}
+
+	groups := make([]goroutineGroup, 0, len(counts))
+	for g, count := range counts {
+		g.count = count
+		groups = append(groups, g)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].count != groups[j].count {
+			return groups[i].count > groups[j].count
+		}
+		if groups[i].function != groups[j].function {
+			return groups[i].function < groups[j].function
+		}
+		return groups[i].state < groups[j].state
+	})
+
+	return groups
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package agent
+
+import (
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestSummarizeGoroutines(t *testing.T) {
+	const stacks = `goroutine 1 [chan receive, 5 minutes]:
+github.com/joyent/freebsd-vpc/agent.(*Agent).Wait(0xc420010000, 0x0, 0x0)
+	/go/src/github.com/joyent/freebsd-vpc/agent/agent.go:157 +0x5b
+main.main()
+	/go/src/github.com/joyent/freebsd-vpc/cmd/vpc/main.go:40 +0x2d
+
+goroutine 7 [select]:
+github.com/joyent/freebsd-vpc/agent.(*reconciler).Run(0xc420090000, 0x9a8f00, 0xc4200a0000)
+	/go/src/github.com/joyent/freebsd-vpc/agent/reconcile.go:140 +0x1c8
+
+goroutine 8 [select]:
+github.com/joyent/freebsd-vpc/agent.(*reconciler).Run(0xc420090100, 0x9a8f00, 0xc4200a0000)
+	/go/src/github.com/joyent/freebsd-vpc/agent/reconcile.go:140 +0x1c8
+
+goroutine 9 [IO wait]:
+internal/poll.runtime_pollWait(0x7f2c5c0e0f00, 0x72, 0x0)
+	/usr/local/go/src/runtime/netpoll.go:173 +0x57
+`
+
+	groups := summarizeGoroutines([]byte(stacks))
+	want := []goroutineGroup{
+		{state: "select", function: "github.com/joyent/freebsd-vpc/agent.(*reconciler).Run", count: 2},
+		{state: "chan receive", function: "github.com/joyent/freebsd-vpc/agent.(*Agent).Wait", count: 1},
+		{state: "IO wait", function: "internal/poll.runtime_pollWait", count: 1},
+	}
+
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d: %+v", len(groups), len(want), groups)
+	}
+	for i := range want {
+		if groups[i] != want[i] {
+			t.Errorf("group %d = %+v, want %+v", i, groups[i], want[i])
+		}
+	}
+
+	if groups := summarizeGoroutines(goroutineStacks()); len(groups) == 0 {
+		t.Errorf("no goroutines summarized for the running test")
+	}
+}
+
+func TestAgent_Reconfigure(t *testing.T) {
+	var config Config
+	config.AgentConfig.Facility = "us-east-1"
+	config.AgentConfig.UnderlayInterfaces = []string{"ixl0"}
+	config.AgentConfig.Reconcile.Interval = time.Minute
+	config.AgentConfig.ShutdownTimeout = 15 * time.Second
+
+	cnID := uuid.Must(uuid.FromString("9ee1d2e1-41b0-4a0b-9bd1-2ea0be1e8b42"))
+	a := &Agent{
+		cnID:       cnID,
+		config:     config,
+		registrar:  newRegistrar(nil, cnID, config.AgentConfig.Facility, config.AgentConfig.UnderlayInterfaces),
+		reconciler: newReconciler(cnID, config.AgentConfig.Reconcile.Interval, false, nil),
+	}
+
+	updated := config
+	updated.AgentConfig.Facility = "us-west-1"
+	updated.AgentConfig.UnderlayInterfaces = []string{"ixl1"}
+	updated.AgentConfig.Reconcile.Interval = 5 * time.Second
+	updated.AgentConfig.Reconcile.DryRun = true
+	updated.AgentConfig.ShutdownTimeout = time.Minute
+
+	if err := a.reconfigure(updated); err != nil {
+		t.Fatalf("reconfigure: %v", err)
+	}
+
+	interval, dryRun, _ := a.reconciler.settings()
+	if interval != 5*time.Second || !dryRun {
+		t.Errorf("reconciler interval %s, dry-run %t; want 5s, true", interval, dryRun)
+	}
+	if !a.reconciler.Status().DryRun {
+		t.Errorf("reconcile status does not report dry-run")
+	}
+
+	if got := a.config.AgentConfig.ShutdownTimeout; got != time.Minute {
+		t.Errorf("shutdown timeout %s, want 1m0s", got)
+	}
+	if got := a.config.AgentConfig.Facility; got != "us-east-1" {
+		t.Errorf("facility changed to %q without a restart", got)
+	}
+	if got := a.config.AgentConfig.UnderlayInterfaces; len(got) != 1 || got[0] != "ixl0" {
+		t.Errorf("underlay interfaces changed to %v without a restart", got)
+	}
+
+	invalid := updated
+	invalid.AgentConfig.ShutdownTimeout = 0
+	if err := a.reconfigure(invalid); err == nil {
+		t.Errorf("reconfigure accepted an invalid shutdown timeout")
+	}
+
+	a.stopping = true
+	if err := a.reconfigure(updated); err == nil {
+		t.Errorf("reconfigure succeeded while the agent is shutting down")
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package health
+
+import (
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	_CmdName = "health"
+	_KeyLive = config.KeyAgentHealthLive
+)
+
+var Cmd = &command.Command{
+	Name: _CmdName,
+
+	Cobra: &cobra.Command{
+		Use:          _CmdName,
+		Short:        "check the health of the running agent",
+		Long:         "Check whether the running agent is ready, or only alive with --live, and exit non-zero if any check failed.",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		Example: `% vpc agent health
+% vpc agent health --live`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cons := conswriter.GetTerminal()
+
+			client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
+			resp, err := client.Health(!viper.GetBool(_KeyLive))
+			if err != nil {
+				return errors.Wrap(err, "unable to get agent health")
+			}
+
+			table := output.Table{
+				Header:          []string{"check", "status", "message"},
+				ColumnAlignment: []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT},
+			}
+
+			var failed int
+			for _, check := range resp.Checks {
+				if check.Status != api.HealthOK {
+					failed++
+				}
+				table.Append(check.Name, check.Status, check.Message)
+			}
+
+			if err := output.Write(cons, viper.GetViper(), resp, table); err != nil {
+				return errors.Wrap(err, "unable to write agent health")
+			}
+
+			if resp.Status != api.HealthOK {
+				return errors.Wrapf(api.ErrUnhealthy, "%d of %d checks failed", failed, len(resp.Checks))
+			}
+
+			return nil
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		{
+			const (
+				key          = _KeyLive
+				longName     = "live"
+				shortName    = ""
+				defaultValue = false
+				description  = "Only check that the agent is alive instead of ready"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.BoolP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		return nil
+	},
+}
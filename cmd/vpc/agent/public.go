@@ -25,20 +25,19 @@
 package agent
 
 import (
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"github.com/joyent/freebsd-vpc/agent"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/agent/health"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/agent/status"
 	"github.com/joyent/freebsd-vpc/db"
 	"github.com/joyent/freebsd-vpc/internal/buildtime"
 	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/config"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"golang.org/x/sys/unix"
 )
 
 const cmdName = "agent"
@@ -59,13 +58,12 @@ var Cmd = &command.Command{
 			log.Info().Str("command", "run").Msg("")
 
 			// 1. Parse config and construct agent
-			var config agent.Config
-			err := viper.Unmarshal(&config)
+			config, err := agent.ViperConfig()
 			if err != nil {
-				return errors.Wrapf(err,"unable to decode config into struct")
+				return errors.Wrap(err, "unable to parse agent config")
 			}
 
-			// 2. Run agent
+			// 2. Run agent until it is signaled to shut down
 			a, err := agent.New(config)
 			if err != nil {
 				return errors.Wrapf(err, "unable to create a new %s agent", buildtime.PROGNAME)
@@ -75,65 +73,205 @@ var Cmd = &command.Command{
 				return errors.Wrapf(err, "unable to start agent")
 			}
 
-			signalCh := make(chan os.Signal, 10)
-			signal.Notify(signalCh, os.Interrupt, unix.SIGTERM, unix.SIGPIPE)
-
-			for {
-				var sig os.Signal
-				select {
-				case s := <-signalCh:
-					sig = s
-				}
-
-				switch sig {
-				case syscall.SIGPIPE:
-					continue
-
-				default:
-					log.Info().Str("signal", sig.String()).Msg("caught signal")
-
-					log.Info().Msg("initiating graceful shutdown of agent")
-					gracefulCh := make(chan struct{})
-					go func() {
-						if err := a.Shutdown(); err != nil {
-							log.Fatal().Err(err).Msg("error during agent shutdown")
-							return
-						}
-						close(gracefulCh)
-					}()
-
-					gracefulTimeout := 15 * time.Second
-					select {
-					case <-signalCh:
-						log.Info().Str("signal", sig.String()).Msg("caught second signal, exiting")
-						os.Exit(1)
-					case <-time.After(gracefulTimeout):
-						log.Info().Dur("timeout", gracefulTimeout).Msg("timeout on graceful shutdown, exiting")
-						os.Exit(1)
-					case <-gracefulCh:
-						log.Info().Msg("graceful shutdown complete")
-						os.Exit(0)
-					}
-				}
+			if err := a.Wait(); err != nil {
+				return errors.Wrap(err, "unable to shut down agent gracefully")
 			}
+
+			log.Info().Msg("graceful shutdown complete")
+
+			return nil
 		},
 	},
 
-	Setup: func(parent *command.Command) error {
+	Setup: func(self *command.Command) error {
 		if err := db.SetDefaultViperOptions(); err != nil {
 			return err
 		}
 
+		{
+			const (
+				key          = config.KeyAgentCNID
+				longName     = "cn-id"
+				shortName    = ""
+				defaultValue = ""
+				description  = "ID of this compute node (defaults to the ID persisted in --cn-id-file)"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = config.KeyAgentCNIDFile
+				longName     = "cn-id-file"
+				shortName    = ""
+				defaultValue = config.DefaultAgentCNIDFile
+				description  = "File the generated ID of this compute node is persisted in"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = config.KeyAgentFacility
+				longName     = "facility"
+				shortName    = ""
+				defaultValue = ""
+				description  = "Name of the facility this compute node is registered in"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key         = config.KeyAgentUnderlayInterfaces
+				longName    = "underlay-interface"
+				shortName   = ""
+				description = "Interface whose addresses are registered as underlay IPs (may be repeated)"
+			)
+			var defaultValue []string
+
+			flags := self.Cobra.Flags()
+			flags.StringSliceP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = config.KeyAgentReconcileInterval
+				longName     = "reconcile-interval"
+				shortName    = ""
+				defaultValue = 30 * time.Second
+				description  = "Interval between reconciliations of the VPC objects on this compute node"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.DurationP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = config.KeyAgentReconcileDryRun
+				longName     = "dry-run"
+				shortName    = "n"
+				defaultValue = false
+				description  = "Log drift between the database and the VPC objects on this compute node without correcting it"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.BoolP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = config.KeyAgentReconcileOwnedFile
+				longName     = "owned-file"
+				shortName    = ""
+				defaultValue = config.DefaultAgentOwnedFile
+				description  = "File the IDs of the VPC objects owned by the reconciler are persisted in"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = config.KeyAgentShutdownTimeout
+				longName     = "shutdown-timeout"
+				shortName    = ""
+				defaultValue = 15 * time.Second
+				description  = "Time a graceful shutdown of the agent may take before it exits"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.DurationP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = config.KeyAgentMetricsAddr
+				longName     = "metrics-addr"
+				shortName    = ""
+				defaultValue = ""
+				description  = "TCP address to serve Prometheus metrics on at /metrics (disabled when empty)"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = config.KeyAgentInternalMode
+				longName     = "internal-mode"
+				shortName    = ""
+				defaultValue = config.DefaultAgentInternalMode
+				description  = "Octal file mode of the unix socket the agent API is served on"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = config.KeyAgentInternalGroup
+				longName     = "internal-group"
+				shortName    = ""
+				defaultValue = ""
+				description  = "Group (name or GID) owning the unix socket the agent API is served on"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
 		if err := setAgentDefaultViperOptions(); err != nil {
 			return err
 		}
 
+		subCommands := []*command.Command{
+			health.Cmd,
+			status.Cmd,
+		}
+
+		if err := self.Register(subCommands); err != nil {
+			log.Fatal().Err(err).Str("cmd", cmdName).Msg("unable to register sub-commands")
+		}
+
 		return nil
 	},
 }
 
 func setAgentDefaultViperOptions() error {
-	viper.SetDefault("agent.addresses.internal", "/tmp/vpc-agent.sock")
+	viper.SetDefault(config.KeyAgentInternalAddr, config.DefaultAgentInternalAddr)
 
 	return nil
 }
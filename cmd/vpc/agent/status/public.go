@@ -0,0 +1,160 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package status
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const _CmdName = "status"
+
+var Cmd = &command.Command{
+	Name: _CmdName,
+
+	Cobra: &cobra.Command{
+		Use:          _CmdName,
+		Short:        "show the registration and reconciliation status of the running agent",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		Example:      `% vpc agent status`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cons := conswriter.GetTerminal()
+
+			client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
+			resp, err := client.Status()
+			if err != nil {
+				return errors.Wrap(err, "unable to get agent status")
+			}
+
+			reg := resp.Registration
+			rec := resp.Reconcile
+			record := statusInfo{
+				APIVersion:         resp.Version,
+				CNID:               reg.CNID,
+				Facility:           reg.Facility,
+				FacilityID:         reg.FacilityID,
+				UnderlayInterfaces: reg.Interfaces,
+				UnderlayIPs:        reg.UnderlayIPs,
+				Registered:         reg.Registered,
+				RegistrationError:  reg.LastError,
+				ReconcileRuns:      rec.Runs,
+				ReconcileDryRun:    rec.DryRun,
+				ReconcileError:     rec.LastError,
+				ObjectStates:       make(map[string]int),
+			}
+			if !reg.LastRegistered.IsZero() {
+				record.LastRegistered = reg.LastRegistered.Format(time.RFC3339)
+			}
+			if !rec.LastRun.IsZero() {
+				record.LastReconciled = rec.LastRun.Format(time.RFC3339)
+			}
+			for _, obj := range rec.Objects {
+				record.ObjectStates[obj.State]++
+			}
+
+			table := output.Table{
+				Header:          []string{"name", "value"},
+				ColumnAlignment: []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT},
+			}
+
+			table.Append("cn-id", record.CNID)
+			table.Append("facility", record.Facility)
+			table.Append("facility-id", record.FacilityID)
+			table.Append("underlay-interfaces", strings.Join(record.UnderlayInterfaces, ","))
+			table.Append("underlay-ips", strings.Join(record.UnderlayIPs, ","))
+			table.Append("registered", strconv.FormatBool(record.Registered))
+			table.Append("last-registered", record.LastRegistered)
+			if record.RegistrationError != "" {
+				table.Append("registration-error", record.RegistrationError)
+			}
+			table.Append("reconcile-runs", strconv.FormatUint(record.ReconcileRuns, 10))
+			table.Append("reconcile-dry-run", strconv.FormatBool(record.ReconcileDryRun))
+			table.Append("last-reconciled", record.LastReconciled)
+			if record.ReconcileError != "" {
+				table.Append("reconcile-error", record.ReconcileError)
+			}
+			table.Append("objects", formatStates(record.ObjectStates))
+
+			return output.Write(cons, viper.GetViper(), record, table)
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		return nil
+	},
+}
+
+// statusInfo is the machine-readable record for the status of the agent.
+type statusInfo struct {
+	APIVersion         string         `json:"api-version" yaml:"api-version"`
+	CNID               string         `json:"cn-id" yaml:"cn-id"`
+	Facility           string         `json:"facility" yaml:"facility"`
+	FacilityID         string         `json:"facility-id" yaml:"facility-id"`
+	UnderlayInterfaces []string       `json:"underlay-interfaces" yaml:"underlay-interfaces"`
+	UnderlayIPs        []string       `json:"underlay-ips" yaml:"underlay-ips"`
+	Registered         bool           `json:"registered" yaml:"registered"`
+	LastRegistered     string         `json:"last-registered,omitempty" yaml:"last-registered,omitempty"`
+	RegistrationError  string         `json:"registration-error,omitempty" yaml:"registration-error,omitempty"`
+	ReconcileRuns      uint64         `json:"reconcile-runs" yaml:"reconcile-runs"`
+	ReconcileDryRun    bool           `json:"reconcile-dry-run" yaml:"reconcile-dry-run"`
+	LastReconciled     string         `json:"last-reconciled,omitempty" yaml:"last-reconciled,omitempty"`
+	ReconcileError     string         `json:"reconcile-error,omitempty" yaml:"reconcile-error,omitempty"`
+	ObjectStates       map[string]int `json:"object-states" yaml:"object-states"`
+}
+
+// formatStates renders the number of objects in each state, e.g.
+// "3 in-sync, 1 drift".
+func formatStates(states map[string]int) string {
+	names := make([]string, 0, len(states))
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%d %s", states[name], name))
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package apply
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/joyent/freebsd-vpc/internal/topology"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	_CmdName     = "apply"
+	_KeyDryRun   = config.KeyApplyDryRun
+	_KeyFilename = config.KeyApplyFilename
+	_KeyPrune    = config.KeyApplyPrune
+)
+
+var Cmd = &command.Command{
+	Name: _CmdName,
+
+	Cobra: &cobra.Command{
+		Use:          _CmdName,
+		Short:        "apply a declarative VPC topology",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		Long: `The apply operation of vpc(8) converges the VPC objects on this host on the
+topology described in a YAML document.  Missing objects are created and
+connected in dependency order, and the VNI, VLAN, and connections of existing
+switch ports and the listen address and connection of existing muxes are
+reconfigured to match the topology.  Objects that are not declared in the
+topology are left alone unless --prune is given, in which case they are
+destroyed once every other step has been applied.  If a step fails, the steps
+that have already been applied are undone.  Destroyed objects can not be
+restored.`,
+		Example: `% cat topology.yaml
+switches:
+  - id: da64c3f3-095d-91e5-df01-5aabcfc52468
+    vni: 123
+    ports:
+      - id: fd436f9c-1f77-11e8-8002-0cc47a6c7d1e
+        connect: 1b0bd9d8-2a8c-11e8-800b-0cc47a6c7d1e
+      - id: 0ebf50e1-1f79-11e8-8002-0cc47a6c7d1e
+        uplink: true
+        connect: 5c4acd32-1b8d-11e8-b408-0cc47a6c7d1e
+hostifs:
+  - id: 1b0bd9d8-2a8c-11e8-800b-0cc47a6c7d1e
+ethlinks:
+  - id: 5c4acd32-1b8d-11e8-b408-0cc47a6c7d1e
+    l2-name: em0
+% vpc apply -f topology.yaml --dry-run
+% vpc apply -f topology.yaml
+% vpc apply -f topology.yaml --prune`,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cons := conswriter.GetTerminal()
+
+			doc, err := topology.Load(viper.GetString(_KeyFilename))
+			if err != nil {
+				return errors.Wrap(err, "unable to load topology")
+			}
+
+			state, err := topology.ReadState()
+			if err != nil {
+				return errors.Wrap(err, "unable to read VPC objects")
+			}
+
+			var prune func(vpc.ID) bool
+			if viper.GetBool(_KeyPrune) {
+				prune = func(vpc.ID) bool { return true }
+			}
+
+			plan, err := topology.NewPrunePlan(doc, state, prune)
+			if err != nil {
+				return errors.Wrap(err, "unable to plan topology changes")
+			}
+
+			drift, err := topology.Drift(doc, state)
+			if err != nil {
+				return errors.Wrap(err, "unable to detect topology drift")
+			}
+			plan.Append(drift...)
+
+			if viper.GetBool(_KeyDryRun) {
+				return writePlan(cons, plan)
+			}
+
+			if plan.Empty() {
+				cons.Write([]byte("Topology is up to date.\n"))
+				return nil
+			}
+
+			numSteps := len(plan.Steps)
+			progress := func(i int, s topology.Step) {
+				cons.Write([]byte(fmt.Sprintf("[%d/%d] %s\n", i+1, numSteps, s)))
+			}
+
+			if err := plan.Apply(progress); err != nil {
+				return errors.Wrap(err, "unable to apply topology")
+			}
+
+			cons.Write([]byte("done.\n"))
+
+			log.Info().Int("steps", numSteps).Msg("topology applied")
+
+			return nil
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		{
+			const (
+				key          = _KeyFilename
+				longName     = "filename"
+				shortName    = "f"
+				defaultValue = ""
+				description  = "Topology document to apply (use - to read from stdin)"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+			self.Cobra.MarkFlagRequired(longName)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = _KeyDryRun
+				longName     = "dry-run"
+				shortName    = "n"
+				defaultValue = false
+				description  = "Print the plan without changing any VPC objects"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.BoolP(longName, shortName, defaultValue, description)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = _KeyPrune
+				longName     = "prune"
+				shortName    = ""
+				defaultValue = false
+				description  = "Destroy VPC objects that are not declared in the topology"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.BoolP(longName, shortName, defaultValue, description)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		return nil
+	},
+}
+
+// planStep is the machine-readable record for a step of a topology plan.
+type planStep struct {
+	Step   int    `json:"step" yaml:"step"`
+	Action string `json:"action" yaml:"action"`
+	ID     string `json:"id" yaml:"id"`
+	Detail string `json:"detail" yaml:"detail"`
+}
+
+func writePlan(cons conswriter.ConsoleWriter, plan *topology.Plan) error {
+	table := output.Table{
+		Header:          []string{"step", "action", "id", "detail"},
+		ColumnAlignment: []int{tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT},
+	}
+
+	records := make([]planStep, 0, len(plan.Steps))
+	for i, s := range plan.Steps {
+		record := planStep{
+			Step:   i + 1,
+			Action: s.Action.String(),
+			ID:     s.ID.String(),
+			Detail: s.Detail(),
+		}
+		records = append(records, record)
+		table.Append(strconv.Itoa(record.Step), record.Action, record.ID, record.Detail)
+	}
+
+	table.Footer = []string{"total", strconv.Itoa(len(records)), "", ""}
+
+	return output.Write(cons, viper.GetViper(), records, table)
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package down
+
+import (
+	"strconv"
+
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	cmdName = "down"
+	keyYes  = config.KeyDBMigrateDownYes
+)
+
+var Cmd = &command.Command{
+	Name: cmdName,
+	Cobra: &cobra.Command{
+		Use:          cmdName + " N",
+		Short:        "revert the last N applied migrations",
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		Long: `The down operation reverts the last N applied migrations.  Reverting a
+migration can drop tables and the data they contain, so --yes is required.`,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n <= 0 {
+				return errors.Errorf("invalid number of migrations: %q", args[0])
+			}
+
+			if !viper.GetBool(keyYes) {
+				return errors.Errorf("refusing to revert %d migration(s) without --yes", n)
+			}
+
+			dbConfig, err := db.ViperConfig()
+			if err != nil {
+				return errors.Wrap(err, "unable to get database config")
+			}
+
+			migrator, err := db.NewMigrator(dbConfig)
+			if err != nil {
+				return errors.Wrap(err, "unable to create migrator")
+			}
+			defer migrator.Close()
+
+			if err := migrator.Down(n); err != nil {
+				return errors.Wrap(err, "unable to revert migrations")
+			}
+
+			status, err := migrator.Status()
+			if err != nil {
+				return errors.Wrap(err, "unable to get migration status")
+			}
+			log.Info().Interface("version", status.Version).Int("pending", len(status.Pending())).Msg("schema downgraded")
+
+			return nil
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		{
+			const (
+				key          = keyYes
+				longName     = "yes"
+				shortName    = "y"
+				defaultValue = false
+				description  = "Confirm reverting migrations (may destroy data)"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.BoolP(longName, shortName, defaultValue, description)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package force
+
+import (
+	"strconv"
+
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const cmdName = "force"
+
+var Cmd = &command.Command{
+	Name: cmdName,
+	Cobra: &cobra.Command{
+		Use:          cmdName + " VERSION",
+		Short:        "set the schema version and clear the dirty flag",
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		Long: `The force operation records VERSION as the current schema version and clears
+the dirty flag without running any migrations.  Use it to recover from a
+migration that failed part way through after repairing the schema by hand.  A
+VERSION of -1 records that no migrations have been applied.`,
+		Example: `% vpc db migrate force 1517299952
+% vpc db migrate force -- -1`,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := strconv.Atoi(args[0])
+			if err != nil {
+				return errors.Errorf("invalid schema version: %q", args[0])
+			}
+
+			dbConfig, err := db.ViperConfig()
+			if err != nil {
+				return errors.Wrap(err, "unable to get database config")
+			}
+
+			migrator, err := db.NewMigrator(dbConfig)
+			if err != nil {
+				return errors.Wrap(err, "unable to create migrator")
+			}
+			defer migrator.Close()
+
+			if err := migrator.Force(version); err != nil {
+				return errors.Wrap(err, "unable to force schema version")
+			}
+
+			log.Info().Int("version", version).Msg("schema version forced")
+
+			return nil
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		return nil
+	},
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package status
+
+import (
+	"strconv"
+
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const cmdName = "status"
+
+var Cmd = &command.Command{
+	Name: cmdName,
+	Cobra: &cobra.Command{
+		Use:          cmdName,
+		Short:        "show the schema version and pending migrations",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cons := conswriter.GetTerminal()
+
+			dbConfig, err := db.ViperConfig()
+			if err != nil {
+				return errors.Wrap(err, "unable to get database config")
+			}
+
+			migrator, err := db.NewMigrator(dbConfig)
+			if err != nil {
+				return errors.Wrap(err, "unable to create migrator")
+			}
+			defer migrator.Close()
+
+			status, err := migrator.Status()
+			if err != nil {
+				return errors.Wrap(err, "unable to get migration status")
+			}
+
+			table := output.Table{
+				Header:          []string{"version", "identifier", "state"},
+				ColumnAlignment: []int{tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT},
+			}
+
+			for _, m := range status.Migrations {
+				var state string
+				switch {
+				case !m.Applied:
+					state = "pending"
+				case status.Dirty && m.Version == *status.Version:
+					state = "dirty"
+				case m.Version == *status.Version:
+					state = "current"
+				default:
+					state = "applied"
+				}
+
+				table.Append(strconv.FormatUint(uint64(m.Version), 10), m.Identifier, state)
+			}
+
+			currentVersion := "none"
+			if status.Version != nil {
+				currentVersion = strconv.FormatUint(uint64(*status.Version), 10)
+			}
+			table.Footer = []string{"version", currentVersion, strconv.Itoa(len(status.Pending())) + " pending"}
+
+			return output.Write(cons, viper.GetViper(), status, table)
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		return nil
+	},
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package target
+
+import (
+	"strconv"
+
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	cmdName = "goto"
+	keyYes  = config.KeyDBMigrateTargetYes
+)
+
+var Cmd = &command.Command{
+	Name: cmdName,
+	Cobra: &cobra.Command{
+		Use:          cmdName + " VERSION",
+		Short:        "migrate the schema up or down to VERSION",
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		Long: `The goto operation applies or reverts migrations until the schema is at
+VERSION.  Migrating to an older version can drop tables and the data they
+contain, so --yes is required when VERSION is older than the current version.`,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := strconv.ParseUint(args[0], 10, 0)
+			if err != nil {
+				return errors.Errorf("invalid schema version: %q", args[0])
+			}
+
+			dbConfig, err := db.ViperConfig()
+			if err != nil {
+				return errors.Wrap(err, "unable to get database config")
+			}
+
+			migrator, err := db.NewMigrator(dbConfig)
+			if err != nil {
+				return errors.Wrap(err, "unable to create migrator")
+			}
+			defer migrator.Close()
+
+			status, err := migrator.Status()
+			if err != nil {
+				return errors.Wrap(err, "unable to get migration status")
+			}
+
+			if status.Version != nil && uint(version) < *status.Version && !viper.GetBool(keyYes) {
+				return errors.Errorf("refusing to migrate down from version %d to %d without --yes", *status.Version, version)
+			}
+
+			if err := migrator.Goto(uint(version)); err != nil {
+				return errors.Wrap(err, "unable to migrate schema")
+			}
+
+			log.Info().Uint64("version", version).Msg("schema migrated")
+
+			return nil
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		{
+			const (
+				key          = keyYes
+				longName     = "yes"
+				shortName    = "y"
+				defaultValue = false
+				description  = "Confirm migrating to an older version (may destroy data)"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.BoolP(longName, shortName, defaultValue, description)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		return nil
+	},
+}
@@ -41,6 +41,7 @@ import (
 
 const (
 	_CmdName          = "connect"
+	_KeyEthLinkClone  = config.KeyEthLinkConnectClone
 	_KeyEthLinkID     = config.KeyEthLinkConnectID
 	_KeyEthLinkL2Name = config.KeyEthLinkConnectL2Name
 )
@@ -70,11 +71,14 @@ var Cmd = &command.Command{
 			}
 
 			l2Name := viper.GetString(_KeyEthLinkL2Name)
-			{
-				if l2Name == "" {
-					return errors.Wrap(err, "unable to get EthLink's physical or cloned interface name")
-				}
+			clone := viper.GetBool(_KeyEthLinkClone)
+			if l2Name == "" {
+				return errors.New("unable to get EthLink's physical or cloned interface name")
+			}
 
+			// When cloning, l2Name names an interface cloner, not an existing
+			// interface.
+			if !clone {
 				existingIfaces, err := vpctest.GetAllInterfaces()
 				if err != nil {
 					return errors.Wrapf(err, "unable to get all interfaces")
@@ -106,7 +110,16 @@ var Cmd = &command.Command{
 			}
 			defer ethLinkNIC.Close()
 
-			if err = ethLinkNIC.Connect(l2Name); err != nil {
+			if clone {
+				if err = ethLinkNIC.CloneAttach(l2Name); err != nil {
+					log.Error().Err(err).Object("ethlink-id", ethLinkID).Str("cloner", l2Name).Msg("vpc ethlink clone attach failed")
+					return errors.Wrap(err, "unable to attach a cloned interface to a VPC EthLink")
+				}
+
+				if l2Name, err = ethLinkNIC.ConnectedName(); err != nil {
+					return errors.Wrap(err, "unable to get the name of the cloned interface")
+				}
+			} else if err = ethLinkNIC.Connect(l2Name); err != nil {
 				log.Error().Err(err).Object("ethlink-id", ethLinkID).Str("l2-name", l2Name).Msg("vpc ethlink connect failed")
 				return errors.Wrap(err, "unable to connect a VPC EthLink to physical or cloned interface")
 			}
@@ -140,6 +153,22 @@ var Cmd = &command.Command{
 			viper.SetDefault(key, defaultValue)
 		}
 
+		{
+			const (
+				key          = _KeyEthLinkClone
+				longName     = "clone"
+				shortName    = ""
+				defaultValue = false
+				description  = "Clone a new interface from the interface cloner named by --l2-name (e.g. vlan) and connect it"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.BoolP(longName, shortName, defaultValue, description)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
 		return nil
 	},
 }
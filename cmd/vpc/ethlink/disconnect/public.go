@@ -0,0 +1,106 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package disconnect
+
+import (
+	"fmt"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/ethlink"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	_CmdName      = "disconnect"
+	_KeyEthLinkID = config.KeyEthLinkDisconnectID
+)
+
+var Cmd = &command.Command{
+	Name: _CmdName,
+
+	Cobra: &cobra.Command{
+		Use:          _CmdName,
+		Short:        "disconnect a VPC EthLink interface from its physical or cloned interface",
+		Aliases:      []string{"disco"},
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			cons := conswriter.GetTerminal()
+
+			cons.Write([]byte(fmt.Sprintf("Disconnecting VPC EthLink from its physical or cloned interface...")))
+
+			ethLinkID, err := flag.GetID(viper.GetViper(), _KeyEthLinkID)
+			if err != nil {
+				return errors.Wrap(err, "unable to get EthLink's VPC ID")
+			}
+
+			ethLinkCfg := ethlink.Config{
+				ID:        ethLinkID,
+				Writeable: true,
+			}
+
+			ethLinkNIC, err := ethlink.Open(ethLinkCfg)
+			if err != nil {
+				log.Error().Err(err).Object("ethlink-id", ethLinkID).Msg("VPC EthLink open failed")
+				return errors.Wrap(err, "unable to open VPC EthLink")
+			}
+			defer ethLinkNIC.Close()
+
+			l2Name, err := ethLinkNIC.ConnectedName()
+			if err != nil {
+				return errors.Wrap(err, "unable to get the name of the interface connected to VPC EthLink")
+			}
+
+			if err = ethLinkNIC.Disconnect(); err != nil {
+				log.Error().Err(err).Object("ethlink-id", ethLinkID).Str("l2-name", l2Name).Msg("vpc ethlink disconnect failed")
+				return errors.Wrap(err, "unable to disconnect a VPC EthLink from its physical or cloned interface")
+			}
+
+			cons.Write([]byte("done.\n"))
+
+			log.Info().Object("ethlink-id", ethLinkID).Str("l2-name", l2Name).Msg("VPC EthLink disconnected from physical or cloned interface")
+
+			return nil
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		if err := flag.AddEthLinkID(self, _KeyEthLinkID, true); err != nil {
+			return errors.Wrap(err, "unable to register VPC EthLink ID flag on VPC EthLink disconnect")
+		}
+
+		return nil
+	},
+}
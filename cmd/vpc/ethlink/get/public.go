@@ -0,0 +1,109 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package get
+
+import (
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/ethlink"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/meta"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	cmdName      = "get"
+	keyEthLinkID = config.KeyEthLinkGetEthLinkID
+	keyMAC       = config.KeyEthLinkGetMAC
+	keyMTU       = config.KeyEthLinkGetMTU
+)
+
+var metaKeys = meta.Keys{MAC: keyMAC, MTU: keyMTU}
+
+var Cmd = &command.Command{
+	Name: cmdName,
+
+	Cobra: &cobra.Command{
+		Use:          cmdName,
+		Short:        "get VPC EthLink information",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cons := conswriter.GetTerminal()
+
+			table := output.Table{
+				Header:          []string{"id", "key", "value"},
+				ColumnAlignment: []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT},
+			}
+
+			id, err := flag.GetID(viper.GetViper(), keyEthLinkID)
+			if err != nil {
+				return errors.Wrap(err, "unable to get VPC EthLink ID")
+			}
+
+			ethLink, err := ethlink.Open(ethlink.Config{ID: id})
+			if err != nil {
+				return errors.Wrap(err, "unable to open VPC EthLink")
+			}
+			defer ethLink.Close()
+
+			attrs, err := meta.Get(viper.GetViper(), metaKeys, id, ethLink, &table)
+			if err != nil {
+				return errors.Wrap(err, "unable to get VPC EthLink attributes")
+			}
+
+			record := ethLinkInfo{
+				ID:    id.String(),
+				Attrs: attrs,
+			}
+
+			return output.Write(cons, viper.GetViper(), record, table)
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		if err := flag.AddEthLinkID(self, keyEthLinkID, true); err != nil {
+			return errors.Wrap(err, "unable to register VPC EthLink ID flag on VPC EthLink get")
+		}
+
+		if err := meta.AddGetFlags(self, metaKeys); err != nil {
+			return errors.Wrap(err, "unable to register meta flags on VPC EthLink get")
+		}
+
+		return nil
+	},
+}
+
+// ethLinkInfo is the machine-readable record for the attributes of a VPC EthLink.
+type ethLinkInfo struct {
+	ID         string `json:"id" yaml:"id"`
+	meta.Attrs `yaml:",inline"`
+}
@@ -32,8 +32,10 @@ import (
 	"strings"
 
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/ethlink"
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mgmt"
 	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
 	"github.com/joyent/freebsd-vpc/internal/config"
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
@@ -63,18 +65,10 @@ var Cmd = &command.Command{
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cons := conswriter.GetTerminal()
 
-			table := tablewriter.NewWriter(cons)
-			table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-			table.SetHeaderLine(false)
-			table.SetAutoFormatHeaders(true)
-
-			table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
-			table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-			table.SetCenterSeparator("")
-			table.SetColumnSeparator("")
-			table.SetRowSeparator("")
-
-			table.SetHeader([]string{"name", "id"})
+			table := output.Table{
+				Header:          []string{"name", "id", "l2 nic", "vtag"},
+				ColumnAlignment: []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT},
+			}
 
 			mgr, err := mgmt.New(nil)
 			if err != nil {
@@ -97,18 +91,30 @@ var Cmd = &command.Command{
 				return errors.Errorf("unsupported sort option: %q", sortBy)
 			}
 
+			records := make([]ethLink, 0, len(objHeaders))
 			for _, hdr := range objHeaders {
-				table.Append([]string{
+				l2Name, vtag, err := getL2Info(hdr.ID())
+				if err != nil {
+					return errors.Wrapf(err, "unable to get the L2 information of VPC EthLink %s", hdr.ID())
+				}
+
+				records = append(records, ethLink{
+					Name:   hdr.UnitName(),
+					ID:     hdr.ID().String(),
+					L2Name: l2Name,
+					VTag:   uint16(vtag),
+				})
+				table.Append(
 					hdr.UnitName(),
 					hdr.ID().String(),
-				})
+					l2Name,
+					strconv.FormatUint(uint64(vtag), 10),
+				)
 			}
 
-			table.SetFooter([]string{"total", strconv.FormatInt(int64(len(objHeaders)), 10), "", "", ""})
-
-			table.Render()
+			table.Footer = []string{"total", strconv.FormatInt(int64(len(objHeaders)), 10), "", ""}
 
-			return nil
+			return output.Write(cons, viper.GetViper(), records, table)
 		},
 	},
 
@@ -134,3 +140,34 @@ var Cmd = &command.Command{
 		return nil
 	},
 }
+
+// getL2Info returns the name of the interface connected to the VPC EthLink
+// (empty if the VPC EthLink isn't connected) and its VTag.
+func getL2Info(id vpc.ID) (l2Name string, vtag vpc.VTag, err error) {
+	el, err := ethlink.Open(ethlink.Config{ID: id})
+	if err != nil {
+		return "", 0, errors.Wrap(err, "unable to open VPC EthLink")
+	}
+	defer el.Close()
+
+	switch l2Name, err = el.ConnectedName(); {
+	case vpc.IsNotExist(err):
+		l2Name = ""
+	case err != nil:
+		return "", 0, errors.Wrap(err, "unable to get connected interface name")
+	}
+
+	if vtag, err = el.VTagGet(); err != nil {
+		return "", 0, errors.Wrap(err, "unable to get VTag")
+	}
+
+	return l2Name, vtag, nil
+}
+
+// ethLink is the machine-readable record for a VPC EthLink.
+type ethLink struct {
+	Name   string `json:"name" yaml:"name"`
+	ID     string `json:"id" yaml:"id"`
+	L2Name string `json:"l2-name,omitempty" yaml:"l2-name,omitempty"`
+	VTag   uint16 `json:"vtag" yaml:"vtag"`
+}
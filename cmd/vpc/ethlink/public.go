@@ -28,7 +28,10 @@ import (
 	"github.com/joyent/freebsd-vpc/cmd/vpc/ethlink/connect"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/ethlink/create"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/ethlink/destroy"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/ethlink/disconnect"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/ethlink/get"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/ethlink/list"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/ethlink/set"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/ethlink/vtag"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/pkg/errors"
@@ -54,7 +57,10 @@ var Cmd = &command.Command{
 			connect.Cmd,
 			create.Cmd,
 			destroy.Cmd,
+			disconnect.Cmd,
+			get.Cmd,
 			list.Cmd,
+			set.Cmd,
 			vtag.Cmd,
 		}
 
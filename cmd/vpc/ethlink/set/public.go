@@ -0,0 +1,90 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package set
+
+import (
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/ethlink"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/meta"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	cmdName      = "set"
+	keyEthLinkID = config.KeyEthLinkSetEthLinkID
+	keyMAC       = config.KeyEthLinkSetMAC
+	keyMTU       = config.KeyEthLinkSetMTU
+)
+
+var metaKeys = meta.Keys{MAC: keyMAC, MTU: keyMTU}
+
+var Cmd = &command.Command{
+	Name: cmdName,
+
+	Cobra: &cobra.Command{
+		Use:          cmdName,
+		Short:        "set VPC EthLink information",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := flag.GetID(viper.GetViper(), keyEthLinkID)
+			if err != nil {
+				return errors.Wrap(err, "unable to get VPC EthLink ID")
+			}
+
+			ethLink, err := ethlink.Open(ethlink.Config{
+				ID:        id,
+				Writeable: true,
+			})
+			if err != nil {
+				return errors.Wrap(err, "unable to open VPC EthLink")
+			}
+			defer ethLink.Close()
+
+			if err := meta.Set(viper.GetViper(), metaKeys, ethLink); err != nil {
+				return errors.Wrap(err, "unable to set VPC EthLink attributes")
+			}
+
+			return nil
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		if err := flag.AddEthLinkID(self, keyEthLinkID, true); err != nil {
+			return errors.Wrap(err, "unable to register VPC EthLink ID flag on VPC EthLink set")
+		}
+
+		if err := meta.AddSetFlags(self, metaKeys); err != nil {
+			return errors.Wrap(err, "unable to register meta flags on VPC EthLink set")
+		}
+
+		return nil
+	},
+}
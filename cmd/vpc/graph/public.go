@@ -0,0 +1,110 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package graph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/joyent/freebsd-vpc/internal/topology"
+	"github.com/pkg/errors"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	_CmdName   = "graph"
+	_KeyFormat = config.KeyGraphFormat
+)
+
+var _Formats = []string{"dot", "json"}
+
+var Cmd = &command.Command{
+	Name: _CmdName,
+
+	Cobra: &cobra.Command{
+		Use:          _CmdName,
+		Short:        "export the topology of the VPC objects on this host",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			format := strings.ToLower(viper.GetString(_KeyFormat))
+			for _, f := range _Formats {
+				if format == f {
+					return nil
+				}
+			}
+
+			return errors.Errorf("unsupported graph format %q (supported formats: %s)", format, strings.Join(_Formats, ", "))
+		},
+
+		Long: `The graph operation of vpc(8) walks the VPC objects on this host and renders
+how switches, ports, interfaces, ethlinks and muxes are connected to each other,
+either in the Graphviz DOT language or as JSON.`,
+		Example: `% vpc graph | dot -Tsvg > vpc.svg
+% vpc graph --format json`,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cons := conswriter.GetTerminal()
+
+			g, err := topology.ReadGraph()
+			if err != nil {
+				return errors.Wrap(err, "unable to read VPC topology")
+			}
+
+			switch format := strings.ToLower(viper.GetString(_KeyFormat)); format {
+			case "dot":
+				return g.WriteDOT(cons)
+			case "json":
+				return g.WriteJSON(cons)
+			default:
+				return errors.Errorf("unsupported graph format %q", format)
+			}
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		{
+			const (
+				key          = _KeyFormat
+				longName     = "format"
+				shortName    = "f"
+				defaultValue = "dot"
+			)
+			description := fmt.Sprintf("Graph format: %s", strings.Join(_Formats, ", "))
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		return nil
+	},
+}
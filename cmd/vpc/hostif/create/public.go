@@ -28,10 +28,13 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/hostif"
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpctest"
+	"github.com/joyent/freebsd-vpc/db"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/maclease"
 	"github.com/joyent/freebsd-vpc/internal/config"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -41,8 +44,10 @@ import (
 )
 
 const (
-	cmdName      = "create"
-	_KeyHostifID = config.KeyHostifCreateID
+	cmdName       = "create"
+	_KeyAccountID = config.KeyHostifCreateAccountID
+	_KeyHostifID  = config.KeyHostifCreateID
+	_KeySubnetID  = config.KeyHostifCreateSubnetID
 )
 
 var Cmd = &command.Command{
@@ -72,6 +77,31 @@ var Cmd = &command.Command{
 				return errors.Wrap(err, "unable to get VPC Hostif ID")
 			}
 
+			// created is set once the interface exists so that a leased MAC
+			// is kept even if a later step fails.
+			var created bool
+			if viper.GetString(_KeyAccountID) != "" {
+				// The Hostif's MAC address is the Node of its ID: lease the
+				// MAC of the requested ID, or derive the ID from a newly
+				// generated MAC.
+				var requested net.HardwareAddr
+				if viper.GetString(_KeyHostifID) != "" {
+					requested = id.Node[:]
+				}
+
+				var mac net.HardwareAddr
+				var finish func(created bool)
+				if mac, finish, err = maclease.Lease(viper.GetViper(), _KeyAccountID, _KeySubnetID, requested); err != nil {
+					return errors.Wrap(err, "unable to lease MAC address")
+				}
+				defer func() { finish(created) }()
+
+				if requested == nil {
+					id = vpc.GenID(vpc.ObjTypeHostif)
+					copy(id.Node[:], mac)
+				}
+			}
+
 			hostifCfg := hostif.Config{
 				ID: id,
 			}
@@ -87,6 +117,7 @@ var Cmd = &command.Command{
 				log.Error().Err(err).Object("hostif-id", id).Msg("Hostif NIC commit failed")
 				return errors.Wrap(err, "unable to commit Hostif NIC")
 			}
+			created = true
 
 			cons.Write([]byte("done.\n"))
 
@@ -116,6 +147,10 @@ var Cmd = &command.Command{
 			return errors.Wrap(err, "unable to register VPC Hostif ID flag on VPC Hostif create")
 		}
 
-		return nil
+		if err := flag.AddMACAllocation(self, _KeyAccountID, _KeySubnetID); err != nil {
+			return errors.Wrap(err, "unable to register MAC allocation flags on VPC Hostif create")
+		}
+
+		return db.SetDefaultViperOptions()
 	},
 }
@@ -28,8 +28,10 @@ import (
 	"fmt"
 
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/hostif"
+	"github.com/joyent/freebsd-vpc/db"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/maclease"
 	"github.com/joyent/freebsd-vpc/internal/config"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -40,6 +42,7 @@ import (
 
 const (
 	cmdName         = "destroy"
+	_KeyAccountID   = config.KeyHostifDestroyAccountID
 	_KeyInterfaceID = config.KeyHostifDestroyID
 )
 
@@ -65,7 +68,15 @@ var Cmd = &command.Command{
 			return errors.Wrap(err, "unable to register VPC Hostif ID flag on VPC Hostif destroy")
 		}
 
-		return nil
+		if err := flag.AddUUID(self, flag.UUIDCfg{
+			Name:        _KeyAccountID,
+			LongName:    "account-id",
+			Description: "release the MAC address to this account's MAC allocator",
+		}); err != nil {
+			return errors.Wrap(err, "unable to register account ID flag on VPC Hostif destroy")
+		}
+
+		return db.SetDefaultViperOptions()
 	},
 }
 
@@ -93,11 +104,22 @@ func runE(cmd *cobra.Command, args []string) error {
 	}
 	defer hostifNIC.Close()
 
+	mac, err := hostifNIC.MAC()
+	if err != nil {
+		return errors.Wrap(err, "unable to get VPC Hostif MAC address")
+	}
+
 	if err := hostifNIC.Destroy(); err != nil {
 		return errors.Wrap(err, "unable to destroy VPC Hostif NIC")
 	}
 
 	cons.Write([]byte("done.\n"))
 
+	if viper.GetString(_KeyAccountID) != "" {
+		if err := maclease.Release(viper.GetViper(), _KeyAccountID, mac); err != nil {
+			return errors.Wrap(err, "unable to release MAC address")
+		}
+	}
+
 	return nil
 }
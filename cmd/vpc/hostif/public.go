@@ -28,7 +28,9 @@ import (
 	"github.com/joyent/freebsd-vpc/cmd/vpc/hostif/create"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/hostif/destroy"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/hostif/genmac"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/hostif/get"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/hostif/list"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/hostif/set"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -50,7 +52,9 @@ var Cmd = &command.Command{
 			create.Cmd,
 			destroy.Cmd,
 			genmac.Cmd,
+			get.Cmd,
 			list.Cmd,
+			set.Cmd,
 		}
 
 		if err := self.Register(subCommands); err != nil {
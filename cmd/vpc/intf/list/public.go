@@ -25,76 +25,227 @@
 package list
 
 import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/hostif"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mgmt"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vmnic"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcsw"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpctest"
 	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/sean-/conswriter"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
-const _CmdName = "list"
+const (
+	_CmdName = "list"
+	_KeyAll  = config.KeyIntfListAll
+)
+
+// Status values reported for each interface.
+const (
+	_StatusOK       = "ok"
+	_StatusNoObject = "no-vpc-obj"
+	_StatusNoIntf   = "no-os-intf"
+)
+
+// _DeviceTypes maps the device name prefix of VPC objects backed by an OS
+// interface to their VPC Object Type.
+var _DeviceTypes = map[string]vpc.ObjType{
+	hostif.DeviceNamePrefix: vpc.ObjTypeHostif,
+	vmnic.DeviceNamePrefix:  vpc.ObjTypeNICVM,
+	vpcsw.DeviceNamePrefix:  vpc.ObjTypeSwitch,
+}
 
 var Cmd = &command.Command{
 	Name: _CmdName,
 	Cobra: &cobra.Command{
 		Use:          _CmdName,
 		Aliases:      []string{"ls"},
-		Short:        "list interfaces",
+		Short:        "list OS interfaces and VPC objects",
 		SilenceUsage: true,
 		Args:         cobra.NoArgs,
-		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return nil
-		},
+
+		Long: `The interface list operation of vpc(8) joins the OS network interfaces with the
+VPC objects in the system.  Interfaces that look like VPC devices but have no
+VPC object are reported with a status of "` + _StatusNoObject + `".  VPC objects
+that should be backed by an interface but are not are reported with a status of
+"` + _StatusNoIntf + `".`,
 
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// mib := []int{unix.CTL_KERN, unix.KERN_OSTYPE}
-			// buf := [256]byte{}
-			// n := unsafe.Sizeof(buf)
-			// if err := unix.SysctlRaw("kern.hostname", mib, &uname.Sysname[0], &n, nil, 0); err != nil {
-			// 	return err
-			// }
-
-			// log.Info().Str("hostname", h).Msg("list")
-
-			return nil
-			// tritonClientConfig, err := api.InitConfig()
-			// if err != nil {
-			// 	return err
-			// }
-
-			// client, err := tritonClientConfig.GetComputeClient()
-			// if err != nil {
-			// 	return err
-			// }
-
-			// instances, err := client.Instances().List(context.Background(), &compute.ListInstancesInput{})
-			// if err != nil {
-			// 	return err
-			// }
-
-			// table := tablewriter.NewWriter(cons)
-			// table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-			// table.SetHeaderLine(false)
-			// table.SetAutoFormatHeaders(true)
-
-			// table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
-			// table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-			// table.SetCenterSeparator("")
-			// table.SetColumnSeparator("")
-			// table.SetRowSeparator("")
-
-			// table.SetHeader([]string{"id", "name", "image", "package"})
-
-			// var numInstances uint
-			// for _, instance := range instances {
-			// 	table.Append([]string{instance.ID, instance.Name, instance.Image, instance.Package})
-			// 	numInstances++
-			// }
-
-			// table.Render()
-
-			return nil
+			cons := conswriter.GetTerminal()
+
+			ifaces, err := vpctest.GetAllInterfaces()
+			if err != nil {
+				return errors.Wrap(err, "unable to get all interfaces")
+			}
+
+			mgr, err := mgmt.New(nil)
+			if err != nil {
+				return errors.Wrap(err, "unable to open VPC Management handle")
+			}
+			defer mgr.Close()
+
+			var objHeaders []mgmt.ObjHeader
+			for _, objType := range vpc.ObjTypes() {
+				hdrs, err := mgr.GetAllIDs(objType)
+				if err != nil {
+					return errors.Wrapf(err, "unable to get VPC IDs for object type %s", objType)
+				}
+
+				objHeaders = append(objHeaders, hdrs...)
+			}
+
+			records := joinInterfaces(ifaces, objHeaders, viper.GetBool(_KeyAll))
+
+			table := output.Table{
+				Header:          []string{"name", "type", "id", "unit name", "mac", "mtu", "flags", "status"},
+				ColumnAlignment: []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT},
+			}
+
+			var numMismatched int
+			for _, r := range records {
+				var mtu string
+				if r.MTU != 0 {
+					mtu = strconv.FormatInt(int64(r.MTU), 10)
+				}
+
+				if r.Status != _StatusOK {
+					numMismatched++
+				}
+
+				table.Append(r.Name, r.Type, r.ID, r.UnitName, r.MAC, mtu, r.Flags, r.Status)
+			}
+
+			table.Footer = []string{"total", strconv.FormatInt(int64(len(records)), 10), "", "", "", "", "mismatched", strconv.FormatInt(int64(numMismatched), 10)}
+
+			return output.Write(cons, viper.GetViper(), records, table)
 		},
 	},
 
 	Setup: func(self *command.Command) error {
+		{
+			const (
+				key          = _KeyAll
+				longName     = "all"
+				shortName    = "a"
+				defaultValue = false
+				description  = "Include OS interfaces that are not VPC devices"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.BoolP(longName, shortName, defaultValue, description)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
 		return nil
 	},
 }
+
+// intfInfo is the machine-readable record for an OS interface and the VPC
+// object backing it, if any.
+type intfInfo struct {
+	Name     string `json:"name" yaml:"name"`
+	Type     string `json:"type" yaml:"type"`
+	ID       string `json:"id" yaml:"id"`
+	UnitName string `json:"unit-name" yaml:"unit-name"`
+	MAC      string `json:"mac" yaml:"mac"`
+	MTU      int    `json:"mtu" yaml:"mtu"`
+	Flags    string `json:"flags" yaml:"flags"`
+	Status   string `json:"status" yaml:"status"`
+}
+
+// joinInterfaces joins the OS interfaces with the VPC object headers by unit
+// name.  OS interfaces that do not look like a VPC device are only included
+// when all is true.
+func joinInterfaces(ifaces vpctest.InterfaceMap, objHeaders []mgmt.ObjHeader, all bool) []intfInfo {
+	records := make([]intfInfo, 0, len(ifaces)+len(objHeaders))
+	seen := make(map[string]struct{}, len(objHeaders))
+
+	for _, hdr := range objHeaders {
+		unitName := hdr.UnitName()
+		r := intfInfo{
+			Type:     hdr.ObjType().String(),
+			ID:       hdr.ID().String(),
+			UnitName: unitName,
+			Status:   _StatusOK,
+		}
+
+		if iface, found := ifaces[unitName]; found {
+			seen[unitName] = struct{}{}
+			r.Name = iface.Name
+			r.MAC = iface.HardwareAddr.String()
+			r.MTU = iface.MTU
+			r.Flags = iface.Flags.String()
+		} else if _, isDevice := deviceType(unitName); isDevice {
+			r.Status = _StatusNoIntf
+		}
+
+		records = append(records, r)
+	}
+
+	for name, iface := range ifaces {
+		if _, found := seen[name]; found {
+			continue
+		}
+
+		r := intfInfo{
+			Name:   iface.Name,
+			MAC:    iface.HardwareAddr.String(),
+			MTU:    iface.MTU,
+			Flags:  iface.Flags.String(),
+			Status: _StatusOK,
+		}
+
+		if objType, isDevice := deviceType(name); isDevice {
+			r.Type = objType.String()
+			r.Status = _StatusNoObject
+		} else if !all {
+			continue
+		}
+
+		records = append(records, r)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			// Sort VPC objects without an OS interface last.
+			if records[i].Name == "" || records[j].Name == "" {
+				return records[j].Name == ""
+			}
+			return records[i].Name < records[j].Name
+		}
+		if records[i].Type != records[j].Type {
+			return records[i].Type < records[j].Type
+		}
+		return records[i].UnitName < records[j].UnitName
+	})
+
+	return records
+}
+
+// deviceType returns the VPC Object Type of an interface name that looks like
+// a VPC device (i.e. "vmnic0").
+func deviceType(name string) (vpc.ObjType, bool) {
+	for prefix, objType := range _DeviceTypes {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		if _, err := strconv.ParseUint(strings.TrimPrefix(name, prefix), 10, 32); err == nil {
+			return objType, true
+		}
+	}
+
+	return vpc.ObjTypeInvalid, false
+}
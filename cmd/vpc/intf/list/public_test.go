@@ -0,0 +1,163 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package list
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mgmt"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpctest"
+)
+
+// objHeader is a mgmt.ObjHeader for a VPC object that does not exist.
+type objHeader struct {
+	id     vpc.ID
+	unitNo uint32
+}
+
+func (oh objHeader) ObjType() vpc.ObjType { return oh.id.ObjType }
+func (oh objHeader) UnitNo() uint32       { return oh.unitNo }
+func (oh objHeader) ID() vpc.ID           { return oh.id }
+func (oh objHeader) UnitName() string     { return fmt.Sprintf("%s%d", oh.id.ObjType, oh.unitNo) }
+
+func TestJoinInterfaces(t *testing.T) {
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	ifaces := vpctest.InterfaceMap{
+		"em0":    {Name: "em0", MTU: 1500, HardwareAddr: mac, Flags: net.FlagUp},
+		"vmnic0": {Name: "vmnic0", MTU: 9000, HardwareAddr: mac, Flags: net.FlagUp},
+		"vmnic7": {Name: "vmnic7", MTU: 1500, HardwareAddr: mac},
+	}
+
+	withIntf := objHeader{id: vpc.GenID(vpc.ObjTypeNICVM), unitNo: 0}
+	withoutIntf := objHeader{id: vpc.GenID(vpc.ObjTypeNICVM), unitNo: 1}
+	port := objHeader{id: vpc.GenID(vpc.ObjTypeSwitchPort), unitNo: 0}
+
+	ok := intfInfo{
+		Name:     "vmnic0",
+		Type:     vpc.ObjTypeNICVM.String(),
+		ID:       withIntf.id.String(),
+		UnitName: "vmnic0",
+		MAC:      mac.String(),
+		MTU:      9000,
+		Flags:    net.FlagUp.String(),
+		Status:   _StatusOK,
+	}
+	noObj := intfInfo{
+		Name:   "vmnic7",
+		Type:   vpc.ObjTypeNICVM.String(),
+		MAC:    mac.String(),
+		MTU:    1500,
+		Flags:  net.Flags(0).String(),
+		Status: _StatusNoObject,
+	}
+	noIntf := intfInfo{
+		Type:     vpc.ObjTypeNICVM.String(),
+		ID:       withoutIntf.id.String(),
+		UnitName: "vmnic1",
+		Status:   _StatusNoIntf,
+	}
+	portInfo := intfInfo{
+		Type:     vpc.ObjTypeSwitchPort.String(),
+		ID:       port.id.String(),
+		UnitName: "vpcp0",
+		Status:   _StatusOK,
+	}
+	osOnly := intfInfo{
+		Name:   "em0",
+		MAC:    mac.String(),
+		MTU:    1500,
+		Flags:  net.FlagUp.String(),
+		Status: _StatusOK,
+	}
+
+	tests := []struct {
+		name   string
+		ifaces vpctest.InterfaceMap
+		hdrs   []objHeader
+		all    bool
+		want   []intfInfo
+	}{
+		{
+			name:   "empty",
+			ifaces: vpctest.InterfaceMap{},
+			want:   []intfInfo{},
+		},
+		{
+			name:   "VPC object with OS interface",
+			ifaces: vpctest.InterfaceMap{"vmnic0": ifaces["vmnic0"]},
+			hdrs:   []objHeader{withIntf},
+			want:   []intfInfo{ok},
+		},
+		{
+			name:   "no-vpc-obj",
+			ifaces: vpctest.InterfaceMap{"vmnic7": ifaces["vmnic7"]},
+			want:   []intfInfo{noObj},
+		},
+		{
+			name:   "no-os-intf",
+			ifaces: vpctest.InterfaceMap{},
+			hdrs:   []objHeader{withoutIntf},
+			want:   []intfInfo{noIntf},
+		},
+		{
+			name:   "VPC object without a device",
+			ifaces: vpctest.InterfaceMap{},
+			hdrs:   []objHeader{port},
+			want:   []intfInfo{portInfo},
+		},
+		{
+			name:   "OS interfaces are excluded",
+			ifaces: ifaces,
+			hdrs:   []objHeader{port, withoutIntf, withIntf},
+			want:   []intfInfo{ok, noObj, noIntf, portInfo},
+		},
+		{
+			name:   "all",
+			ifaces: ifaces,
+			hdrs:   []objHeader{port, withoutIntf, withIntf},
+			all:    true,
+			want:   []intfInfo{osOnly, ok, noObj, noIntf, portInfo},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			hdrs := make([]mgmt.ObjHeader, 0, len(test.hdrs))
+			for _, hdr := range test.hdrs {
+				hdrs = append(hdrs, hdr)
+			}
+
+			got := joinInterfaces(test.ifaces, hdrs, test.all)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("records mismatch:\ngot:  %+v\nwant: %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -33,7 +33,9 @@ import (
 
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mgmt"
+	"github.com/joyent/freebsd-vpc/agent/api"
 	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
 	"github.com/joyent/freebsd-vpc/internal/config"
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
@@ -79,6 +81,10 @@ and their respective VPC IDs.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cons := conswriter.GetTerminal()
 
+			if viper.GetBool(config.KeyViaAgent) {
+				return listViaAgent(cons)
+			}
+
 			if viper.GetBool(keyObjCounts) {
 				return listTypeCount(cons)
 			}
@@ -150,61 +156,44 @@ and their respective VPC IDs.`,
 	},
 }
 
-func listTypeCount(cons conswriter.ConsoleWriter) error {
-	table := tablewriter.NewWriter(cons)
-	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-	table.SetHeaderLine(false)
-	table.SetAutoFormatHeaders(true)
-
-	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
-	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-	table.SetCenterSeparator("")
-	table.SetColumnSeparator("")
-	table.SetRowSeparator("")
+// objCount is the machine-readable record for the number of objects of a
+// given type.
+type objCount struct {
+	Type  string `json:"type" yaml:"type"`
+	Count int64  `json:"count" yaml:"count"`
+}
 
-	table.SetHeader([]string{"name", "count"})
+// objHeader is the machine-readable record for a VPC object.
+type objHeader struct {
+	Type     string `json:"type" yaml:"type"`
+	ID       string `json:"id" yaml:"id"`
+	UnitName string `json:"unit-name" yaml:"unit-name"`
+}
 
+func listTypeCount(cons conswriter.ConsoleWriter) error {
 	mgr, err := mgmt.New(nil)
 	if err != nil {
 		return errors.Wrapf(err, "unable to open VPC Management handle")
 	}
 	defer mgr.Close()
 
-	var numTypes int64
+	records := make([]objCount, 0, len(vpc.ObjTypes()))
 	for _, objType := range vpc.ObjTypes() {
 		count, err := mgr.CountType(objType)
 		if err != nil {
 			return errors.Wrapf(err, "unable to count object type %s", objType)
 		}
 
-		table.Append([]string{
-			objType.String(),
-			strconv.FormatInt(int64(count), 10),
+		records = append(records, objCount{
+			Type:  objType.String(),
+			Count: int64(count),
 		})
-		numTypes++
 	}
 
-	table.SetFooter([]string{"total", strconv.FormatInt(numTypes, 10)})
-
-	table.Render()
-
-	return nil
+	return writeObjCounts(cons, records)
 }
 
 func listTypeIDs(cons conswriter.ConsoleWriter) error {
-	table := tablewriter.NewWriter(cons)
-	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-	table.SetHeaderLine(false)
-	table.SetAutoFormatHeaders(true)
-
-	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT})
-	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-	table.SetCenterSeparator("")
-	table.SetColumnSeparator("")
-	table.SetRowSeparator("")
-
-	table.SetHeader([]string{"type", "id", "unit name"})
-
 	mgr, err := mgmt.New(nil)
 	if err != nil {
 		return errors.Wrapf(err, "unable to open VPC Management handle")
@@ -233,7 +222,7 @@ func listTypeIDs(cons conswriter.ConsoleWriter) error {
 		}
 	}
 
-	var numIDs int64
+	records := []objHeader{}
 	for _, objType := range objTypes {
 		objHeaders, err := mgr.GetAllIDs(objType)
 		if err != nil {
@@ -251,18 +240,106 @@ func listTypeIDs(cons conswriter.ConsoleWriter) error {
 		}
 
 		for _, hdr := range objHeaders {
-			table.Append([]string{
-				hdr.ObjType().String(),
-				hdr.ID().String(),
-				hdr.UnitName(),
+			records = append(records, objHeader{
+				Type:     hdr.ObjType().String(),
+				ID:       hdr.ID().String(),
+				UnitName: hdr.UnitName(),
 			})
-			numIDs++
 		}
 	}
 
-	table.SetFooter([]string{"total", strconv.FormatInt(numIDs, 10), ""})
+	return writeObjHeaders(cons, records)
+}
+
+// listViaAgent lists VPC objects using the API of the vpc agent.
+func listViaAgent(cons conswriter.ConsoleWriter) error {
+	client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
+
+	if viper.GetBool(keyObjCounts) {
+		objects, err := client.List("")
+		if err != nil {
+			return errors.Wrap(err, "unable to list VPC objects via agent")
+		}
+
+		counts := make(map[string]int64, len(objects))
+		for _, obj := range objects {
+			counts[obj.Type]++
+		}
+
+		records := make([]objCount, 0, len(vpc.ObjTypes()))
+		for _, objType := range vpc.ObjTypes() {
+			records = append(records, objCount{
+				Type:  objType.String(),
+				Count: counts[objType.String()],
+			})
+		}
+
+		return writeObjCounts(cons, records)
+	}
+
+	objects, err := client.List(viper.GetString(keyType))
+	if err != nil {
+		return errors.Wrap(err, "unable to list VPC objects via agent")
+	}
+
+	records := make([]objHeader, 0, len(objects))
+	for _, obj := range objects {
+		records = append(records, objHeader{
+			Type:     obj.Type,
+			ID:       obj.ID,
+			UnitName: obj.UnitName,
+		})
+	}
+
+	sortBy := viper.GetString(keySortBy)
+	switch k := strings.ToLower(sortBy); k {
+	case "id":
+		sort.SliceStable(records, func(i, j int) bool {
+			if records[i].Type != records[j].Type {
+				return records[i].Type < records[j].Type
+			}
+			return records[i].ID < records[j].ID
+		})
+	case "name":
+		sort.SliceStable(records, func(i, j int) bool {
+			if records[i].Type != records[j].Type {
+				return records[i].Type < records[j].Type
+			}
+			return records[i].UnitName < records[j].UnitName
+		})
+	default:
+		return errors.Errorf("unsupported sort option: %q", sortBy)
+	}
+
+	return writeObjHeaders(cons, records)
+}
+
+func writeObjCounts(cons conswriter.ConsoleWriter, records []objCount) error {
+	table := output.Table{
+		Header:          []string{"name", "count"},
+		ColumnAlignment: []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT},
+	}
+
+	for _, record := range records {
+		table.Append(record.Type, strconv.FormatInt(record.Count, 10))
+	}
+
+	table.Footer = []string{"total", strconv.FormatInt(int64(len(records)), 10)}
+
+	return output.Write(cons, viper.GetViper(), records, table)
+}
+
+func writeObjHeaders(cons conswriter.ConsoleWriter, records []objHeader) error {
+	table := output.Table{
+		Header:          []string{"type", "id", "unit name"},
+		ColumnAlignment: []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT},
+	}
+
+	for _, record := range records {
+		table.Append(record.Type, record.ID, record.UnitName)
+	}
 
-	table.Render()
+	table.Footer = []string{"total", strconv.FormatInt(int64(len(records)), 10), ""}
 
-	return nil
+	return output.Write(cons, viper.GetViper(), records, table)
 }
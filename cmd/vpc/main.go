@@ -27,6 +27,9 @@ package main
 import (
 	"os"
 
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/sean-/conswriter"
 	"github.com/sean-/sysexits"
@@ -40,12 +43,31 @@ func realmain() int {
 
 	if err := Execute(); err != nil {
 		log.Error().Err(err).Msg("unable to run")
-		os.Exit(sysexits.Software)
+		return exitCode(err)
 	}
 
 	return sysexits.OK
 }
 
+// exitCode maps the VPC error at the root of err to a sysexits(3) exit code.
+// A failed health check of the agent maps to EX_UNAVAILABLE.
+func exitCode(err error) int {
+	switch {
+	case errors.Cause(err) == api.ErrUnhealthy:
+		return sysexits.Unavailable
+	case vpc.IsNotExist(err):
+		return sysexits.NoInput
+	case vpc.IsExist(err):
+		return sysexits.CantCreate
+	case vpc.IsBusy(err):
+		return sysexits.TempFail
+	case vpc.IsPermission(err):
+		return sysexits.NoPerm
+	default:
+		return sysexits.Software
+	}
+}
+
 func main() {
 	os.Exit(realmain())
 }
@@ -28,6 +28,7 @@ import (
 	"fmt"
 
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mux"
+	"github.com/joyent/freebsd-vpc/agent/api"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
 	"github.com/joyent/freebsd-vpc/internal/config"
@@ -72,6 +73,18 @@ var Cmd = &command.Command{
 				return errors.Wrap(err, "unable to get VPC Interface ID")
 			}
 
+			if viper.GetBool(config.KeyViaAgent) {
+				client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
+				if err := client.MuxConnect(api.ConnectRequest{ID: muxID.String(), InterfaceID: targetID.String()}); err != nil {
+					return errors.Wrap(err, "unable to connect a VPC Interface to VPC Mux via agent")
+				}
+
+				cons.Write([]byte("done.\n"))
+				log.Info().Object("mux-id", muxID).Object("interface-id", targetID).Msg("VPC Mux connected to VPC Interface via agent")
+
+				return nil
+			}
+
 			muxCfg := mux.Config{
 				ID:        muxID,
 				Writeable: true,
@@ -28,6 +28,7 @@ import (
 	"fmt"
 
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mux"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpctxn"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
 	"github.com/joyent/freebsd-vpc/internal/config"
@@ -73,61 +74,25 @@ var Cmd = &command.Command{
 				return errors.Wrap(err, "unable to get VPC Mux ID")
 			}
 
-			// Create a stack of commit and undo operations to walk through in the
-			// event of an error.
-			var commit bool
-			var commitFuncs, undoFuncs []func() error
-			defer func() {
-				scopeHandlers := undoFuncs
-				modeStr := "undo"
-				if commit {
-					modeStr = "commit"
-					scopeHandlers = commitFuncs
-				}
-
-				for i := len(scopeHandlers) - 1; i >= 0; i-- {
-					if err := scopeHandlers[i](); err != nil {
-						log.Error().Err(err).Msgf("failure during %s", modeStr)
-					}
-				}
-			}()
-			commitFuncs = append(commitFuncs, func() error {
-				cons.Write([]byte("done.\n"))
-				return nil
-			})
+			txn := vpctxn.New()
+			defer txn.Finish(&err)
 
 			muxCfg := mux.Config{
 				ID:        muxID,
 				Writeable: true,
 			}
 
-			vpcMux, err := mux.Create(muxCfg)
-			if err != nil {
+			if _, err = txn.CreateMux(muxCfg); err != nil {
 				log.Error().Err(err).Object("mux-cfg", muxCfg).Msg("mux create failed")
 				return errors.Wrap(err, "unable to create VPC Mux")
 			}
-			commitFuncs = append(commitFuncs, func() error {
-				if err := vpcMux.Close(); err != nil {
-					log.Error().Err(err).Msg("unable to commit VPC Mux")
-					return errors.Wrap(err, "unable to commit VPC Mux during commit operation")
-				}
-
-				return nil
-			})
-			undoFuncs = append(undoFuncs, func() error {
-				if err := vpcMux.Close(); err != nil {
-					log.Error().Err(err).Msg("unable to clean up VPC Mux during error recovery")
-				}
-
-				return nil
-			})
-
-			if err := vpcMux.Commit(); err != nil {
+
+			if err = txn.Commit(); err != nil {
 				log.Error().Err(err).Object("mux-cfg", muxCfg).Msg("vpc mux commit failed")
 				return errors.Wrap(err, "unable to commit VPC Mux")
 			}
 
-			commit = true
+			cons.Write([]byte("done.\n"))
 
 			log.Info().Object("mux-id", muxID).Msg("mux created")
 
@@ -0,0 +1,161 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package add
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mux"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	_CmdName         = "add"
+	_KeyMAC          = config.KeyMuxFTEAddMAC
+	_KeyMuxID        = config.KeyMuxFTEAddMuxID
+	_KeyUnderlayAddr = config.KeyMuxFTEAddUnderlayAddr
+	_KeyVNI          = config.KeyMuxFTEAddVNI
+)
+
+var Cmd = &command.Command{
+	Name: _CmdName,
+
+	Cobra: &cobra.Command{
+		Use:          _CmdName,
+		Short:        "add or replace a VPC Mux forwarding table entry",
+		Aliases:      []string{"set"},
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		Example:      `% doas vpc mux fte add --mux-id=e4a5e6f2-1b8d-11e8-b4c7-0cc47a6c7d1e --vni=123 --mac=58:9c:fc:00:00:2a --underlay-addr=10.65.0.12:4789`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			cons := conswriter.GetTerminal()
+
+			cons.Write([]byte(fmt.Sprintf("Adding VPC Mux forwarding table entry...")))
+
+			muxID, err := flag.GetMuxID(viper.GetViper(), _KeyMuxID)
+			if err != nil {
+				return errors.Wrap(err, "unable to get VPC Mux ID")
+			}
+
+			mac, err := flag.GetMAC(viper.GetViper(), _KeyMAC, nil)
+			if err != nil {
+				return errors.Wrap(err, "unable to get overlay MAC address")
+			}
+
+			underlayStr := viper.GetString(_KeyUnderlayAddr)
+			if underlayStr == "" {
+				return errors.New("missing underlay address")
+			}
+			if _, _, err := net.SplitHostPort(underlayStr); err != nil {
+				underlayStr = net.JoinHostPort(underlayStr, strconv.Itoa(mux.UnderlayPort))
+			}
+
+			underlay, err := net.ResolveUDPAddr("udp", underlayStr)
+			if err != nil {
+				return errors.Wrapf(err, "unable to parse underlay address %q", underlayStr)
+			}
+
+			fte := mux.FTE{
+				VNI:      vpc.VNI(viper.GetInt(_KeyVNI)),
+				MAC:      mac,
+				Underlay: underlay,
+			}
+
+			muxCfg := mux.Config{
+				ID:        muxID,
+				Writeable: true,
+			}
+
+			vpcMux, err := mux.Open(muxCfg)
+			if err != nil {
+				log.Error().Err(err).Object("mux-id", muxID).Msg("VPC Mux open failed")
+				return errors.Wrap(err, "unable to open VPC Mux")
+			}
+			defer vpcMux.Close()
+
+			if err = vpcMux.FTESet(fte); err != nil {
+				log.Error().Err(err).Object("mux-id", muxID).Object("fte", fte).Msg("vpc mux fte set failed")
+				return errors.Wrap(err, "unable to add VPC Mux forwarding table entry")
+			}
+
+			cons.Write([]byte("done.\n"))
+
+			log.Info().Object("mux-id", muxID).Object("fte", fte).Msg("VPC Mux FTE added")
+
+			return nil
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		if err := flag.AddMuxID(self, _KeyMuxID, true); err != nil {
+			return errors.Wrap(err, "unable to register Mux ID flag on VPC Mux FTE add")
+		}
+
+		if err := flag.AddMAC(self, _KeyMAC, true); err != nil {
+			return errors.Wrap(err, "unable to register MAC flag on VPC Mux FTE add")
+		}
+
+		vniCfg := flag.VNICfg{
+			Name:     _KeyVNI,
+			Required: true,
+		}
+		if err := flag.AddVNI(self, vniCfg); err != nil {
+			return errors.Wrap(err, "unable to register VNI flag on VPC Mux FTE add")
+		}
+
+		{
+			const (
+				key          = _KeyUnderlayAddr
+				longName     = "underlay-addr"
+				shortName    = "u"
+				defaultValue = ""
+				description  = "Underlay address and port of the VPC Mux the overlay MAC is reachable through"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+			self.Cobra.MarkFlagRequired(longName)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		return nil
+	},
+}
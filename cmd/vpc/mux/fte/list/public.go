@@ -0,0 +1,139 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package list
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mux"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	_CmdName  = "list"
+	_KeyMuxID = config.KeyMuxFTEListMuxID
+)
+
+var Cmd = &command.Command{
+	Name: _CmdName,
+
+	Cobra: &cobra.Command{
+		Use:          _CmdName,
+		Aliases:      []string{"ls"},
+		Short:        "list VPC Mux forwarding table entries",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cons := conswriter.GetTerminal()
+
+			table := output.Table{
+				Header:          []string{"vni", "mac", "underlay"},
+				ColumnAlignment: []int{tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT},
+			}
+
+			muxID, err := flag.GetMuxID(viper.GetViper(), _KeyMuxID)
+			if err != nil {
+				return errors.Wrap(err, "unable to get VPC Mux ID")
+			}
+
+			muxCfg := mux.Config{
+				ID: muxID,
+			}
+
+			vpcMux, err := mux.Open(muxCfg)
+			if err != nil {
+				log.Error().Err(err).Object("mux-id", muxID).Msg("VPC Mux open failed")
+				return errors.Wrap(err, "unable to open VPC Mux")
+			}
+			defer vpcMux.Close()
+
+			ftes, err := vpcMux.FTEList()
+			if err != nil {
+				return errors.Wrap(err, "unable to list VPC Mux forwarding table entries")
+			}
+
+			sort.SliceStable(ftes, func(i, j int) bool {
+				if ftes[i].VNI != ftes[j].VNI {
+					return ftes[i].VNI < ftes[j].VNI
+				}
+				return bytes.Compare(ftes[i].MAC, ftes[j].MAC) < 0
+			})
+
+			records := make([]fteEntry, 0, len(ftes))
+			for _, fte := range ftes {
+				var underlay string
+				if fte.Underlay != nil {
+					underlay = fte.Underlay.String()
+				}
+
+				records = append(records, fteEntry{
+					VNI:      uint32(fte.VNI),
+					MAC:      fte.MAC.String(),
+					Underlay: underlay,
+				})
+				table.Append(
+					strconv.FormatInt(int64(fte.VNI), 10),
+					fte.MAC.String(),
+					underlay,
+				)
+			}
+
+			table.Footer = []string{"total", strconv.FormatInt(int64(len(ftes)), 10), ""}
+
+			return output.Write(cons, viper.GetViper(), records, table)
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		if err := flag.AddMuxID(self, _KeyMuxID, true); err != nil {
+			return errors.Wrap(err, "unable to register Mux ID flag on VPC Mux FTE list")
+		}
+
+		return nil
+	},
+}
+
+// fteEntry is the machine-readable record for a VPC Mux forwarding table
+// entry.
+type fteEntry struct {
+	VNI      uint32 `json:"vni" yaml:"vni"`
+	MAC      string `json:"mac" yaml:"mac"`
+	Underlay string `json:"underlay" yaml:"underlay"`
+}
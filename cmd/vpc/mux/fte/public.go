@@ -25,6 +25,9 @@
 package fte
 
 import (
+	"github.com/joyent/freebsd-vpc/cmd/vpc/mux/fte/add"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/mux/fte/del"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/mux/fte/list"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -42,7 +45,11 @@ var Cmd = &command.Command{
 	},
 
 	Setup: func(self *command.Command) error {
-		subCommands := command.Commands{}
+		subCommands := command.Commands{
+			add.Cmd,
+			del.Cmd,
+			list.Cmd,
+		}
 
 		if err := self.Register(subCommands); err != nil {
 			return errors.Wrapf(err, "unable to register sub-commands under %s", cmdName)
@@ -27,8 +27,10 @@ package listen
 import (
 	"fmt"
 	"net"
+	"strconv"
 
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mux"
+	"github.com/joyent/freebsd-vpc/agent/api"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
 	"github.com/joyent/freebsd-vpc/internal/config"
@@ -53,6 +55,7 @@ var Cmd = &command.Command{
 		Short:        "listen address to use when sending/receiving muxed VPC traffic",
 		SilenceUsage: true,
 		Args:         cobra.NoArgs,
+		Example:      `% doas vpc mux listen --mux-id=e4a5e6f2-1b8d-11e8-b4c7-0cc47a6c7d1e --listen-addr=[2001:db8::12]:4789`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return nil
 		},
@@ -67,28 +70,31 @@ var Cmd = &command.Command{
 				return errors.Wrap(err, "unable to get VPC Mux ID")
 			}
 
-			listenAddr := viper.GetString(_KeyListenAddr)
-			if listenAddr == "" {
-				return errors.Wrap(err, "unable to listening address for VPC Mux")
+			listenStr := viper.GetString(_KeyListenAddr)
+			if listenStr == "" {
+				return errors.New("missing listen address for VPC Mux")
+			}
+			if _, _, err := net.SplitHostPort(listenStr); err != nil {
+				listenStr = net.JoinHostPort(listenStr, strconv.Itoa(mux.UnderlayPort))
 			}
-			{
-				host, port, err := net.SplitHostPort(listenAddr)
-				if err != nil {
-					return errors.Wrap(err, "unable to find host/port")
-				}
 
-				ip := net.ParseIP(host)
-				if ip == nil {
-					return errors.Wrap(err, "invalid IP address")
-				}
+			udpAddr, err := net.ResolveUDPAddr("udp", listenStr)
+			if err != nil {
+				return errors.Wrapf(err, "unable to resolve listen address %q", listenStr)
+			}
+			listenAddr := udpAddr.String()
+			viper.Set(_KeyListenAddr, listenAddr)
 
-				if port == "" {
-					// FIXME(seanc@): turn this into a const
-					port = "4789"
+			if viper.GetBool(config.KeyViaAgent) {
+				client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
+				if err := client.MuxListen(api.MuxListenRequest{ID: muxID.String(), Addr: listenAddr}); err != nil {
+					return errors.Wrap(err, "unable to setup VPC Mux listener via agent")
 				}
 
-				listenAddr = net.JoinHostPort(ip.String(), port)
-				viper.Set(_KeyListenAddr, listenAddr)
+				cons.Write([]byte("done.\n"))
+				log.Info().Object("mux-id", muxID).Str("listen-addr", listenAddr).Msg("VPC Mux listener started via agent")
+
+				return nil
 			}
 
 			muxCfg := mux.Config{
@@ -103,7 +109,7 @@ var Cmd = &command.Command{
 			}
 			defer vpcMux.Close()
 
-			if err = vpcMux.Listen(listenAddr); err != nil {
+			if err = vpcMux.ListenUDP(udpAddr); err != nil {
 				log.Error().Err(err).Object("mux-id", muxID).Str("listen-addr", listenAddr).Msg("vpc mux listen failed")
 				return errors.Wrap(err, "unable to setup VPC Mux listener")
 			}
@@ -123,7 +129,7 @@ var Cmd = &command.Command{
 				longName     = "listen-addr"
 				shortName    = ""
 				defaultValue = ""
-				description  = "Address and port the VPC Mux will use to listen for traffic on the underlay network"
+				description  = "Address (IPv4, IPv6 or name) and port the VPC Mux will use to listen for traffic on the underlay network"
 			)
 
 			flags := self.Cobra.Flags()
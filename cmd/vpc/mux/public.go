@@ -30,7 +30,9 @@ import (
 	"github.com/joyent/freebsd-vpc/cmd/vpc/mux/destroy"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/mux/disconnect"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/mux/fte"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/mux/get"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/mux/listen"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/mux/set"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/mux/show"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/pkg/errors"
@@ -54,7 +56,9 @@ var Cmd = &command.Command{
 			disconnect.Cmd,
 			destroy.Cmd,
 			fte.Cmd,
+			get.Cmd,
 			listen.Cmd,
+			set.Cmd,
 			show.Cmd,
 		}
 
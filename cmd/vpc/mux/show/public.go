@@ -25,9 +25,12 @@
 package show
 
 import (
+	"strconv"
+
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mux"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
 	"github.com/joyent/freebsd-vpc/internal/config"
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
@@ -58,18 +61,10 @@ var Cmd = &command.Command{
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cons := conswriter.GetTerminal()
 
-			table := tablewriter.NewWriter(cons)
-			table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-			table.SetHeaderLine(false)
-			table.SetAutoFormatHeaders(true)
-
-			table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
-			table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-			table.SetCenterSeparator("")
-			table.SetColumnSeparator("")
-			table.SetRowSeparator("")
-
-			table.SetHeader([]string{"name", "id"})
+			table := output.Table{
+				Header:          []string{"name", "id"},
+				ColumnAlignment: []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT},
+			}
 
 			muxID, err := flag.GetMuxID(viper.GetViper(), _KeyMuxID)
 			if err != nil {
@@ -92,18 +87,28 @@ var Cmd = &command.Command{
 				return errors.Wrapf(err, "unable to get VPC ID for interface connected to VPC Mux")
 			}
 
-			host, port, err := vpcMux.ListenAddr()
+			listenAddr, err := vpcMux.ListenAddr()
 			if err != nil {
 				return errors.Wrapf(err, "unable to get VPC Mux listening address")
 			}
 
-			table.Append([]string{"interface-id", interfaceID.String()})
-			table.Append([]string{"listen-addr", host})
-			table.Append([]string{"listen-port", port})
+			record := muxInfo{
+				InterfaceID: interfaceID.String(),
+			}
+			if listenAddr != nil {
+				record.ListenAddr = listenAddr.IP.String()
+				record.ListenPort = strconv.Itoa(listenAddr.Port)
+				record.ListenZone = listenAddr.Zone
+			}
 
-			table.Render()
+			table.Append("interface-id", record.InterfaceID)
+			table.Append("listen-addr", record.ListenAddr)
+			table.Append("listen-port", record.ListenPort)
+			if record.ListenZone != "" {
+				table.Append("listen-zone", record.ListenZone)
+			}
 
-			return nil
+			return output.Write(cons, viper.GetViper(), record, table)
 		},
 	},
 
@@ -111,3 +116,11 @@ var Cmd = &command.Command{
 		return nil
 	},
 }
+
+// muxInfo is the machine-readable record for a VPC Mux.
+type muxInfo struct {
+	InterfaceID string `json:"interface-id" yaml:"interface-id"`
+	ListenAddr  string `json:"listen-addr" yaml:"listen-addr"`
+	ListenPort  string `json:"listen-port" yaml:"listen-port"`
+	ListenZone  string `json:"listen-zone,omitempty" yaml:"listen-zone,omitempty"`
+}
@@ -25,29 +25,38 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"path"
+	"strings"
 
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
 	gopsagent "github.com/google/gops/agent"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/agent"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/apply"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/db"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/doc"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/ethlink"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/graph"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/hostif"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/intf"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/list"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/mux"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/shell"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/subnet"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/version"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/vm"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/vmnic"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/vnic"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw"
 	"github.com/joyent/freebsd-vpc/internal/buildtime"
 	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
 	"github.com/joyent/freebsd-vpc/internal/config"
 	"github.com/joyent/freebsd-vpc/internal/logger"
 	"github.com/mattn/go-isatty"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/sean-/conswriter"
 	"github.com/sean-/seed"
@@ -59,17 +68,21 @@ const cmdName = "root"
 
 var subCommands = command.Commands{
 	agent.Cmd,
+	apply.Cmd,
 	db.Cmd,
 	doc.Cmd,
 	ethlink.Cmd,
+	graph.Cmd,
 	intf.Cmd,
 	hostif.Cmd,
 	list.Cmd,
 	mux.Cmd,
 	shell.Cmd,
+	subnet.Cmd,
 	version.Cmd,
 	vm.Cmd,
 	vmnic.Cmd,
+	vnic.Cmd,
 	vpcsw.Cmd,
 }
 
@@ -100,6 +113,18 @@ $ doas vpc switch port remove --switch-id=da64c3f3-095d-91e5-df13-5aabcfc52468 -
 $ doas vpc switch destroy --switch-id=da64c3f3-095d-91e5-df13-5aabcfc52468
 $ vpc list
 `,
+
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Re-run the logger setup now that the command line flags have been
+			// parsed.
+			if err := logger.Setup(viper.GetViper()); err != nil {
+				return errors.Wrap(err, "unable to setup logger")
+			}
+
+			setupTracer()
+
+			return nil
+		},
 	},
 
 	Setup: func(self *command.Command) error {
@@ -137,6 +162,36 @@ $ vpc list
 			viper.SetDefault(key, defaultValue)
 		}
 
+		{
+			const (
+				key          = config.KeyOutputFormat
+				longName     = "output"
+				shortName    = "o"
+				defaultValue = "table"
+			)
+			description := fmt.Sprintf("Output format for list, show, and get commands (%s)", output.FormatsStr())
+
+			flags := self.Cobra.PersistentFlags()
+			flags.StringP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = config.KeyViaAgent
+				longName     = "via-agent"
+				shortName    = ""
+				defaultValue = false
+				description  = "Perform VPC operations through the vpc agent's internal socket"
+			)
+
+			flags := self.Cobra.PersistentFlags()
+			flags.BoolP(longName, shortName, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
 		{
 			const (
 				key          = config.KeyLogLevel
@@ -185,6 +240,36 @@ $ vpc list
 			viper.SetDefault(key, defaultValue)
 		}
 
+		{
+			const (
+				key          = config.KeyLogTraceKBI
+				longOpt      = "trace-kbi"
+				shortOpt     = ""
+				defaultValue = false
+				description  = "Log every VPC kernel interface call (also enabled at debug log level)"
+			)
+
+			flags := self.Cobra.PersistentFlags()
+			flags.BoolP(longOpt, shortOpt, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longOpt))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = config.KeyLogTraceKBIPayload
+				longOpt      = "trace-kbi-payload"
+				shortOpt     = ""
+				defaultValue = false
+				description  = "Include hex-encoded input and output payloads in VPC kernel interface traces"
+			)
+
+			flags := self.Cobra.PersistentFlags()
+			flags.BoolP(longOpt, shortOpt, defaultValue, description)
+			viper.BindPFlag(key, flags.Lookup(longOpt))
+			viper.SetDefault(key, defaultValue)
+		}
+
 		{
 			const (
 				key          = config.KeyUseUTC
@@ -241,6 +326,27 @@ func Execute() error {
 	return nil
 }
 
+// setupTracer installs a KBI tracer when requested via --trace-kbi or when
+// running at the debug log level.
+func setupTracer() {
+	var level zerolog.Level
+	switch {
+	case viper.GetBool(config.KeyLogTraceKBI):
+		level = zerolog.InfoLevel
+	case strings.ToLower(viper.GetString(config.KeyLogLevel)) == logger.LevelDebug.String():
+		level = zerolog.DebugLevel
+	default:
+		vpc.SetTracer(nil)
+		return
+	}
+
+	vpc.SetTracer(vpc.LogTracer{
+		Logger:   log.Logger,
+		Level:    level,
+		Payloads: viper.GetBool(config.KeyLogTraceKBIPayload),
+	})
+}
+
 func init() {
 	// Initialize viper in order to be able to read values from a config file.
 	viper.SetConfigName(buildtime.PROGNAME)
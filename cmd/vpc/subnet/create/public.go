@@ -0,0 +1,126 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package create
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/joyent/freebsd-vpc/internal/ipam"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	cmdName   = "create"
+	_KeyCIDR  = config.KeySubnetCreateCIDR
+	_KeyVPCID = config.KeySubnetCreateVPCID
+)
+
+var Cmd = &command.Command{
+	Name: cmdName,
+
+	Cobra: &cobra.Command{
+		Use:          cmdName,
+		Short:        "create a subnet in a VPC",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cons := conswriter.GetTerminal()
+
+			vpcID, err := flag.GetUUID(viper.GetViper(), _KeyVPCID)
+			if err != nil {
+				return errors.Wrap(err, "unable to get VPC ID")
+			}
+
+			network, err := ipam.ParseSubnet(viper.GetString(_KeyCIDR))
+			if err != nil {
+				return err
+			}
+
+			dbConfig, err := db.ViperConfig()
+			if err != nil {
+				return errors.Wrap(err, "unable to get database config")
+			}
+
+			dbPool, err := db.New(dbConfig)
+			if err != nil {
+				return errors.Wrap(err, "unable to create database pool")
+			}
+			defer dbPool.Close()
+
+			subnet, err := ipam.New(dbPool).CreateSubnet(context.Background(), vpcID, network)
+			if err != nil {
+				return err
+			}
+
+			log.Info().Str("id", subnet.ID.String()).Str("vpc-id", vpcID.String()).Str("network", subnet.Network.String()).Msg("subnet created")
+			cons.Write([]byte(fmt.Sprintf("%s\n", subnet.ID)))
+
+			return nil
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		if err := flag.AddUUID(self, flag.UUIDCfg{
+			Name:        _KeyVPCID,
+			LongName:    "vpc-id",
+			Description: "VPC to create the subnet in",
+			Required:    true,
+		}); err != nil {
+			return errors.Wrap(err, "unable to register VPC ID flag on subnet create")
+		}
+
+		{
+			const (
+				key          = _KeyCIDR
+				longName     = "cidr"
+				shortName    = "c"
+				defaultValue = ""
+				description  = "network of the subnet in CIDR notation (e.g. 10.0.1.0/24 or fd00:1::/64)"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+			self.Cobra.MarkFlagRequired(longName)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package list
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/joyent/freebsd-vpc/internal/ipam"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	cmdName   = "list"
+	_KeyVPCID = config.KeySubnetListVPCID
+)
+
+var Cmd = &command.Command{
+	Name: cmdName,
+
+	Cobra: &cobra.Command{
+		Use:          cmdName,
+		Aliases:      []string{"ls"},
+		Short:        "list the subnets in a VPC",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cons := conswriter.GetTerminal()
+
+			vpcID, err := flag.GetUUID(viper.GetViper(), _KeyVPCID)
+			if err != nil {
+				return errors.Wrap(err, "unable to get VPC ID")
+			}
+
+			dbConfig, err := db.ViperConfig()
+			if err != nil {
+				return errors.Wrap(err, "unable to get database config")
+			}
+
+			dbPool, err := db.New(dbConfig)
+			if err != nil {
+				return errors.Wrap(err, "unable to create database pool")
+			}
+			defer dbPool.Close()
+
+			subnets, err := ipam.New(dbPool).ListSubnets(context.Background(), vpcID)
+			if err != nil {
+				return err
+			}
+
+			table := output.Table{
+				Header:          []string{"id", "network", "address type", "gateway"},
+				ColumnAlignment: []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT},
+				Footer:          []string{"", "", "total", strconv.Itoa(len(subnets))},
+			}
+			records := make([]subnetInfo, 0, len(subnets))
+			for _, subnet := range subnets {
+				info := subnetInfo{
+					ID:          subnet.ID.String(),
+					Network:     subnet.Network.String(),
+					AddressType: subnet.AddressType(),
+					Gateway:     ipam.Gateway(subnet.Network).String(),
+				}
+				records = append(records, info)
+				table.Append(info.ID, info.Network, info.AddressType, info.Gateway)
+			}
+
+			return output.Write(cons, viper.GetViper(), records, table)
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		if err := flag.AddUUID(self, flag.UUIDCfg{
+			Name:        _KeyVPCID,
+			LongName:    "vpc-id",
+			Description: "VPC to list the subnets of",
+			Required:    true,
+		}); err != nil {
+			return errors.Wrap(err, "unable to register VPC ID flag on subnet list")
+		}
+
+		return nil
+	},
+}
+
+type subnetInfo struct {
+	ID          string `json:"id" yaml:"id"`
+	Network     string `json:"network" yaml:"network"`
+	AddressType string `json:"address-type" yaml:"address-type"`
+	Gateway     string `json:"gateway" yaml:"gateway"`
+}
@@ -25,13 +25,13 @@
 package create
 
 import (
-	"context"
-	"net"
-	"net/http"
-
+	"github.com/joyent/freebsd-vpc/agent/api"
 	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 const cmdName = "create"
@@ -50,20 +50,14 @@ var Cmd = &command.Command{
 		RunE: func(cmd *cobra.Command, args []string) error {
 			log.Info().Str("command", "vm").Msg("")
 
-			client := http.Client{
-				Transport: &http.Transport{
-					DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-						return net.Dial("unix", "/tmp/vpc-agent.sock")
-					},
-				},
-			}
+			client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
 
-			resp, err := client.Get("http://unix/test")
+			resp, err := client.Ping()
 			if err != nil {
-				log.Fatal().Err(err).Msg("error making request")
+				return errors.Wrap(err, "unable to reach agent")
 			}
 
-			log.Info().Int("code", resp.StatusCode).Msg("got response")
+			log.Info().Str("version", resp.Version).Msg("got response")
 
 			return nil
 		},
@@ -28,10 +28,14 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vmnic"
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpctest"
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/joyent/freebsd-vpc/db"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/maclease"
 	"github.com/joyent/freebsd-vpc/internal/config"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -41,9 +45,11 @@ import (
 )
 
 const (
-	cmdName     = "create"
-	keyVMNICID  = config.KeyVMNICCreateID
-	keyVMNICMAC = config.KeyVMNICCreateMAC
+	cmdName      = "create"
+	keyAccountID = config.KeyVMNICCreateAccountID
+	keySubnetID  = config.KeyVMNICCreateSubnetID
+	keyVMNICID   = config.KeyVMNICCreateID
+	keyVMNICMAC  = config.KeyVMNICCreateMAC
 )
 
 var Cmd = &command.Command{
@@ -74,11 +80,51 @@ var Cmd = &command.Command{
 				return errors.Wrap(err, "unable to get VPC ID")
 			}
 
-			mac, err := flag.GetMAC(viper.GetViper(), keyVMNICMAC, &id)
-			if err != nil {
+			var mac net.HardwareAddr
+			// created is set once the interface exists so that a leased MAC
+			// is kept even if a later step fails.
+			var created bool
+			if viper.GetString(keyAccountID) != "" {
+				// The VM NIC's MAC address is the Node of its ID, so lease
+				// the MAC of the requested ID (or --mac) if there is one and
+				// derive the ID from a newly generated MAC otherwise.
+				var requested net.HardwareAddr
+				switch {
+				case viper.GetString(keyVMNICMAC) != "":
+					if requested, err = flag.GetMAC(viper.GetViper(), keyVMNICMAC, nil); err != nil {
+						return errors.Wrap(err, "unable to get MAC address")
+					}
+				case viper.GetString(keyVMNICID) != "":
+					requested = id.Node[:]
+				}
+
+				var finish func(created bool)
+				if mac, finish, err = maclease.Lease(viper.GetViper(), keyAccountID, keySubnetID, requested); err != nil {
+					return errors.Wrap(err, "unable to lease MAC address")
+				}
+				defer func() { finish(created) }()
+
+				if viper.GetString(keyVMNICID) == "" {
+					id = vpc.GenID(vpc.ObjTypeNICVM)
+					copy(id.Node[:], mac)
+				}
+			} else if mac, err = flag.GetMAC(viper.GetViper(), keyVMNICMAC, &id); err != nil {
 				return errors.Wrap(err, "unable to get MAC address")
 			}
 
+			if viper.GetBool(config.KeyViaAgent) {
+				client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
+				if err := client.VMNICCreate(api.VMNICCreateRequest{ID: id.String(), MAC: mac.String()}); err != nil {
+					return errors.Wrap(err, "unable to create VM NIC via agent")
+				}
+				created = true
+
+				cons.Write([]byte("done.\n"))
+				log.Info().Object("vmnic-id", id).Msg("VM NIC created via agent")
+
+				return nil
+			}
+
 			vmnicCfg := vmnic.Config{
 				ID:  id,
 				MAC: mac,
@@ -95,6 +141,7 @@ var Cmd = &command.Command{
 				log.Error().Err(err).Object("vmnic-id", id).Msg("VM NIC commit failed")
 				return errors.Wrap(err, "unable to commit VM NIC")
 			}
+			created = true
 
 			cons.Write([]byte("done.\n"))
 
@@ -106,10 +153,9 @@ var Cmd = &command.Command{
 				}
 				_, newIfaces, _ := existingIfaces.Difference(ifacesAfterCreate)
 
-				var newVMNICMAC net.HardwareAddr = id.Node[:]
-				newVMNIC, err = newIfaces.FindMAC(newVMNICMAC)
+				newVMNIC, err = newIfaces.FindMAC(mac)
 				if err != nil {
-					return errors.Wrapf(err, "unable to find new VM NIC with MAC %q", id.Node)
+					return errors.Wrapf(err, "unable to find new VM NIC with MAC %q", mac)
 				}
 			}
 
@@ -128,6 +174,10 @@ var Cmd = &command.Command{
 			return errors.Wrap(err, "unable to register MAC flag on VM NIC create")
 		}
 
-		return nil
+		if err := flag.AddMACAllocation(self, keyAccountID, keySubnetID); err != nil {
+			return errors.Wrap(err, "unable to register MAC allocation flags on VM NIC create")
+		}
+
+		return db.SetDefaultViperOptions()
 	},
 }
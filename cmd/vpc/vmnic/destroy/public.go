@@ -26,10 +26,14 @@ package destroy
 
 import (
 	"fmt"
+	"net"
 
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vmnic"
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/joyent/freebsd-vpc/db"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/maclease"
 	"github.com/joyent/freebsd-vpc/internal/config"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -39,8 +43,9 @@ import (
 )
 
 const (
-	cmdName    = "destroy"
-	keyVMNICID = config.KeyVMNICDestroyID
+	cmdName      = "destroy"
+	keyAccountID = config.KeyVMNICDestroyAccountID
+	keyVMNICID   = config.KeyVMNICDestroyID
 )
 
 var Cmd = &command.Command{
@@ -65,7 +70,15 @@ var Cmd = &command.Command{
 			return errors.Wrap(err, "unable to register VM NIC ID flag on VPC Switch destroy")
 		}
 
-		return nil
+		if err := flag.AddUUID(self, flag.UUIDCfg{
+			Name:        keyAccountID,
+			LongName:    "account-id",
+			Description: "release the MAC address to this account's MAC allocator",
+		}); err != nil {
+			return errors.Wrap(err, "unable to register account ID flag on VM NIC destroy")
+		}
+
+		return db.SetDefaultViperOptions()
 	},
 }
 
@@ -79,6 +92,24 @@ func runE(cmd *cobra.Command, args []string) error {
 		return errors.Wrap(err, "unable to get VPC ID")
 	}
 
+	if viper.GetBool(config.KeyViaAgent) {
+		client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
+		resp, err := client.VMNICDestroy(id.String())
+		if err != nil {
+			return errors.Wrap(err, "unable to destroy VM NIC via agent")
+		}
+
+		mac, err := net.ParseMAC(resp.MAC)
+		if err != nil {
+			return errors.Wrap(err, "unable to parse VM NIC MAC address returned by agent")
+		}
+
+		cons.Write([]byte("done.\n"))
+		log.Info().Object("vmnic-id", id).Str("mac", mac.String()).Msg("VM NIC destroyed via agent")
+
+		return releaseMAC(mac)
+	}
+
 	vmnicCfg := vmnic.Config{
 		ID:        id,
 		Writeable: true,
@@ -93,11 +124,30 @@ func runE(cmd *cobra.Command, args []string) error {
 	}
 	defer vmNIC.Close()
 
+	mac, err := vmNIC.MAC()
+	if err != nil {
+		return errors.Wrap(err, "unable to get VM NIC MAC address")
+	}
+
 	if err := vmNIC.Destroy(); err != nil {
 		return errors.Wrap(err, "unable to destroy VM NIC")
 	}
 
 	cons.Write([]byte("done.\n"))
 
+	return releaseMAC(mac)
+}
+
+// releaseMAC releases the MAC address of the destroyed VM NIC when
+// --account-id is given.
+func releaseMAC(mac net.HardwareAddr) error {
+	if viper.GetString(keyAccountID) == "" {
+		return nil
+	}
+
+	if err := maclease.Release(viper.GetViper(), keyAccountID, mac); err != nil {
+		return errors.Wrap(err, "unable to release MAC address")
+	}
+
 	return nil
 }
@@ -30,6 +30,8 @@ import (
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vmnic"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/meta"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
 	"github.com/joyent/freebsd-vpc/internal/config"
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
@@ -40,10 +42,14 @@ import (
 
 const (
 	cmdName       = "get"
+	keyGetMAC     = config.KeyVMNICGetMAC
+	keyGetMTU     = config.KeyVMNICGetMTU
 	keyGetNQueues = config.KeyVMNICGetNQueues
 	keyVMNICID    = config.KeyVMNICGetVMNICID
 )
 
+var metaKeys = meta.Keys{MAC: keyGetMAC, MTU: keyGetMTU}
+
 var Cmd = &command.Command{
 	Name: cmdName,
 
@@ -59,18 +65,10 @@ var Cmd = &command.Command{
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cons := conswriter.GetTerminal()
 
-			table := tablewriter.NewWriter(cons)
-			table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-			table.SetHeaderLine(false)
-			table.SetAutoFormatHeaders(true)
-
-			table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
-			table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-			table.SetCenterSeparator("")
-			table.SetColumnSeparator("")
-			table.SetRowSeparator("")
-
-			table.SetHeader([]string{"id", "key", "value"})
+			table := output.Table{
+				Header:          []string{"id", "key", "value"},
+				ColumnAlignment: []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT},
+			}
 
 			id, err := flag.GetID(viper.GetViper(), keyVMNICID)
 			if err != nil {
@@ -86,22 +84,31 @@ var Cmd = &command.Command{
 			}
 			defer vmn.Close()
 
+			attrs, err := meta.Get(viper.GetViper(), metaKeys, id, vmn, &table)
+			if err != nil {
+				return errors.Wrap(err, "unable to get VM NIC attributes")
+			}
+
+			record := vmnicInfo{
+				ID:    id.String(),
+				Attrs: attrs,
+			}
+
 			if viper.GetBool(keyGetNQueues) {
 				numQueues, err := vmn.NQueuesGet()
 				if err != nil {
 					return errors.Wrapf(err, "unable to get the number of hardware queues")
 				}
 
-				table.Append([]string{
+				record.NumQueues = &numQueues
+				table.Append(
 					id.String(),
 					"num-queues",
 					strconv.FormatInt(int64(numQueues), 10),
-				})
+				)
 			}
 
-			table.Render()
-
-			return nil
+			return output.Write(cons, viper.GetViper(), record, table)
 		},
 	},
 
@@ -126,6 +133,17 @@ var Cmd = &command.Command{
 			return errors.Wrap(err, "unable to register VMNIC ID flag on VM NIC get")
 		}
 
+		if err := meta.AddGetFlags(self, metaKeys); err != nil {
+			return errors.Wrap(err, "unable to register meta flags on VM NIC get")
+		}
+
 		return nil
 	},
 }
+
+// vmnicInfo is the machine-readable record for the attributes of a VM NIC.
+type vmnicInfo struct {
+	ID         string  `json:"id" yaml:"id"`
+	NumQueues  *uint16 `json:"num-queues,omitempty" yaml:"num-queues,omitempty"`
+	meta.Attrs `yaml:",inline"`
+}
@@ -26,8 +26,10 @@ package set
 
 import (
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vmnic"
+	"github.com/joyent/freebsd-vpc/agent/api"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/meta"
 	"github.com/joyent/freebsd-vpc/internal/config"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -37,11 +39,15 @@ import (
 const (
 	cmdName        = "set"
 	keySetFreeze   = config.KeyVMNICSetFreeze
+	keySetMAC      = config.KeyVMNICSetMAC
+	keySetMTU      = config.KeyVMNICSetMTU
 	keySetNQueues  = config.KeyVMNICSetNQueues
 	keySetUnfreeze = config.KeyVMNICSetUnfreeze
 	keyVMNICID     = config.KeyVMNICSetVMNICID
 )
 
+var metaKeys = meta.Keys{MAC: keySetMAC, MTU: keySetMTU}
+
 var Cmd = &command.Command{
 	Name: cmdName,
 
@@ -60,6 +66,24 @@ var Cmd = &command.Command{
 				return errors.Wrap(err, "unable to get VM NIC ID")
 			}
 
+			if viper.GetBool(config.KeyViaAgent) {
+				client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
+				req := api.VMNICSetRequest{
+					ID:       id.String(),
+					Freeze:   viper.GetBool(keySetFreeze),
+					Unfreeze: viper.GetBool(keySetUnfreeze),
+				}
+				if numQueues := viper.GetInt(keySetNQueues); numQueues > 0 {
+					req.NumQueues = uint16(numQueues)
+				}
+
+				if err := client.VMNICSet(req); err != nil {
+					return errors.Wrap(err, "unable to set VM NIC attributes via agent")
+				}
+
+				return nil
+			}
+
 			vmnicCfg := vmnic.Config{
 				ID: id,
 			}
@@ -75,6 +99,10 @@ var Cmd = &command.Command{
 				}
 			}
 
+			if err := meta.Set(viper.GetViper(), metaKeys, vmn); err != nil {
+				return errors.Wrap(err, "unable to set VM NIC attributes")
+			}
+
 			if numQueues := viper.GetInt(keySetNQueues); numQueues > 0 {
 				if err := vmn.NQueuesSet(uint16(numQueues)); err != nil {
 					return errors.Wrapf(err, "unable to set the number of hardware queues")
@@ -96,6 +124,10 @@ var Cmd = &command.Command{
 			return errors.Wrap(err, "unable to register VM NIC ID flag on VM NIC set")
 		}
 
+		if err := meta.AddSetFlags(self, metaKeys); err != nil {
+			return errors.Wrap(err, "unable to register meta flags on VM NIC set")
+		}
+
 		{
 			const (
 				key          = keySetNQueues
@@ -0,0 +1,143 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package add
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/joyent/freebsd-vpc/internal/ipam"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	cmdName      = "add"
+	_KeyIP       = config.KeyVNICIPAddIP
+	_KeySubnetID = config.KeyVNICIPAddSubnetID
+	_KeyVNICID   = config.KeyVNICIPAddVNICID
+)
+
+var Cmd = &command.Command{
+	Name: cmdName,
+
+	Cobra: &cobra.Command{
+		Use:          cmdName,
+		Short:        "assign a subnet address to a VNIC",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cons := conswriter.GetTerminal()
+
+			vnicID, err := flag.GetUUID(viper.GetViper(), _KeyVNICID)
+			if err != nil {
+				return errors.Wrap(err, "unable to get VNIC ID")
+			}
+
+			subnetID, err := flag.GetUUID(viper.GetViper(), _KeySubnetID)
+			if err != nil {
+				return errors.Wrap(err, "unable to get subnet ID")
+			}
+
+			var ip net.IP
+			if ipStr := viper.GetString(_KeyIP); ipStr != "" {
+				if ip = net.ParseIP(ipStr); ip == nil {
+					return errors.Errorf("unable to parse IP %q", ipStr)
+				}
+			}
+
+			dbConfig, err := db.ViperConfig()
+			if err != nil {
+				return errors.Wrap(err, "unable to get database config")
+			}
+
+			dbPool, err := db.New(dbConfig)
+			if err != nil {
+				return errors.Wrap(err, "unable to create database pool")
+			}
+			defer dbPool.Close()
+
+			a, err := ipam.New(dbPool).AssignIP(context.Background(), vnicID, subnetID, ip)
+			if err != nil {
+				return err
+			}
+
+			log.Info().Str("vnic-id", vnicID.String()).Str("ip-id", a.IP.ID.String()).Str("ip", a.IP.IP.String()).Int("index", a.Index).Msg("address assigned")
+			cons.Write([]byte(fmt.Sprintf("%s\n", a.IP.IP)))
+
+			return nil
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		if err := flag.AddUUID(self, flag.UUIDCfg{
+			Name:        _KeyVNICID,
+			LongName:    "vnic-id",
+			Description: "VNIC to assign the address to",
+			Required:    true,
+		}); err != nil {
+			return errors.Wrap(err, "unable to register VNIC ID flag on VNIC IP add")
+		}
+
+		if err := flag.AddUUID(self, flag.UUIDCfg{
+			Name:        _KeySubnetID,
+			LongName:    "subnet-id",
+			Description: "subnet to allocate the address from",
+			Required:    true,
+		}); err != nil {
+			return errors.Wrap(err, "unable to register subnet ID flag on VNIC IP add")
+		}
+
+		{
+			const (
+				key          = _KeyIP
+				longName     = "ip"
+				shortName    = ""
+				defaultValue = ""
+				description  = "address to assign (default: the lowest free address in the subnet)"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package list
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/joyent/freebsd-vpc/internal/ipam"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	cmdName    = "list"
+	_KeyVNICID = config.KeyVNICIPListVNICID
+)
+
+var Cmd = &command.Command{
+	Name: cmdName,
+
+	Cobra: &cobra.Command{
+		Use:          cmdName,
+		Aliases:      []string{"ls"},
+		Short:        "list the addresses assigned to a VNIC",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cons := conswriter.GetTerminal()
+
+			vnicID, err := flag.GetUUID(viper.GetViper(), _KeyVNICID)
+			if err != nil {
+				return errors.Wrap(err, "unable to get VNIC ID")
+			}
+
+			dbConfig, err := db.ViperConfig()
+			if err != nil {
+				return errors.Wrap(err, "unable to get database config")
+			}
+
+			dbPool, err := db.New(dbConfig)
+			if err != nil {
+				return errors.Wrap(err, "unable to create database pool")
+			}
+			defer dbPool.Close()
+
+			assignments, err := ipam.New(dbPool).ListAssignments(context.Background(), vnicID)
+			if err != nil {
+				return err
+			}
+
+			table := output.Table{
+				Header:          []string{"index", "ip", "ip id", "subnet id"},
+				ColumnAlignment: []int{tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT},
+			}
+			records := make([]vnicIP, 0, len(assignments))
+			for _, a := range assignments {
+				record := vnicIP{
+					Index:    a.Index,
+					IP:       a.IP.IP.String(),
+					IPID:     a.IP.ID.String(),
+					SubnetID: a.IP.SubnetID.String(),
+				}
+				records = append(records, record)
+				table.Append(strconv.Itoa(record.Index), record.IP, record.IPID, record.SubnetID)
+			}
+
+			return output.Write(cons, viper.GetViper(), records, table)
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		if err := flag.AddUUID(self, flag.UUIDCfg{
+			Name:        _KeyVNICID,
+			LongName:    "vnic-id",
+			Description: "VNIC to list the addresses of",
+			Required:    true,
+		}); err != nil {
+			return errors.Wrap(err, "unable to register VNIC ID flag on VNIC IP list")
+		}
+
+		return nil
+	},
+}
+
+type vnicIP struct {
+	Index    int    `json:"index" yaml:"index"`
+	IP       string `json:"ip" yaml:"ip"`
+	IPID     string `json:"ip-id" yaml:"ip-id"`
+	SubnetID string `json:"subnet-id" yaml:"subnet-id"`
+}
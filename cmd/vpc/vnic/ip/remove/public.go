@@ -0,0 +1,113 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package remove
+
+import (
+	"context"
+
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/joyent/freebsd-vpc/internal/ipam"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	cmdName    = "remove"
+	_KeyIPID   = config.KeyVNICIPRemoveIPID
+	_KeyVNICID = config.KeyVNICIPRemoveVNICID
+)
+
+var Cmd = &command.Command{
+	Name: cmdName,
+
+	Cobra: &cobra.Command{
+		Use:          cmdName,
+		Aliases:      []string{"rm"},
+		Short:        "remove an address from a VNIC and free it",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			vnicID, err := flag.GetUUID(viper.GetViper(), _KeyVNICID)
+			if err != nil {
+				return errors.Wrap(err, "unable to get VNIC ID")
+			}
+
+			ipID, err := flag.GetUUID(viper.GetViper(), _KeyIPID)
+			if err != nil {
+				return errors.Wrap(err, "unable to get IP ID")
+			}
+
+			dbConfig, err := db.ViperConfig()
+			if err != nil {
+				return errors.Wrap(err, "unable to get database config")
+			}
+
+			dbPool, err := db.New(dbConfig)
+			if err != nil {
+				return errors.Wrap(err, "unable to create database pool")
+			}
+			defer dbPool.Close()
+
+			if err := ipam.New(dbPool).UnassignIP(context.Background(), vnicID, ipID); err != nil {
+				return err
+			}
+
+			log.Info().Str("vnic-id", vnicID.String()).Str("ip-id", ipID.String()).Msg("address removed")
+
+			return nil
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		if err := flag.AddUUID(self, flag.UUIDCfg{
+			Name:        _KeyVNICID,
+			LongName:    "vnic-id",
+			Description: "VNIC to remove the address from",
+			Required:    true,
+		}); err != nil {
+			return errors.Wrap(err, "unable to register VNIC ID flag on VNIC IP remove")
+		}
+
+		if err := flag.AddUUID(self, flag.UUIDCfg{
+			Name:        _KeyIPID,
+			LongName:    "ip-id",
+			Description: "address to remove (see \"vnic ip list\")",
+			Required:    true,
+		}); err != nil {
+			return errors.Wrap(err, "unable to register IP ID flag on VNIC IP remove")
+		}
+
+		return nil
+	},
+}
@@ -1,8 +1,3 @@
-// Go interface to VPC syscalls on *NIX-like systems.
-//
-// SPDX-License-Identifier: BSD-2-Clause-FreeBSD
-//
-// Copyright (C) 2018 Sean Chittenden <seanc@joyent.com>
 // Copyright (c) 2018 Joyent, Inc.
 // All rights reserved.
 //
@@ -27,22 +22,34 @@
 // OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
 // SUCH DAMAGE.
 
-// +build android darwin dragonfly freebsd linux nacl netbsd openbsd plan9 solaris
-
-package vpc
+package vnic
 
 import (
+	"github.com/joyent/freebsd-vpc/cmd/vpc/vnic/ip"
+	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/pkg/errors"
-	"golang.org/x/sys/unix"
+	"github.com/spf13/cobra"
 )
 
-func (h *Handle) closeHandle() error {
-	// TODO(seanc@): verify that we don't need to wrap this close in a loop
-	if err := unix.Close(int(h.fd)); err != nil {
-		return errors.Wrap(err, "unable to close VPC handle")
-	}
+const cmdName = "vnic"
+
+var Cmd = &command.Command{
+	Name: cmdName,
+
+	Cobra: &cobra.Command{
+		Use:   cmdName,
+		Short: "VPC virtual NIC management",
+	},
+
+	Setup: func(self *command.Command) error {
+		subCommands := command.Commands{
+			ip.Cmd,
+		}
 
-	h.fd = HandleClosedFD
+		if err := self.Register(subCommands); err != nil {
+			return errors.Wrapf(err, "unable to register sub-commands under %s", cmdName)
+		}
 
-	return nil
+		return nil
+	},
 }
@@ -25,25 +25,37 @@
 package create
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"strconv"
 
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcsw"
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpctest"
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/joyent/freebsd-vpc/db"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
 	"github.com/joyent/freebsd-vpc/internal/config"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	uuid "github.com/satori/go.uuid"
 	"github.com/sean-/conswriter"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 const (
-	_CmdName      = "create"
-	_KeySwitchID  = config.KeySWCreateSwitchID
-	_KeySwitchMAC = config.KeySWCreateSwitchMAC
+	_CmdName       = "create"
+	_KeyFacilityID = config.KeySWCreateFacilityID
+	_KeySwitchID   = config.KeySWCreateSwitchID
+	_KeySwitchMAC  = config.KeySWCreateSwitchMAC
+	_KeyVNI        = config.KeySWCreateVNI
+	_KeyVPCID      = config.KeySWCreateVPCID
+
+	// _VNIAuto is the --vni value that leases a VNI from the database.
+	_VNIAuto = "auto"
 )
 
 var Cmd = &command.Command{
@@ -55,6 +67,14 @@ var Cmd = &command.Command{
 		SilenceUsage: true,
 		Args:         cobra.NoArgs,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if viper.GetString(_KeyVNI) != _VNIAuto {
+				return nil
+			}
+
+			if viper.GetString(_KeyFacilityID) == "" || viper.GetString(_KeyVPCID) == "" {
+				return errors.Errorf("--vni=%s requires --facility-id and --vpc-id", _VNIAuto)
+			}
+
 			return nil
 		},
 
@@ -78,9 +98,42 @@ var Cmd = &command.Command{
 				return errors.Wrap(err, "unable to get MAC address")
 			}
 
+			// created is set once the switch exists so that a leased VNI is
+			// kept even if a later step fails.
+			var created bool
+			var vni vpc.VNI
+			switch vniStr := viper.GetString(_KeyVNI); vniStr {
+			case _VNIAuto:
+				var finish func(created bool)
+				if vni, finish, err = leaseVNI(); err != nil {
+					return errors.Wrap(err, "unable to lease VNI")
+				}
+				defer func() { finish(created) }()
+			default:
+				n, err := strconv.ParseUint(vniStr, 10, 32)
+				if err != nil || vpc.VNI(n) > vpc.VNIMax {
+					return errors.Errorf("invalid VNI %q: must be %s or between %d and %d", vniStr, _VNIAuto, vpc.VNIMin, vpc.VNIMax)
+				}
+				vni = vpc.VNI(n)
+			}
+
+			if viper.GetBool(config.KeyViaAgent) {
+				client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
+				if err := client.SwitchCreate(api.SwitchCreateRequest{ID: id.String(), MAC: mac.String(), VNI: uint32(vni)}); err != nil {
+					return errors.Wrap(err, "unable to create VPC Switch via agent")
+				}
+				created = true
+
+				cons.Write([]byte("done.\n"))
+				log.Info().Str("id", id.String()).Uint32("vni", uint32(vni)).Msg("vpcsw created via agent")
+
+				return nil
+			}
+
 			switchCfg := vpcsw.Config{
 				ID:  id,
 				MAC: mac,
+				VNI: vni,
 			}
 
 			vpcSwitch, err := vpcsw.Create(switchCfg)
@@ -94,6 +147,7 @@ var Cmd = &command.Command{
 				log.Error().Err(err).Str("id", id.String()).Msg("vpcsw commit failed")
 				return errors.Wrap(err, "unable to commit VPC Switch")
 			}
+			created = true
 
 			cons.Write([]byte("done.\n"))
 
@@ -112,7 +166,7 @@ var Cmd = &command.Command{
 				}
 			}
 
-			log.Info().Str("id", id.String()).Str("mac", newSwitch.HardwareAddr.String()).Str("name", newSwitch.Name).Msg("vpcsw created")
+			log.Info().Str("id", id.String()).Str("mac", newSwitch.HardwareAddr.String()).Str("name", newSwitch.Name).Uint32("vni", uint32(vni)).Msg("vpcsw created")
 
 			return nil
 		},
@@ -127,6 +181,103 @@ var Cmd = &command.Command{
 			return errors.Wrap(err, "unable to register MAC flag on VPC Switch create")
 		}
 
-		return nil
+		{
+			const (
+				key          = _KeyVNI
+				longName     = "vni"
+				shortName    = ""
+				defaultValue = "0"
+				description  = "VNI of the VPC Switch, or \"" + _VNIAuto + "\" to lease one from the database"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = _KeyFacilityID
+				longName     = "facility-id"
+				shortName    = ""
+				defaultValue = ""
+				description  = "facility to lease the VNI in when --vni=" + _VNIAuto
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		{
+			const (
+				key          = _KeyVPCID
+				longName     = "vpc-id"
+				shortName    = ""
+				defaultValue = ""
+				description  = "VPC to lease the VNI to when --vni=" + _VNIAuto
+			)
+
+			flags := self.Cobra.Flags()
+			flags.StringP(longName, shortName, defaultValue, description)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		return db.SetDefaultViperOptions()
 	},
 }
+
+// leaseVNI leases a VNI from the database for the facility and VPC given on
+// the command line.  finish must be called once the switch has been created
+// (or not): if created is false the lease is canceled and the VNI can be
+// leased again immediately.  The VNI of a destroyed switch is released with
+// vpc switch destroy --facility-id.
+func leaseVNI() (vni vpc.VNI, finish func(created bool), err error) {
+	facilityID, err := uuid.FromString(viper.GetString(_KeyFacilityID))
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "unable to parse facility ID")
+	}
+
+	vpcID, err := uuid.FromString(viper.GetString(_KeyVPCID))
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "unable to parse VPC ID")
+	}
+
+	dbConfig, err := db.ViperConfig()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	dbPool, err := db.New(dbConfig)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "unable to create database pool")
+	}
+
+	ctx := context.Background()
+	alloc := db.NewVNIAllocator(dbPool)
+	if vni, err = alloc.Lease(ctx, facilityID, vpcID); err != nil {
+		dbPool.Close()
+		return 0, nil, err
+	}
+	log.Info().Str("facility-id", facilityID.String()).Str("vpc-id", vpcID.String()).Uint32("vni", uint32(vni)).Msg("leased VNI")
+
+	finish = func(created bool) {
+		defer dbPool.Close()
+
+		if created {
+			return
+		}
+
+		if err := alloc.Cancel(ctx, facilityID, vni); err != nil {
+			log.Warn().Err(err).Uint32("vni", uint32(vni)).Msg("unable to cancel VNI lease")
+		}
+	}
+
+	return vni, finish, nil
+}
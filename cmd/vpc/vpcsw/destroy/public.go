@@ -25,9 +25,13 @@
 package destroy
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcsw"
+	"github.com/joyent/freebsd-vpc/agent/api"
+	"github.com/joyent/freebsd-vpc/db"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
 	"github.com/joyent/freebsd-vpc/internal/config"
@@ -39,8 +43,10 @@ import (
 )
 
 const (
-	_CmdName     = "destroy"
-	_KeySwitchID = config.KeySWDestroySwitchID
+	_CmdName       = "destroy"
+	_KeyFacilityID = config.KeySWDestroyFacilityID
+	_KeySwitchID   = config.KeySWDestroySwitchID
+	_KeyVNI        = config.KeySWDestroyVNI
 )
 
 var Cmd = &command.Command{
@@ -54,6 +60,10 @@ var Cmd = &command.Command{
 		Args:             cobra.NoArgs,
 
 		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if viper.GetString(_KeyFacilityID) != "" && viper.GetInt(_KeyVNI) == 0 {
+				return errors.New("--facility-id requires --vni")
+			}
+
 			return nil
 		},
 
@@ -65,7 +75,19 @@ var Cmd = &command.Command{
 			return errors.Wrap(err, "unable to register ID flag on VPC Switch destroy")
 		}
 
-		return nil
+		if err := flag.AddUUID(self, flag.UUIDCfg{
+			Name:        _KeyFacilityID,
+			LongName:    "facility-id",
+			Description: "facility to release the VNI of the VPC Switch in",
+		}); err != nil {
+			return errors.Wrap(err, "unable to register facility ID flag on VPC Switch destroy")
+		}
+
+		if err := flag.AddVNI(self, flag.VNICfg{Name: _KeyVNI}); err != nil {
+			return errors.Wrap(err, "unable to register VNI flag on VPC Switch destroy")
+		}
+
+		return db.SetDefaultViperOptions()
 	},
 }
 
@@ -79,6 +101,18 @@ func runE(cmd *cobra.Command, args []string) error {
 		return errors.Wrap(err, "unable to get VPC ID")
 	}
 
+	if viper.GetBool(config.KeyViaAgent) {
+		client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
+		if err := client.SwitchDestroy(id.String()); err != nil {
+			return errors.Wrap(err, "unable to destroy VPC Switch via agent")
+		}
+
+		cons.Write([]byte("done.\n"))
+		log.Info().Str("id", id.String()).Msg("vpcsw destroyed via agent")
+
+		return releaseVNI()
+	}
+
 	switchCfg := vpcsw.Config{
 		ID:        id,
 		Writeable: true,
@@ -98,5 +132,38 @@ func runE(cmd *cobra.Command, args []string) error {
 
 	cons.Write([]byte("done.\n"))
 
+	return releaseVNI()
+}
+
+// releaseVNI releases the VNI leased to the destroyed switch with vpc switch
+// create --vni=auto when --facility-id is given.  The VNI is tombstoned and is
+// not leased again until it expires.
+func releaseVNI() error {
+	if viper.GetString(_KeyFacilityID) == "" {
+		return nil
+	}
+
+	facilityID, err := flag.GetUUID(viper.GetViper(), _KeyFacilityID)
+	if err != nil {
+		return errors.Wrap(err, "unable to get facility ID")
+	}
+
+	dbConfig, err := db.ViperConfig()
+	if err != nil {
+		return err
+	}
+
+	dbPool, err := db.New(dbConfig)
+	if err != nil {
+		return errors.Wrap(err, "unable to create database pool")
+	}
+	defer dbPool.Close()
+
+	vni := vpc.VNI(viper.GetInt(_KeyVNI))
+	if err := db.NewVNIAllocator(dbPool).Release(context.Background(), facilityID, vni); err != nil {
+		return errors.Wrap(err, "unable to release VNI")
+	}
+	log.Info().Str("facility-id", facilityID.String()).Uint32("vni", uint32(vni)).Msg("released VNI")
+
 	return nil
 }
@@ -30,10 +30,12 @@ import (
 
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpctest"
 	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
 	"github.com/sean-/conswriter"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 const (
@@ -61,40 +63,30 @@ var Cmd = &command.Command{
 				return errors.Wrapf(err, "unable to get all interfaces")
 			}
 
-			table := tablewriter.NewWriter(cons)
-			table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-			table.SetHeaderLine(false)
-			table.SetAutoFormatHeaders(true)
-
-			table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
-			table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-			table.SetCenterSeparator("")
-			table.SetColumnSeparator("")
-			table.SetRowSeparator("")
-
-			table.SetHeader([]string{"name", "index", "mtu", "mac", "flags"})
+			table := output.Table{
+				Header:          []string{"name", "index", "mtu", "mac", "flags"},
+				ColumnAlignment: []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT},
+			}
 
-			var numInterfaces int64
+			records := []output.Interface{}
 			for _, iface := range existingIfaces {
 				if !strings.HasPrefix(iface.Name, "vpcsw") {
 					continue
 				}
 
-				table.Append([]string{
+				records = append(records, output.NewInterface(iface))
+				table.Append(
 					iface.Name,
 					strconv.FormatInt(int64(iface.Index), 10),
 					strconv.FormatInt(int64(iface.MTU), 10),
 					iface.HardwareAddr.String(),
 					iface.Flags.String(),
-				})
-				numInterfaces++
+				)
 			}
 
-			table.SetFooter([]string{"total", strconv.FormatInt(numInterfaces, 10), "", "", ""})
+			table.Footer = []string{"total", strconv.FormatInt(int64(len(records)), 10), "", "", ""}
 
-			table.Render()
-
-			return nil
+			return output.Write(cons, viper.GetViper(), records, table)
 		},
 	},
 
@@ -28,6 +28,8 @@ import (
 	"fmt"
 
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcsw"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpctxn"
+	"github.com/joyent/freebsd-vpc/agent/api"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
 	"github.com/joyent/freebsd-vpc/internal/config"
@@ -80,28 +82,20 @@ var Cmd = &command.Command{
 				return errors.Wrap(err, "unable to get MAC address")
 			}
 
-			// Create a stack of commit and undo operations to walk through in the
-			// event of an error.
-			var commit bool
-			var commitFuncs, undoFuncs []func() error
-			defer func() {
-				scopeHandlers := undoFuncs
-				modeStr := "undo"
-				if commit {
-					modeStr = "commit"
-					scopeHandlers = commitFuncs
+			if viper.GetBool(config.KeyViaAgent) {
+				client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
+				if err := client.PortAdd(api.PortRequest{SwitchID: switchID.String(), PortID: portID.String(), MAC: portMAC.String()}); err != nil {
+					return errors.Wrap(err, "unable to add a port to VPC Switch via agent")
 				}
 
-				for i := len(scopeHandlers) - 1; i >= 0; i-- {
-					if err := scopeHandlers[i](); err != nil {
-						log.Error().Err(err).Msgf("failure during %s", modeStr)
-					}
-				}
-			}()
-			commitFuncs = append(commitFuncs, func() error {
 				cons.Write([]byte("done.\n"))
+				log.Info().Object("port-id", portID).Str("switch-id", switchID.String()).Msg("vpcp created via agent")
+
 				return nil
-			})
+			}
+
+			txn := vpctxn.New()
+			defer txn.Finish(&err)
 
 			// 1) Open switch and add a port
 			switchCfg := vpcsw.Config{
@@ -109,28 +103,13 @@ var Cmd = &command.Command{
 				Writeable: true,
 			}
 
-			vpcSwitch, err := vpcsw.Open(switchCfg)
+			vpcSwitch, err := txn.OpenSwitch(switchCfg)
 			if err != nil {
 				log.Error().Err(err).Object("switch-cfg", switchCfg).Msg("vpcsw open failed")
 				return errors.Wrap(err, "unable to open VPC Switch")
 			}
-			commitFuncs = append(commitFuncs, func() error {
-				if err := vpcSwitch.Close(); err != nil {
-					log.Error().Err(err).Msg("unable to commit VPC Switch")
-					return errors.Wrap(err, "unable to commit VPC switch during operation commit")
-				}
-
-				return nil
-			})
-			undoFuncs = append(undoFuncs, func() error {
-				if err := vpcSwitch.Close(); err != nil {
-					log.Error().Err(err).Msg("unable to clean up VPC Switch during error recovery")
-				}
-
-				return nil
-			})
 
-			if err = vpcSwitch.PortAdd(portID, portMAC); err != nil {
+			if err = txn.PortAdd(vpcSwitch, portID, portMAC); err != nil {
 				log.Error().Err(err).
 					Object("port-id", portID).
 					Str("port-mac", portMAC.String()).
@@ -139,7 +118,11 @@ var Cmd = &command.Command{
 				return errors.Wrap(err, "unable to add a port to VPC Switch")
 			}
 
-			commit = true
+			if err = txn.Commit(); err != nil {
+				return errors.Wrap(err, "unable to commit VPC Switch Port add")
+			}
+
+			cons.Write([]byte("done.\n"))
 
 			// log.Info().Str("port-id", portAddCfg.ID.String()).Str("switch-id", switchID.String()).Str("uplink-id", uplinkID.String()). /*.Str("name", newPort.Name)*/ Msg("vpcp created")
 			log.Info().Object("port-id", portID).Str("switch-id", switchID.String()).Msg("vpcp created")
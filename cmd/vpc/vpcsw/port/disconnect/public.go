@@ -28,6 +28,7 @@ import (
 	"fmt"
 
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcp"
+	"github.com/joyent/freebsd-vpc/agent/api"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
 	"github.com/joyent/freebsd-vpc/internal/config"
@@ -72,6 +73,18 @@ var Cmd = &command.Command{
 				return errors.Wrap(err, "unable to get switch port ID")
 			}
 
+			if viper.GetBool(config.KeyViaAgent) {
+				client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
+				if err := client.PortDisconnect(api.ConnectRequest{ID: portID.String(), InterfaceID: interfaceID.String()}); err != nil {
+					return errors.Wrap(err, "unable to disconnect a VPC Interface from VPC Switch Port via agent")
+				}
+
+				cons.Write([]byte("done.\n"))
+				log.Info().Object("port-id", portID).Object("interface-id", interfaceID).Msg("VPC Interface disconnected from VPC Switch Port via agent")
+
+				return nil
+			}
+
 			portCfg := vpcp.Config{
 				ID:        portID,
 				Writeable: true,
@@ -0,0 +1,138 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package get
+
+import (
+	"strconv"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcp"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/meta"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/sean-/conswriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	cmdName   = "get"
+	keyPortID = config.KeySWPortGetPortID
+	keyMAC    = config.KeySWPortGetMAC
+	keyMTU    = config.KeySWPortGetMTU
+)
+
+var metaKeys = meta.Keys{MAC: keyMAC, MTU: keyMTU}
+
+var Cmd = &command.Command{
+	Name: cmdName,
+
+	Cobra: &cobra.Command{
+		Use:          cmdName,
+		Short:        "get VPC Switch Port information",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cons := conswriter.GetTerminal()
+
+			table := output.Table{
+				Header:          []string{"id", "key", "value"},
+				ColumnAlignment: []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT},
+			}
+
+			id, err := flag.GetID(viper.GetViper(), keyPortID)
+			if err != nil {
+				return errors.Wrap(err, "unable to get VPC Switch Port ID")
+			}
+
+			port, err := vpcp.Open(vpcp.Config{ID: id})
+			if err != nil {
+				return errors.Wrap(err, "unable to open VPC Switch Port")
+			}
+			defer port.Close()
+
+			attrs, err := meta.Get(viper.GetViper(), metaKeys, id, port, &table)
+			if err != nil {
+				return errors.Wrap(err, "unable to get VPC Switch Port attributes")
+			}
+
+			vni, err := port.GetVNI()
+			if err != nil {
+				return errors.Wrap(err, "unable to get VPC Port VNI")
+			}
+			table.Append(id.String(), "vni", strconv.FormatInt(int64(vni), 10))
+
+			vlan, err := port.VLAN()
+			if err != nil {
+				return errors.Wrap(err, "unable to get VPC Port VLAN")
+			}
+			table.Append(id.String(), "vlan", strconv.FormatUint(uint64(vlan), 10))
+
+			record := portInfo{
+				ID:    id.String(),
+				VNI:   int32(vni),
+				VLAN:  uint16(vlan),
+				Attrs: attrs,
+			}
+
+			switch peerID, err := port.PeerID(); {
+			case vpc.IsNotExist(err):
+			case err != nil:
+				return errors.Wrap(err, "unable to get VPC Port peer ID")
+			default:
+				record.PeerID = peerID.String()
+				table.Append(id.String(), "peer-id", record.PeerID)
+			}
+
+			return output.Write(cons, viper.GetViper(), record, table)
+		},
+	},
+
+	Setup: func(self *command.Command) error {
+		if err := flag.AddPortID(self, keyPortID, true); err != nil {
+			return errors.Wrap(err, "unable to register VPC Switch Port ID flag on VPC Switch Port get")
+		}
+
+		if err := meta.AddGetFlags(self, metaKeys); err != nil {
+			return errors.Wrap(err, "unable to register meta flags on VPC Switch Port get")
+		}
+
+		return nil
+	},
+}
+
+// portInfo is the machine-readable record for the attributes of a VPC Switch Port.
+type portInfo struct {
+	ID         string `json:"id" yaml:"id"`
+	VNI        int32  `json:"vni" yaml:"vni"`
+	VLAN       uint16 `json:"vlan" yaml:"vlan"`
+	PeerID     string `json:"peer-id,omitempty" yaml:"peer-id,omitempty"`
+	meta.Attrs `yaml:",inline"`
+}
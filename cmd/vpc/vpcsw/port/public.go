@@ -28,6 +28,7 @@ import (
 	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/port/add"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/port/connect"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/port/disconnect"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/port/get"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/port/remove"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/port/set"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/port/uplink"
@@ -52,6 +53,7 @@ var Cmd = &command.Command{
 			add.Cmd,
 			connect.Cmd,
 			disconnect.Cmd,
+			get.Cmd,
 			//list.Cmd,
 			remove.Cmd,
 			set.Cmd,
@@ -28,6 +28,7 @@ import (
 	"fmt"
 
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcsw"
+	"github.com/joyent/freebsd-vpc/agent/api"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
 	"github.com/joyent/freebsd-vpc/internal/config"
@@ -92,6 +93,18 @@ func runE(cmd *cobra.Command, args []string) error {
 		return errors.Wrap(err, "unable to get VPC Switch Port ID")
 	}
 
+	if viper.GetBool(config.KeyViaAgent) {
+		client := api.NewClient(viper.GetString(config.KeyAgentInternalAddr))
+		if err := client.PortRemove(api.PortRequest{SwitchID: switchID.String(), PortID: portID.String()}); err != nil {
+			return errors.Wrap(err, "unable to remove VPC Switch Port via agent")
+		}
+
+		cons.Write([]byte("done.\n"))
+		log.Info().Object("port-id", portID).Str("switch-id", switchID.String()).Msg("vpcp removed via agent")
+
+		return nil
+	}
+
 	// 3) open switch
 	switchCfg := vpcsw.Config{
 		ID:        switchID,
@@ -31,6 +31,7 @@ import (
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcp"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/joyent/freebsd-vpc/internal/command/meta"
 	"github.com/joyent/freebsd-vpc/internal/config"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -38,11 +39,16 @@ import (
 )
 
 const (
-	cmdName    = "set"
-	_KeyPortID = config.KeySWPortSetPortID
-	_KeySetVNI = config.KeySWPortSetVNI
+	cmdName     = "set"
+	_KeyPortID  = config.KeySWPortSetPortID
+	_KeySetMAC  = config.KeySWPortSetMAC
+	_KeySetMTU  = config.KeySWPortSetMTU
+	_KeySetVLAN = config.KeySWPortSetVLAN
+	_KeySetVNI  = config.KeySWPortSetVNI
 )
 
+var metaKeys = meta.Keys{MAC: _KeySetMAC, MTU: _KeySetMTU}
+
 var Cmd = &command.Command{
 	Name: cmdName,
 
@@ -77,6 +83,20 @@ var Cmd = &command.Command{
 				}
 			}
 
+			if vlan := viper.GetInt(_KeySetVLAN); vlan >= 0 {
+				if vlan > vpc.VTagMax {
+					return errors.Errorf("VLAN %d out of range (max %d)", vlan, vpc.VTagMax)
+				}
+
+				if err := port.SetVLAN(vpc.VTag(vlan)); err != nil {
+					return errors.Wrapf(err, "unable to set VPC VLAN")
+				}
+			}
+
+			if err := meta.Set(viper.GetViper(), metaKeys, port); err != nil {
+				return errors.Wrap(err, "unable to set VPC Port attributes")
+			}
+
 			vni, err := port.GetVNI()
 			if err != nil {
 				return errors.Wrapf(err, "unable to get the VNI for VPC Port")
@@ -108,6 +128,26 @@ var Cmd = &command.Command{
 			viper.SetDefault(key, defaultValue)
 		}
 
+		{
+			const (
+				key          = _KeySetVLAN
+				longName     = "vlan"
+				shortName    = ""
+				defaultValue = -1
+				description  = "set the VLAN ID of a given VPC Port (0 clears the VLAN)"
+			)
+
+			flags := self.Cobra.Flags()
+			flags.IntP(longName, shortName, defaultValue, description)
+
+			viper.BindPFlag(key, flags.Lookup(longName))
+			viper.SetDefault(key, defaultValue)
+		}
+
+		if err := meta.AddSetFlags(self, metaKeys); err != nil {
+			return errors.Wrap(err, "unable to register meta flags on VPC Switch Port Set")
+		}
+
 		return nil
 	},
 }
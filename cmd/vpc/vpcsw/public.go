@@ -27,8 +27,11 @@ package vpcsw
 import (
 	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/create"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/destroy"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/get"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/list"
 	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/port"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/reset"
+	"github.com/joyent/freebsd-vpc/cmd/vpc/vpcsw/set"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -49,8 +52,11 @@ var Cmd = &command.Command{
 		subCommands := command.Commands{
 			create.Cmd,
 			destroy.Cmd,
+			get.Cmd,
 			list.Cmd,
 			port.Cmd,
+			reset.Cmd,
+			set.Cmd,
 		}
 
 		if err := self.Register(subCommands); err != nil {
@@ -0,0 +1,324 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package db
+
+import (
+	"context"
+	"net"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// CN is a row in the cn table.
+type CN struct {
+	ID         uuid.UUID
+	FacilityID uuid.UUID
+}
+
+const cnColumns = `id, facility_id`
+
+func scanCN(r rowScanner) (CN, error) {
+	var cn CN
+	err := r.Scan(&cn.ID, &cn.FacilityID)
+	return cn, err
+}
+
+// CreateCN inserts cn.  If cn.ID is uuid.Nil the database generates the ID and
+// cn.ID is updated.
+func CreateCN(ctx context.Context, q Querier, cn *CN) error {
+	const sql = `INSERT INTO cn (id, facility_id) VALUES (COALESCE($1, gen_random_uuid()), $2) RETURNING id`
+	if err := q.QueryRowEx(ctx, sql, nil, nullID(cn.ID), cn.FacilityID).Scan(&cn.ID); err != nil {
+		return errors.Wrapf(err, "unable to create CN in facility %s", cn.FacilityID)
+	}
+
+	return nil
+}
+
+// GetCN returns the CN identified by id.
+func GetCN(ctx context.Context, q Querier, id uuid.UUID) (CN, error) {
+	const sql = `SELECT ` + cnColumns + ` FROM cn WHERE id = $1`
+	cn, err := scanCN(q.QueryRowEx(ctx, sql, nil, id))
+	if err != nil {
+		return CN{}, errors.Wrapf(notFound(err), "unable to get CN %s", id)
+	}
+
+	return cn, nil
+}
+
+// ListCNs returns the CNs in facilityID.
+func ListCNs(ctx context.Context, q Querier, facilityID uuid.UUID) ([]CN, error) {
+	const sql = `SELECT ` + cnColumns + ` FROM cn WHERE facility_id = $1 ORDER BY id`
+	var cns []CN
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		cn, err := scanCN(r)
+		if err != nil {
+			return err
+		}
+		cns = append(cns, cn)
+		return nil
+	}, sql, facilityID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list CNs in facility %s", facilityID)
+	}
+
+	return cns, nil
+}
+
+// UpdateCN moves cn to cn.FacilityID.
+func UpdateCN(ctx context.Context, q Querier, cn CN) error {
+	const sql = `UPDATE cn SET facility_id = $2 WHERE id = $1`
+	if err := execOne(ctx, q, sql, cn.ID, cn.FacilityID); err != nil {
+		return errors.Wrapf(err, "unable to update CN %s", cn.ID)
+	}
+
+	return nil
+}
+
+// DeleteCN deletes the CN identified by id.
+func DeleteCN(ctx context.Context, q Querier, id uuid.UUID) error {
+	const sql = `DELETE FROM cn WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete CN %s", id)
+	}
+
+	return nil
+}
+
+// CNUnderlayIP is a row in the cn_underlay_ip table.
+type CNUnderlayIP struct {
+	CNID       uuid.UUID
+	UnderlayIP net.IP
+}
+
+func scanCNUnderlayIP(r rowScanner) (CNUnderlayIP, error) {
+	var ip CNUnderlayIP
+	var addr string
+	if err := r.Scan(&ip.CNID, &addr); err != nil {
+		return CNUnderlayIP{}, err
+	}
+
+	if ip.UnderlayIP = net.ParseIP(addr); ip.UnderlayIP == nil {
+		return CNUnderlayIP{}, errors.Errorf("invalid underlay IP %q", addr)
+	}
+
+	return ip, nil
+}
+
+// CreateCNUnderlayIP inserts ip.
+func CreateCNUnderlayIP(ctx context.Context, q Querier, ip CNUnderlayIP) error {
+	const sql = `INSERT INTO cn_underlay_ip (cn_id, underlay_ip) VALUES ($1, $2)`
+	if _, err := q.ExecEx(ctx, sql, nil, ip.CNID, ip.UnderlayIP.String()); err != nil {
+		return errors.Wrapf(err, "unable to add underlay IP %s to CN %s", ip.UnderlayIP, ip.CNID)
+	}
+
+	return nil
+}
+
+// GetCNUnderlayIP returns the CN that owns underlayIP.
+func GetCNUnderlayIP(ctx context.Context, q Querier, underlayIP net.IP) (CNUnderlayIP, error) {
+	const sql = `SELECT cn_id, underlay_ip FROM cn_underlay_ip WHERE underlay_ip = $1`
+	ip, err := scanCNUnderlayIP(q.QueryRowEx(ctx, sql, nil, underlayIP.String()))
+	if err != nil {
+		return CNUnderlayIP{}, errors.Wrapf(notFound(err), "unable to get underlay IP %s", underlayIP)
+	}
+
+	return ip, nil
+}
+
+// ListCNUnderlayIPs returns the underlay IPs of cnID.
+func ListCNUnderlayIPs(ctx context.Context, q Querier, cnID uuid.UUID) ([]CNUnderlayIP, error) {
+	const sql = `SELECT cn_id, underlay_ip FROM cn_underlay_ip WHERE cn_id = $1 ORDER BY underlay_ip`
+	var ips []CNUnderlayIP
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		ip, err := scanCNUnderlayIP(r)
+		if err != nil {
+			return err
+		}
+		ips = append(ips, ip)
+		return nil
+	}, sql, cnID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list underlay IPs of CN %s", cnID)
+	}
+
+	return ips, nil
+}
+
+// UpdateCNUnderlayIP moves ip.UnderlayIP to ip.CNID.
+func UpdateCNUnderlayIP(ctx context.Context, q Querier, ip CNUnderlayIP) error {
+	const sql = `UPDATE cn_underlay_ip SET cn_id = $2 WHERE underlay_ip = $1`
+	if err := execOne(ctx, q, sql, ip.UnderlayIP.String(), ip.CNID); err != nil {
+		return errors.Wrapf(err, "unable to move underlay IP %s to CN %s", ip.UnderlayIP, ip.CNID)
+	}
+
+	return nil
+}
+
+// DeleteCNUnderlayIP deletes underlayIP.
+func DeleteCNUnderlayIP(ctx context.Context, q Querier, underlayIP net.IP) error {
+	const sql = `DELETE FROM cn_underlay_ip WHERE underlay_ip = $1`
+	if err := execOne(ctx, q, sql, underlayIP.String()); err != nil {
+		return errors.Wrapf(err, "unable to delete underlay IP %s", underlayIP)
+	}
+
+	return nil
+}
+
+// VM types.
+const (
+	VMTypeBhyve = "bhyve"
+	VMTypeKVM   = "kvm"
+	VMTypeJail  = "jail"
+	VMTypeZone  = "zone"
+)
+
+// VM is a row in the vm table.
+type VM struct {
+	ID                    uuid.UUID
+	CNID                  uuid.UUID
+	AccountID             uuid.UUID
+	TerminationProtection bool
+	Type                  string
+}
+
+const vmColumns = `id, cn_id, account_id, termination_protection, vm_type`
+
+func scanVM(r rowScanner) (VM, error) {
+	var vm VM
+	err := r.Scan(&vm.ID, &vm.CNID, &vm.AccountID, &vm.TerminationProtection, &vm.Type)
+	return vm, err
+}
+
+// CreateVM inserts vm.  If vm.ID is uuid.Nil the database generates the ID and
+// vm.ID is updated.
+func CreateVM(ctx context.Context, q Querier, vm *VM) error {
+	const sql = `INSERT INTO vm (id, cn_id, account_id, termination_protection, vm_type) VALUES (COALESCE($1, gen_random_uuid()), $2, $3, $4, $5) RETURNING id`
+	if err := q.QueryRowEx(ctx, sql, nil, nullID(vm.ID), vm.CNID, vm.AccountID, vm.TerminationProtection, vm.Type).Scan(&vm.ID); err != nil {
+		return errors.Wrapf(err, "unable to create VM on CN %s", vm.CNID)
+	}
+
+	return nil
+}
+
+// GetVM returns the VM identified by id.
+func GetVM(ctx context.Context, q Querier, id uuid.UUID) (VM, error) {
+	const sql = `SELECT ` + vmColumns + ` FROM vm WHERE id = $1`
+	vm, err := scanVM(q.QueryRowEx(ctx, sql, nil, id))
+	if err != nil {
+		return VM{}, errors.Wrapf(notFound(err), "unable to get VM %s", id)
+	}
+
+	return vm, nil
+}
+
+// ListVMs returns the VMs on cnID.
+func ListVMs(ctx context.Context, q Querier, cnID uuid.UUID) ([]VM, error) {
+	const sql = `SELECT ` + vmColumns + ` FROM vm WHERE cn_id = $1 ORDER BY id`
+	var vms []VM
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		vm, err := scanVM(r)
+		if err != nil {
+			return err
+		}
+		vms = append(vms, vm)
+		return nil
+	}, sql, cnID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list VMs on CN %s", cnID)
+	}
+
+	return vms, nil
+}
+
+// UpdateVM updates the termination protection and type of vm.
+func UpdateVM(ctx context.Context, q Querier, vm VM) error {
+	const sql = `UPDATE vm SET termination_protection = $2, vm_type = $3 WHERE id = $1`
+	if err := execOne(ctx, q, sql, vm.ID, vm.TerminationProtection, vm.Type); err != nil {
+		return errors.Wrapf(err, "unable to update VM %s", vm.ID)
+	}
+
+	return nil
+}
+
+// DeleteVM deletes the VM identified by id.
+func DeleteVM(ctx context.Context, q Querier, id uuid.UUID) error {
+	const sql = `DELETE FROM vm WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete VM %s", id)
+	}
+
+	return nil
+}
+
+// CNVNIC is a VNIC attached to a VM on a CN along with the subnet of its
+// primary IP and the VNI and VLAN of that subnet.  MAC is nil until a MAC
+// address has been leased for the VNIC, see SetVNICMAC.
+type CNVNIC struct {
+	VNICID    uuid.UUID
+	VMID      uuid.UUID
+	AccountID uuid.UUID
+	SubnetID  uuid.UUID
+	MAC       net.HardwareAddr
+	VNI       int32
+	VLANID    int32
+}
+
+// ListCNVNICs returns the VNICs of the VMs on cnID.  VNICs without a primary
+// IP, or whose subnet has no VNI in the facility of the CN, are omitted.
+func ListCNVNICs(ctx context.Context, q Querier, cnID uuid.UUID) ([]CNVNIC, error) {
+	const sql = `SELECT vnic.id, vm.id, vnic.account_id, subnet_ip.subnet_id, vnic.mac, svv.vni, svv.vlan_id ` +
+		`FROM vm ` +
+		`JOIN cn ON cn.id = vm.cn_id ` +
+		`JOIN vnic ON vnic.obj_id = vm.id ` +
+		`JOIN obj_type ON obj_type.id = vnic.obj_type AND obj_type.name = 'vm' ` +
+		`JOIN vnic_ip ON vnic_ip.vnic_id = vnic.id AND vnic_ip.ip_index = 0 ` +
+		`JOIN subnet_ip ON subnet_ip.id = vnic_ip.ip_id ` +
+		`JOIN subnet_vni_vlan AS svv ON svv.subnet_id = subnet_ip.subnet_id AND svv.facility_id = cn.facility_id ` +
+		`WHERE vm.cn_id = $1 ` +
+		`ORDER BY vnic.id`
+	var vnics []CNVNIC
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		var vnic CNVNIC
+		var mac *string
+		if err := r.Scan(&vnic.VNICID, &vnic.VMID, &vnic.AccountID, &vnic.SubnetID, &mac, &vnic.VNI, &vnic.VLANID); err != nil {
+			return err
+		}
+		if mac != nil {
+			hwAddr, err := net.ParseMAC(*mac)
+			if err != nil {
+				return errors.Wrapf(err, "unable to parse MAC %q of VNIC %s", *mac, vnic.VNICID)
+			}
+			vnic.MAC = hwAddr
+		}
+		vnics = append(vnics, vnic)
+		return nil
+	}, sql, cnID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list VNICs on CN %s", cnID)
+	}
+
+	return vnics, nil
+}
@@ -79,3 +79,15 @@ func SetDefaultViperOptions() error {
 
 	return nil
 }
+
+// ViperConfig returns the database Config stored in the "db" Viper key.
+func ViperConfig() (Config, error) {
+	var config struct {
+		DBConfig Config `mapstructure:"db"`
+	}
+	if err := viper.Unmarshal(&config); err != nil {
+		return Config{}, errors.Wrap(err, "unable to decode config into struct")
+	}
+
+	return config.DBConfig, nil
+}
@@ -0,0 +1,523 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package db
+
+import (
+	"context"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/pgtype"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// testPool returns a pool connected to the insecure CockroachDB (or
+// PostgreSQL) instance named by the VPC_TEST_DB_* environment variables with
+// all migrations applied.  The test is skipped if VPC_TEST_DB_HOST is unset.
+// The database named by VPC_TEST_DB_DATABASE (default "vpc_test") must exist.
+func testPool(t *testing.T) *Pool {
+	host := os.Getenv("VPC_TEST_DB_HOST")
+	if host == "" {
+		t.Skip("VPC_TEST_DB_HOST not set")
+	}
+
+	cfg := Config{
+		Scheme:      "crdb",
+		Host:        host,
+		Port:        26257,
+		User:        "root",
+		Database:    "vpc_test",
+		ConnTimeout: 10 * time.Second,
+	}
+	if port := os.Getenv("VPC_TEST_DB_PORT"); port != "" {
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			t.Fatalf("invalid VPC_TEST_DB_PORT: %v", err)
+		}
+		cfg.Port = uint16(p)
+	}
+	if user := os.Getenv("VPC_TEST_DB_USER"); user != "" {
+		cfg.User = user
+	}
+	if database := os.Getenv("VPC_TEST_DB_DATABASE"); database != "" {
+		cfg.Database = database
+	}
+
+	m, err := NewMigrator(cfg)
+	if err != nil {
+		t.Fatalf("unable to create migrator: %v", err)
+	}
+	defer m.Close()
+	if err := m.Up(0); err != nil {
+		t.Fatalf("unable to migrate: %v", err)
+	}
+
+	pool, err := New(cfg)
+	if err != nil {
+		t.Fatalf("unable to connect: %v", err)
+	}
+
+	return pool
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err       error
+		retryable bool
+		unique    bool
+	}{
+		{pgx.PgError{Code: "40001"}, true, false},
+		{errors.Wrap(pgx.PgError{Code: "40001"}, "wrapped"), true, false},
+		{pgx.PgError{Code: "23505"}, false, true},
+		{errors.Wrap(pgx.PgError{Code: "23505"}, "wrapped"), false, true},
+		{ErrNotFound, false, false},
+	}
+
+	for i, test := range tests {
+		if got := IsRetryable(test.err); got != test.retryable {
+			t.Errorf("%d: IsRetryable(%v) = %t, want %t", i, test.err, got, test.retryable)
+		}
+		if got := IsUniqueViolation(test.err); got != test.unique {
+			t.Errorf("%d: IsUniqueViolation(%v) = %t, want %t", i, test.err, got, test.unique)
+		}
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	if !IsNotFound(errors.Wrap(notFound(pgx.ErrNoRows), "wrapped")) {
+		t.Error("wrapped pgx.ErrNoRows is not found")
+	}
+
+	if IsNotFound(errors.New("other")) {
+		t.Error("unrelated error is not found")
+	}
+}
+
+func TestIntervalDuration(t *testing.T) {
+	i := pgtype.Interval{Microseconds: 1500000, Days: 90, Status: pgtype.Present}
+	if got, want := intervalDuration(i), DefaultExpireAfter+1500*time.Millisecond; got != want {
+		t.Errorf("intervalDuration = %v, want %v", got, want)
+	}
+}
+
+func TestExecuteTxRetry(t *testing.T) {
+	pool := testPool(t)
+	defer pool.Close()
+
+	attempts := 0
+	err := pool.ExecuteTx(context.Background(), func(tx *pgx.Tx) error {
+		attempts++
+		if attempts == 1 {
+			return pgx.PgError{Code: "40001", Message: "injected retry"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExecuteTx: %v", err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
+
+// errRollback is returned by the transaction in TestRepository so that every
+// row it creates is rolled back.
+var errRollback = errors.New("rollback")
+
+func TestRepository(t *testing.T) {
+	pool := testPool(t)
+	defer pool.Close()
+
+	ctx := context.Background()
+	err := pool.ExecuteTx(ctx, func(tx *pgx.Tx) error {
+		org := Org{Name: "test-org"}
+		if err := CreateOrg(ctx, tx, &org); err != nil {
+			return err
+		}
+		org.Name = "renamed-org"
+		if err := UpdateOrg(ctx, tx, org); err != nil {
+			return err
+		}
+		if got, err := GetOrg(ctx, tx, org.ID); err != nil || got != org {
+			return errors.Errorf("GetOrg = %+v, %v; want %+v", got, err, org)
+		}
+
+		account := Account{OrgID: org.ID, Name: "test-account"}
+		if err := CreateAccount(ctx, tx, &account); err != nil {
+			return err
+		}
+		if accounts, err := ListAccounts(ctx, tx, org.ID); err != nil || len(accounts) != 1 || accounts[0] != account {
+			return errors.Errorf("ListAccounts = %+v, %v; want [%+v]", accounts, err, account)
+		}
+
+		vpc := VPC{AccountID: account.ID, Name: "test-vpc"}
+		if err := CreateVPC(ctx, tx, &vpc); err != nil {
+			return err
+		}
+		if vpc.ID == uuid.Nil {
+			return errors.New("CreateVPC did not generate an ID")
+		}
+
+		region := Region{ID: "test-region-" + uuid.Must(uuid.NewV4()).String()}
+		if err := CreateRegion(ctx, tx, region); err != nil {
+			return err
+		}
+		facility := Facility{Name: "test-facility-" + uuid.Must(uuid.NewV4()).String(), RegionID: region.ID}
+		if err := CreateFacility(ctx, tx, &facility); err != nil {
+			return err
+		}
+		if got, err := GetFacilityByName(ctx, tx, facility.Name); err != nil || got != facility {
+			return errors.Errorf("GetFacilityByName = %+v, %v; want %+v", got, err, facility)
+		}
+		az := AZ{RegionID: region.ID, Name: "a"}
+		if err := CreateAZ(ctx, tx, &az); err != nil {
+			return err
+		}
+
+		vni := VNI{FacilityID: facility.ID, VNI: 4242}
+		if err := CreateVNI(ctx, tx, vni); err != nil {
+			return err
+		}
+		vni.VPCID = uuid.NullUUID{UUID: vpc.ID, Valid: true}
+		if err := UpdateVNI(ctx, tx, vni); err != nil {
+			return err
+		}
+		gotVNI, err := GetVNI(ctx, tx, facility.ID, vni.VNI)
+		if err != nil {
+			return err
+		}
+		if gotVNI.VPCID != vni.VPCID || gotVNI.ExpireAfter != DefaultExpireAfter || gotVNI.ExpiredAt != nil {
+			return errors.Errorf("GetVNI = %+v", gotVNI)
+		}
+
+		_, network, _ := net.ParseCIDR("10.1.2.0/24")
+		subnet := Subnet{VPCID: vpc.ID, Network: *network}
+		if err := CreateSubnet(ctx, tx, &subnet); err != nil {
+			return err
+		}
+		gotSubnet, err := GetSubnet(ctx, tx, subnet.ID)
+		if err != nil {
+			return err
+		}
+		if gotSubnet.Network.String() != network.String() {
+			return errors.Errorf("GetSubnet network = %s, want %s", gotSubnet.Network.String(), network)
+		}
+
+		hwAddr, _ := net.ParseMAC("58:9c:fc:00:00:01")
+		mac := AccountMAC{AccountID: account.ID, MAC: hwAddr, VPCID: vpc.ID, SubnetID: subnet.ID}
+		if err := CreateAccountMAC(ctx, tx, &mac); err != nil {
+			return err
+		}
+		if _, err := GetAccountMAC(ctx, tx, account.ID, hwAddr); err != nil {
+			return err
+		}
+
+		ip := SubnetIP{VPCID: vpc.ID, SubnetID: subnet.ID, IP: net.ParseIP("10.1.2.10")}
+		if err := CreateSubnetIP(ctx, tx, &ip); err != nil {
+			return err
+		}
+
+		router := Router{VPCID: vpc.ID}
+		if err := CreateRouter(ctx, tx, &router); err != nil {
+			return err
+		}
+
+		vnic := VNIC{AccountID: account.ID}
+		if err := CreateVNIC(ctx, tx, &vnic); err != nil {
+			return err
+		}
+		if err := CreateVNICIP(ctx, tx, VNICIP{VNICID: vnic.ID, IPID: ip.ID}); err != nil {
+			return err
+		}
+		if ips, err := ListVNICIPs(ctx, tx, vnic.ID); err != nil || len(ips) != 1 || ips[0].IPID != ip.ID {
+			return errors.Errorf("ListVNICIPs = %+v, %v", ips, err)
+		}
+
+		sg := SecurityGroup{AccountID: account.ID, Name: "test-sg"}
+		if err := CreateSecurityGroup(ctx, tx, &sg); err != nil {
+			return err
+		}
+		tcp := int32(6)
+		rule := SecurityGroupRule{SecurityGroupID: sg.ID, Protocol: &tcp, SrcCIDR: network}
+		if err := CreateSecurityGroupRule(ctx, tx, &rule); err != nil {
+			return err
+		}
+		gotRule, err := GetSecurityGroupRule(ctx, tx, rule.ID)
+		if err != nil {
+			return err
+		}
+		if gotRule.Direction != DirectionIn || gotRule.Protocol == nil || *gotRule.Protocol != tcp ||
+			gotRule.SrcCIDR.String() != network.String() || gotRule.DstCIDR != nil {
+			return errors.Errorf("GetSecurityGroupRule = %+v", gotRule)
+		}
+
+		cn := CN{FacilityID: facility.ID}
+		if err := CreateCN(ctx, tx, &cn); err != nil {
+			return err
+		}
+		underlay := CNUnderlayIP{CNID: cn.ID, UnderlayIP: net.ParseIP("192.0.2.1")}
+		if err := CreateCNUnderlayIP(ctx, tx, underlay); err != nil {
+			return err
+		}
+
+		vm := VM{CNID: cn.ID, AccountID: account.ID, Type: VMTypeBhyve}
+		if err := CreateVM(ctx, tx, &vm); err != nil {
+			return err
+		}
+
+		vmType, err := GetObjTypeID(ctx, tx, "vm")
+		if err != nil {
+			return err
+		}
+		vnic.ObjID = uuid.NullUUID{UUID: vm.ID, Valid: true}
+		vnic.ObjType = uuid.NullUUID{UUID: vmType, Valid: true}
+		if err := UpdateVNIC(ctx, tx, vnic); err != nil {
+			return err
+		}
+		if vnics, err := ListCNVNICs(ctx, tx, cn.ID); err != nil || len(vnics) != 0 {
+			return errors.Errorf("ListCNVNICs without a VNI mapping = %+v, %v", vnics, err)
+		}
+		svv := SubnetVNIVLAN{FacilityID: facility.ID, SubnetID: subnet.ID, VNI: vni.VNI, VLANID: 42}
+		if err := CreateSubnetVNIVLAN(ctx, tx, svv); err != nil {
+			return err
+		}
+		if got, err := GetSubnetVNIVLAN(ctx, tx, facility.ID, subnet.ID); err != nil || got != svv {
+			return errors.Errorf("GetSubnetVNIVLAN = %+v, %v; want %+v", got, err, svv)
+		}
+		wantVNIC := CNVNIC{VNICID: vnic.ID, VMID: vm.ID, AccountID: account.ID, SubnetID: subnet.ID, VNI: vni.VNI, VLANID: 42}
+		if vnics, err := ListCNVNICs(ctx, tx, cn.ID); err != nil || len(vnics) != 1 || !reflect.DeepEqual(vnics[0], wantVNIC) {
+			return errors.Errorf("ListCNVNICs = %+v, %v; want [%+v]", vnics, err, wantVNIC)
+		}
+		if err := SetVNICMAC(ctx, tx, vnic.ID, hwAddr); err != nil {
+			return err
+		}
+		if err := SetVNICMAC(ctx, tx, vnic.ID, hwAddr); !IsNotFound(err) {
+			return errors.Errorf("SetVNICMAC with a MAC already set: %v", err)
+		}
+		wantVNIC.MAC = hwAddr
+		if vnics, err := ListCNVNICs(ctx, tx, cn.ID); err != nil || len(vnics) != 1 || !reflect.DeepEqual(vnics[0], wantVNIC) {
+			return errors.Errorf("ListCNVNICs with a MAC = %+v, %v; want [%+v]", vnics, err, wantVNIC)
+		}
+		vnic.ObjID, vnic.ObjType = uuid.NullUUID{}, uuid.NullUUID{}
+		if err := UpdateVNIC(ctx, tx, vnic); err != nil {
+			return err
+		}
+		if err := DeleteVM(ctx, tx, vm.ID); err != nil {
+			return err
+		}
+		if _, err := GetVM(ctx, tx, vm.ID); !IsNotFound(err) {
+			return errors.Errorf("GetVM after delete: %v", err)
+		}
+		if err := DeleteVM(ctx, tx, vm.ID); !IsNotFound(err) {
+			return errors.Errorf("DeleteVM after delete: %v", err)
+		}
+
+		return errRollback
+	})
+	if errors.Cause(err) != errRollback {
+		t.Fatal(err)
+	}
+}
+
+func TestVNIAllocator(t *testing.T) {
+	pool := testPool(t)
+	defer pool.Close()
+
+	ctx := context.Background()
+	q := pool.Pool()
+
+	region := Region{ID: "test-region-" + uuid.Must(uuid.NewV4()).String()}
+	if err := CreateRegion(ctx, q, region); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteRegion(ctx, q, region.ID)
+	facility := Facility{Name: "test-facility-" + uuid.Must(uuid.NewV4()).String(), RegionID: region.ID}
+	if err := CreateFacility(ctx, q, &facility); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteFacility(ctx, q, facility.ID)
+
+	org := Org{Name: "test-org"}
+	if err := CreateOrg(ctx, q, &org); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteOrg(ctx, q, org.ID)
+	account := Account{OrgID: org.ID}
+	if err := CreateAccount(ctx, q, &account); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteAccount(ctx, q, account.ID)
+	vpc := VPC{AccountID: account.ID}
+	if err := CreateVPC(ctx, q, &vpc); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteVPC(ctx, q, vpc.ID)
+
+	alloc := NewVNIAllocator(pool)
+	alloc.Min, alloc.Max = 100, 101
+	defer func() {
+		for vni := alloc.Min; vni <= alloc.Max; vni++ {
+			DeleteVNI(ctx, q, facility.ID, int32(vni))
+		}
+	}()
+
+	for _, want := range []int32{100, 101} {
+		vni, err := alloc.Lease(ctx, facility.ID, vpc.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int32(vni) != want {
+			t.Fatalf("Lease = %d, want %d", vni, want)
+		}
+	}
+
+	if _, err := alloc.Lease(ctx, facility.ID, vpc.ID); errors.Cause(err) != ErrVNIExhausted {
+		t.Fatalf("Lease with no free VNIs: %v", err)
+	}
+
+	if err := alloc.Release(ctx, facility.ID, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := alloc.Release(ctx, facility.ID, 100); !IsNotFound(err) {
+		t.Fatalf("second Release: %v", err)
+	}
+
+	// The released VNI is tombstoned for DefaultExpireAfter.
+	if _, err := alloc.Lease(ctx, facility.ID, vpc.ID); errors.Cause(err) != ErrVNIExhausted {
+		t.Fatalf("Lease of a tombstoned VNI: %v", err)
+	}
+
+	tombstone, err := GetVNI(ctx, q, facility.ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tombstone.VPCID.Valid || tombstone.ExpiredAt == nil {
+		t.Fatalf("released VNI = %+v, want a tombstone", tombstone)
+	}
+	tombstone.ExpireAfter = time.Microsecond
+	if err := UpdateVNI(ctx, q, tombstone); err != nil {
+		t.Fatal(err)
+	}
+
+	vni, err := alloc.Lease(ctx, facility.ID, vpc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vni != 100 {
+		t.Fatalf("Lease after expiry = %d, want 100", vni)
+	}
+
+	// A canceled lease is deleted rather than tombstoned.
+	if err := alloc.Cancel(ctx, facility.ID, 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetVNI(ctx, q, facility.ID, 100); !IsNotFound(err) {
+		t.Fatalf("GetVNI of a canceled VNI: %v", err)
+	}
+	if vni, err := alloc.Lease(ctx, facility.ID, vpc.ID); err != nil || vni != 100 {
+		t.Fatalf("Lease after Cancel = %d, %v; want 100", vni, err)
+	}
+}
+
+func TestRandomMAC(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		mac, err := randomMAC()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if mac[0]&0x01 != 0 || mac[0]&0x02 == 0 {
+			t.Fatalf("%s is not a locally administered unicast address", mac)
+		}
+	}
+}
+
+func TestMACAllocator(t *testing.T) {
+	pool := testPool(t)
+	defer pool.Close()
+
+	ctx := context.Background()
+	q := pool.Pool()
+
+	org := Org{Name: "test-org"}
+	if err := CreateOrg(ctx, q, &org); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteOrg(ctx, q, org.ID)
+	account := Account{OrgID: org.ID}
+	if err := CreateAccount(ctx, q, &account); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteAccount(ctx, q, account.ID)
+	vpc := VPC{AccountID: account.ID}
+	if err := CreateVPC(ctx, q, &vpc); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteVPC(ctx, q, vpc.ID)
+	_, network, _ := net.ParseCIDR("10.9.0.0/24")
+	subnet := Subnet{VPCID: vpc.ID, Network: *network}
+	if err := CreateSubnet(ctx, q, &subnet); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteSubnet(ctx, q, subnet.ID)
+
+	alloc := NewMACAllocator(pool)
+	mac, err := alloc.Lease(ctx, account.ID, subnet.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteAccountMAC(ctx, q, account.ID, mac)
+
+	if _, err := alloc.Lease(ctx, account.ID, subnet.ID, mac); errors.Cause(err) != ErrMACInUse {
+		t.Fatalf("Lease of a leased MAC: %v", err)
+	}
+
+	if err := alloc.Release(ctx, account.ID, mac); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := alloc.Lease(ctx, account.ID, subnet.ID, mac); errors.Cause(err) != ErrMACInUse {
+		t.Fatalf("Lease of a tombstoned MAC: %v", err)
+	}
+
+	tombstone, err := GetAccountMAC(ctx, q, account.ID, mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tombstone.ExpireAfter = time.Microsecond
+	if err := UpdateAccountMAC(ctx, q, tombstone); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := alloc.Lease(ctx, account.ID, subnet.ID, mac); err != nil {
+		t.Fatalf("Lease of an expired MAC: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"net"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// ErrMACExhausted is returned when MACAllocator is unable to generate an
+	// unused MAC address.
+	ErrMACExhausted = errors.New("unable to find an unused MAC address")
+
+	// ErrMACInUse is returned when a specific MAC address is requested that
+	// is already in use or tombstoned in the account.
+	ErrMACInUse = errors.New("MAC address in use")
+)
+
+// MACAllocator leases MAC addresses that are unique within an account.
+// Released MACs are tombstoned and are not handed back out until
+// expired_at + expire_after has passed.
+type MACAllocator struct {
+	pool *Pool
+
+	// Attempts is the number of random MACs tried before giving up.
+	Attempts int
+}
+
+// NewMACAllocator returns a MACAllocator backed by pool.
+func NewMACAllocator(pool *Pool) *MACAllocator {
+	return &MACAllocator{
+		pool:     pool,
+		Attempts: 16,
+	}
+}
+
+// Lease leases mac in accountID for use in subnetID.  If mac is nil a random
+// locally administered unicast MAC is generated.  ErrMACInUse is returned if
+// mac is already leased or tombstoned in the account.
+func (a *MACAllocator) Lease(ctx context.Context, accountID, subnetID uuid.UUID, mac net.HardwareAddr) (leased net.HardwareAddr, err error) {
+	if mac != nil && (len(mac) != 6 || mac[0]&0x01 != 0) {
+		return nil, errors.Errorf("%s is not a unicast Ethernet address", mac)
+	}
+
+	err = a.pool.ExecuteTx(ctx, func(tx *pgx.Tx) error {
+		subnet, err := GetSubnet(ctx, tx, subnetID)
+		if err != nil {
+			return err
+		}
+
+		if mac != nil {
+			ok, err := a.lease(ctx, tx, accountID, subnet, mac)
+			switch {
+			case err != nil:
+				return err
+			case !ok:
+				return ErrMACInUse
+			}
+
+			leased = mac
+			return nil
+		}
+
+		for i := 0; i < a.Attempts; i++ {
+			candidate, err := randomMAC()
+			if err != nil {
+				return err
+			}
+
+			ok, err := a.lease(ctx, tx, accountID, subnet, candidate)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				leased = candidate
+				return nil
+			}
+		}
+
+		return ErrMACExhausted
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to lease a MAC address in account %s", accountID)
+	}
+
+	return leased, nil
+}
+
+// lease claims mac for subnet if it is unused or its tombstone has expired and
+// returns false if mac is unavailable.
+func (a *MACAllocator) lease(ctx context.Context, tx *pgx.Tx, accountID uuid.UUID, subnet Subnet, mac net.HardwareAddr) (bool, error) {
+	const reclaimSQL = `UPDATE account_mac SET vpc_id = $3, subnet_id = $4, expired_at = NULL ` +
+		`WHERE account_id = $1 AND mac = $2 AND expired_at IS NOT NULL AND expired_at + expire_after <= now()`
+	switch err := execOne(ctx, tx, reclaimSQL, accountID, mac.String(), subnet.VPCID, subnet.ID); err {
+	case nil:
+		return true, nil
+	case ErrNotFound:
+	default:
+		return false, errors.Wrapf(err, "unable to reclaim MAC %s", mac)
+	}
+
+	const insertSQL = `INSERT INTO account_mac (account_id, mac, vpc_id, subnet_id, expire_after) ` +
+		`VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING`
+	tag, err := tx.ExecEx(ctx, insertSQL, nil, accountID, mac.String(), subnet.VPCID, subnet.ID, DefaultExpireAfter)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to insert MAC %s", mac)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// Release tombstones mac in accountID.  The MAC is not leased again until its
+// expire_after interval has passed.  ErrNotFound is returned if mac is not
+// leased.
+func (a *MACAllocator) Release(ctx context.Context, accountID uuid.UUID, mac net.HardwareAddr) error {
+	const sql = `UPDATE account_mac SET expired_at = now() ` +
+		`WHERE account_id = $1 AND mac = $2 AND expired_at IS NULL`
+	err := a.pool.ExecuteTx(ctx, func(tx *pgx.Tx) error {
+		return execOne(ctx, tx, sql, accountID, mac.String())
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to release MAC %s in account %s", mac, accountID)
+	}
+
+	return nil
+}
+
+// randomMAC returns a random unicast, locally administered MAC address.
+func randomMAC() (net.HardwareAddr, error) {
+	mac := make(net.HardwareAddr, 6)
+	if _, err := rand.Read(mac); err != nil {
+		return nil, errors.Wrap(err, "unable to read random bytes")
+	}
+
+	// Clear the multicast/broadcast bit and set the locally administered bit.
+	mac[0] = mac[0]&^0x01 | 0x02
+
+	return mac, nil
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package db
+
+import (
+	"os"
+
+	"github.com/joyent/freebsd-vpc/db/migrations"
+	"github.com/mattes/migrate"
+	"github.com/mattes/migrate/database/postgres"
+	"github.com/mattes/migrate/source"
+	"github.com/mattes/migrate/source/go-bindata"
+	"github.com/pkg/errors"
+)
+
+// Migration describes a schema migration found in the migration source.
+type Migration struct {
+	Version    uint   `json:"version" yaml:"version"`
+	Identifier string `json:"identifier" yaml:"identifier"`
+	Applied    bool   `json:"applied" yaml:"applied"`
+}
+
+// MigrationStatus describes the schema version of the database.  Version is
+// nil when no migrations have been applied.
+type MigrationStatus struct {
+	Version    *uint       `json:"version" yaml:"version"`
+	Dirty      bool        `json:"dirty" yaml:"dirty"`
+	Migrations []Migration `json:"migrations" yaml:"migrations"`
+}
+
+// Pending returns the migrations that have not been applied.
+func (s MigrationStatus) Pending() []Migration {
+	var pending []Migration
+	for _, m := range s.Migrations {
+		if !m.Applied {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending
+}
+
+// Migrator applies the embedded schema migrations to a database.
+type Migrator struct {
+	pool   *Pool
+	source source.Driver
+	m      *migrate.Migrate
+}
+
+func NewMigrator(config Config) (*Migrator, error) {
+	pool, err := New(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create database pool")
+	}
+
+	// Wrap jackc/pgx in an sql.DB-compatible facade.
+	stdDB, err := pool.STDDB()
+	if err != nil {
+		pool.Close()
+		return nil, errors.Wrap(err, "unable to conjur up sql.DB facade")
+	}
+
+	if err := stdDB.Ping(); err != nil {
+		pool.Close()
+		return nil, errors.Wrap(err, "unable to ping with stdlib driver")
+	}
+
+	src, err := bindata.WithInstance(
+		bindata.Resource(migrations.AssetNames(),
+			func(name string) ([]byte, error) {
+				return migrations.Asset(name)
+			}))
+	if err != nil {
+		pool.Close()
+		return nil, errors.Wrap(err, "unable to create migration source")
+	}
+
+	driver, err := postgres.WithInstance(stdDB, &postgres.Config{})
+	if err != nil {
+		pool.Close()
+		return nil, errors.Wrap(err, "unable to create migration driver")
+	}
+
+	m, err := migrate.NewWithInstance("file:///migrations/crdb/", src,
+		config.Database, driver)
+	if err != nil {
+		pool.Close()
+		return nil, errors.Wrap(err, "unable to create migration")
+	}
+
+	return &Migrator{
+		pool:   pool,
+		source: src,
+		m:      m,
+	}, nil
+}
+
+func (mg *Migrator) Close() error {
+	srcErr, dbErr := mg.m.Close()
+	mg.pool.Close()
+
+	if srcErr != nil {
+		return errors.Wrap(srcErr, "unable to close migration source")
+	}
+
+	if dbErr != nil {
+		return errors.Wrap(dbErr, "unable to close migration driver")
+	}
+
+	return nil
+}
+
+// Status returns the current schema version of the database and every
+// migration found in the migration source.
+func (mg *Migrator) Status() (MigrationStatus, error) {
+	var status MigrationStatus
+
+	version, dirty, err := mg.m.Version()
+	switch {
+	case err == migrate.ErrNilVersion:
+	case err != nil:
+		return MigrationStatus{}, errors.Wrap(err, "unable to get schema version")
+	default:
+		status.Version = &version
+		status.Dirty = dirty
+	}
+
+	v, err := mg.source.First()
+	for err == nil {
+		r, identifier, readErr := mg.source.ReadUp(v)
+		if readErr != nil {
+			return MigrationStatus{}, errors.Wrapf(readErr, "unable to read migration %d", v)
+		}
+		r.Close()
+
+		status.Migrations = append(status.Migrations, Migration{
+			Version:    v,
+			Identifier: identifier,
+			Applied:    status.Version != nil && v <= *status.Version,
+		})
+
+		v, err = mg.source.Next(v)
+	}
+	if !os.IsNotExist(err) {
+		return MigrationStatus{}, errors.Wrap(err, "unable to read migration source")
+	}
+
+	return status, nil
+}
+
+// Up applies the next n pending migrations.  An n of zero applies every
+// pending migration.
+func (mg *Migrator) Up(n int) error {
+	var err error
+	switch {
+	case n < 0:
+		return errors.Errorf("invalid number of migrations: %d", n)
+	case n == 0:
+		err = mg.m.Up()
+	default:
+		err = mg.m.Steps(n)
+	}
+
+	if err != nil && err != migrate.ErrNoChange {
+		return errors.Wrap(err, "unable to upgrade schema")
+	}
+
+	return nil
+}
+
+// Down reverts the last n applied migrations.
+func (mg *Migrator) Down(n int) error {
+	if n <= 0 {
+		return errors.Errorf("invalid number of migrations: %d", n)
+	}
+
+	if err := mg.m.Steps(-n); err != nil && err != migrate.ErrNoChange {
+		return errors.Wrap(err, "unable to downgrade schema")
+	}
+
+	return nil
+}
+
+// Goto migrates the schema up or down to version.
+func (mg *Migrator) Goto(version uint) error {
+	if err := mg.m.Migrate(version); err != nil && err != migrate.ErrNoChange {
+		return errors.Wrapf(err, "unable to migrate schema to version %d", version)
+	}
+
+	return nil
+}
+
+// Force sets the schema version and clears the dirty flag without running
+// any migrations.  A version of -1 marks the schema as having no migrations
+// applied.
+func (mg *Migrator) Force(version int) error {
+	if version < -1 {
+		return errors.Errorf("invalid schema version: %d", version)
+	}
+
+	if err := mg.m.Force(version); err != nil {
+		return errors.Wrapf(err, "unable to force schema version to %d", version)
+	}
+
+	return nil
+}
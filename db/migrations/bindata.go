@@ -2,6 +2,10 @@
 // sources:
 // crdb/1517299952_init.down.sql
 // crdb/1517299952_init.up.sql
+// crdb/1536451200_vpc_account_id_fk.down.sql
+// crdb/1536451200_vpc_account_id_fk.up.sql
+// crdb/1539820800_vnic_mac.down.sql
+// crdb/1539820800_vnic_mac.up.sql
 // DO NOT EDIT!
 
 // Copyright (c) 2018 Joyent, Inc.
@@ -134,6 +138,86 @@ func _1517299952_initUpSql() (*asset, error) {
 	return a, nil
 }
 
+var __1536451200_vpc_account_id_fkDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x73\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x28\x2b\x48\x56\x70\x09\xf2\x0f\x50\x70\xf6\xf7\x0b\x0e\x09\x72\xf4\xf4\x0b\x01\x89\xc5\x27\x26\x27\xe7\x97\xe6\x95\xc4\x67\xa6\xc4\xa7\x65\x5b\x73\x39\xa2\x69\x71\x74\x71\x41\xd6\x81\xa2\x5a\xc1\xcd\x3f\xc8\xd5\xd3\xdd\x4f\xc1\xdb\x35\x52\x23\x33\x45\x53\x21\xc8\xd5\xcd\x35\xc8\xd5\xcf\xd9\x35\x18\xa6\x10\x24\x6c\xcd\x05\x00\xb0\xad\x8b\xa1\x88\x00\x00\x00")
+
+func _1536451200_vpc_account_id_fkDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1536451200_vpc_account_id_fkDownSql,
+		"1536451200_vpc_account_id_fk.down.sql",
+	)
+}
+
+func _1536451200_vpc_account_id_fkDownSql() (*asset, error) {
+	bytes, err := _1536451200_vpc_account_id_fkDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1536451200_vpc_account_id_fk.down.sql", size: 136, mode: os.FileMode(420), modTime: time.Unix(1519862399, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __1536451200_vpc_account_id_fkUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x65\x8f\x4d\x0a\xc2\x30\x10\x85\xf7\x3d\xc5\x5b\x2a\x58\x2f\xd0\x55\x6d\xa2\x14\xa5\x2d\x31\x08\xae\x4a\x4c\xa7\x34\x2a\x89\xb4\xb1\xe2\xed\x8d\x82\xa8\xb8\xfd\xde\xcf\xbc\x89\x63\xc8\x8e\xa0\xb4\x76\x57\xeb\x6b\xd3\xd4\xed\x09\xda\xd9\xc1\xf7\xca\x58\x0f\xd7\xc2\x07\x7d\xbc\x68\x78\x75\x38\x13\x7a\x6a\xa9\x27\xab\xa9\x79\xc2\xb9\x69\x60\x82\x99\x54\x13\xac\x51\x1c\xbf\xe0\xa7\x6d\x86\x5b\x67\x74\x17\x52\x47\xd2\x3e\x64\x68\xa4\xfe\x8e\x5d\x95\x05\xc1\x0d\x84\x9c\xe1\xa6\x06\x58\xe7\xa1\xce\x83\x83\xb2\xef\x2d\xcf\xb2\x9c\xcd\xa3\x74\x23\xb9\x80\x4c\x17\x1b\xfe\x9a\xc1\x44\x59\x21\x2b\x8b\xad\x14\x69\x5e\xc8\xdf\xe9\xc9\x9f\x3d\x65\xec\xdb\x1d\x50\xfd\xfb\xec\xb2\x14\x3c\x5f\x15\x58\xf3\xfd\xe4\xa3\x4c\x21\xf8\x92\x0b\x5e\x64\x7c\xfb\x3e\x31\x09\x38\x89\x1e\x1a\x51\x14\x0d\x32\x01\x00\x00")
+
+func _1536451200_vpc_account_id_fkUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1536451200_vpc_account_id_fkUpSql,
+		"1536451200_vpc_account_id_fk.up.sql",
+	)
+}
+
+func _1536451200_vpc_account_id_fkUpSql() (*asset, error) {
+	bytes, err := _1536451200_vpc_account_id_fkUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1536451200_vpc_account_id_fk.up.sql", size: 306, mode: os.FileMode(420), modTime: time.Unix(1519862399, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __1539820800_vnic_macDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\x03\x73\x09\xf2\x0f\x50\xf0\xf4\x73\x71\x8d\x50\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x28\xcb\xcb\x4c\x76\x00\x11\xf1\x89\xc9\xc9\xf9\xa5\x79\x25\xf1\x99\x29\xf1\xb9\x89\xc9\xf1\xd9\xa9\x95\xd6\x5c\x8e\x3e\x21\xae\x41\x0a\x21\x8e\x4e\x3e\xae\x60\x95\x0a\x2e\x20\x33\x9c\xfd\x7d\x42\x7d\xfd\x90\x0c\x01\xaa\xb7\xe6\x02\x00\x0a\x79\x04\xec\x5f\x00\x00\x00")
+
+func _1539820800_vnic_macDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1539820800_vnic_macDownSql,
+		"1539820800_vnic_mac.down.sql",
+	)
+}
+
+func _1539820800_vnic_macDownSql() (*asset, error) {
+	bytes, err := _1539820800_vnic_macDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1539820800_vnic_mac.down.sql", size: 95, mode: os.FileMode(420), modTime: time.Unix(1519862399, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __1539820800_vnic_macUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\x03\x55\x90\x41\x6e\x83\x30\x10\x45\xf7\x9c\xe2\x2f\x5b\xa9\xf4\x02\x59\x11\x70\x25\x24\x30\x6a\x62\x2a\x76\x68\x6a\x4c\xb0\x42\xec\xc8\x76\x1a\xe5\xf6\x35\xa0\xb6\xea\xca\x9e\xef\x79\x4f\x33\x4e\x53\x88\x49\xa1\xce\x72\xd0\x30\x38\xe5\x3d\xec\x08\xc2\x07\x2f\x73\x68\x8f\x59\x91\x57\x03\x46\x67\x2f\x20\x29\xed\xcd\x84\xfe\x42\x12\x9f\x0f\x84\xc8\xd1\x49\x99\x80\xfb\xa4\xcc\x52\x26\x69\xfa\x0b\x8e\xda\xf9\x80\xeb\x4c\x32\xe2\xd6\x44\x65\xce\x41\x66\x80\x0f\xd6\xc5\xe8\xae\xc3\xb4\x2a\x56\xc0\xdb\x78\xa7\xb0\x05\x35\x62\xb4\xb8\xce\x4a\x5d\x3d\x74\xf0\xff\xe6\x23\xe9\x6c\x3c\x9c\x92\xd6\x48\x3d\x6b\x0a\xda\x1a\xbf\xba\xb7\x71\x62\x57\x20\x17\xfc\x6b\x92\x55\x82\x1d\x20\xb2\x7d\xc5\xf0\x65\xb4\x44\x56\x14\xc8\x9b\xaa\xad\x39\x96\x2d\x04\xeb\xc4\x2e\xc9\x0f\x2c\x13\x0c\x2d\x2f\xdf\x5b\x86\x92\x17\xac\x43\xf9\x06\xde\x08\xb0\xae\x3c\x8a\xe3\xca\xf6\x3f\xeb\xeb\x61\xf9\x81\xfe\xac\x1e\x68\xf8\xa6\x7d\xfa\x7b\x7b\x59\xc4\xcf\xbb\xe4\x1b\x61\x57\x2d\xf1\x59\x01\x00\x00")
+
+func _1539820800_vnic_macUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1539820800_vnic_macUpSql,
+		"1539820800_vnic_mac.up.sql",
+	)
+}
+
+func _1539820800_vnic_macUpSql() (*asset, error) {
+	bytes, err := _1539820800_vnic_macUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1539820800_vnic_mac.up.sql", size: 345, mode: os.FileMode(420), modTime: time.Unix(1519862399, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 // Asset loads and returns the asset for the given name.
 // It returns an error if the asset could not be found or
 // could not be loaded.
@@ -188,6 +272,10 @@ func AssetNames() []string {
 var _bindata = map[string]func() (*asset, error){
 	"1517299952_init.down.sql": _1517299952_initDownSql,
 	"1517299952_init.up.sql": _1517299952_initUpSql,
+	"1536451200_vpc_account_id_fk.down.sql": _1536451200_vpc_account_id_fkDownSql,
+	"1536451200_vpc_account_id_fk.up.sql": _1536451200_vpc_account_id_fkUpSql,
+	"1539820800_vnic_mac.down.sql": _1539820800_vnic_macDownSql,
+	"1539820800_vnic_mac.up.sql": _1539820800_vnic_macUpSql,
 }
 
 // AssetDir returns the file names below a certain
@@ -232,6 +320,10 @@ type bintree struct {
 var _bintree = &bintree{nil, map[string]*bintree{
 	"1517299952_init.down.sql": &bintree{_1517299952_initDownSql, map[string]*bintree{}},
 	"1517299952_init.up.sql": &bintree{_1517299952_initUpSql, map[string]*bintree{}},
+	"1536451200_vpc_account_id_fk.down.sql": &bintree{_1536451200_vpc_account_id_fkDownSql, map[string]*bintree{}},
+	"1536451200_vpc_account_id_fk.up.sql": &bintree{_1536451200_vpc_account_id_fkUpSql, map[string]*bintree{}},
+	"1539820800_vnic_mac.down.sql": &bintree{_1539820800_vnic_macDownSql, map[string]*bintree{}},
+	"1539820800_vnic_mac.up.sql": &bintree{_1539820800_vnic_macUpSql, map[string]*bintree{}},
 }}
 
 // RestoreAsset restores an asset under the given directory
@@ -0,0 +1,2 @@
+ALTER TABLE vpc DROP CONSTRAINT vpc_account_id_fk;
+ALTER TABLE vpc ADD CONSTRAINT account_id_fk FOREIGN KEY(id) REFERENCES account(id);
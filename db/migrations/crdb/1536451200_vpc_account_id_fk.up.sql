@@ -0,0 +1,5 @@
+-- The account_id_fk constraint of the vpc table referenced vpc.id instead of
+-- vpc.account_id, which rejected every VPC whose ID was not also an account
+-- ID.
+ALTER TABLE vpc DROP CONSTRAINT account_id_fk;
+ALTER TABLE vpc ADD CONSTRAINT vpc_account_id_fk FOREIGN KEY(account_id) REFERENCES account(id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS vnic@vnic_account_id_mac_key;
+ALTER TABLE vnic DROP COLUMN IF EXISTS mac;
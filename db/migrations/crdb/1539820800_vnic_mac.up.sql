@@ -0,0 +1,5 @@
+-- The MAC address of a VNIC is leased from account_mac by the agent when the
+-- VNIC is first placed on a CN and stored with the VNIC so that the VM NIC
+-- keeps its MAC address across reconciliations and agent restarts.
+ALTER TABLE vnic ADD COLUMN mac TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS vnic_account_id_mac_key ON vnic (account_id, mac);
@@ -0,0 +1,183 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Org is a row in the org table.
+type Org struct {
+	ID   uuid.UUID
+	Name string
+}
+
+const orgColumns = `id, COALESCE(name, '')`
+
+func scanOrg(r rowScanner) (Org, error) {
+	var org Org
+	err := r.Scan(&org.ID, &org.Name)
+	return org, err
+}
+
+// CreateOrg inserts org.  If org.ID is uuid.Nil the database generates the ID
+// and org.ID is updated.
+func CreateOrg(ctx context.Context, q Querier, org *Org) error {
+	const sql = `INSERT INTO org (id, name) VALUES (COALESCE($1, gen_random_uuid()), $2) RETURNING id`
+	if err := q.QueryRowEx(ctx, sql, nil, nullID(org.ID), org.Name).Scan(&org.ID); err != nil {
+		return errors.Wrap(err, "unable to create org")
+	}
+
+	return nil
+}
+
+// GetOrg returns the org identified by id.
+func GetOrg(ctx context.Context, q Querier, id uuid.UUID) (Org, error) {
+	const sql = `SELECT ` + orgColumns + ` FROM org WHERE id = $1`
+	org, err := scanOrg(q.QueryRowEx(ctx, sql, nil, id))
+	if err != nil {
+		return Org{}, errors.Wrapf(notFound(err), "unable to get org %s", id)
+	}
+
+	return org, nil
+}
+
+// ListOrgs returns all orgs.
+func ListOrgs(ctx context.Context, q Querier) ([]Org, error) {
+	const sql = `SELECT ` + orgColumns + ` FROM org ORDER BY id`
+	var orgs []Org
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		org, err := scanOrg(r)
+		if err != nil {
+			return err
+		}
+		orgs = append(orgs, org)
+		return nil
+	}, sql)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list orgs")
+	}
+
+	return orgs, nil
+}
+
+// UpdateOrg updates the name of org.
+func UpdateOrg(ctx context.Context, q Querier, org Org) error {
+	const sql = `UPDATE org SET name = $2 WHERE id = $1`
+	if err := execOne(ctx, q, sql, org.ID, org.Name); err != nil {
+		return errors.Wrapf(err, "unable to update org %s", org.ID)
+	}
+
+	return nil
+}
+
+// DeleteOrg deletes the org identified by id.
+func DeleteOrg(ctx context.Context, q Querier, id uuid.UUID) error {
+	const sql = `DELETE FROM org WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete org %s", id)
+	}
+
+	return nil
+}
+
+// Account is a row in the account table.
+type Account struct {
+	ID    uuid.UUID
+	OrgID uuid.UUID
+	Name  string
+}
+
+const accountColumns = `id, org_id, COALESCE(name, '')`
+
+func scanAccount(r rowScanner) (Account, error) {
+	var account Account
+	err := r.Scan(&account.ID, &account.OrgID, &account.Name)
+	return account, err
+}
+
+// CreateAccount inserts account.  If account.ID is uuid.Nil the database
+// generates the ID and account.ID is updated.
+func CreateAccount(ctx context.Context, q Querier, account *Account) error {
+	const sql = `INSERT INTO account (id, org_id, name) VALUES (COALESCE($1, gen_random_uuid()), $2, $3) RETURNING id`
+	if err := q.QueryRowEx(ctx, sql, nil, nullID(account.ID), account.OrgID, account.Name).Scan(&account.ID); err != nil {
+		return errors.Wrapf(err, "unable to create account in org %s", account.OrgID)
+	}
+
+	return nil
+}
+
+// GetAccount returns the account identified by id.
+func GetAccount(ctx context.Context, q Querier, id uuid.UUID) (Account, error) {
+	const sql = `SELECT ` + accountColumns + ` FROM account WHERE id = $1`
+	account, err := scanAccount(q.QueryRowEx(ctx, sql, nil, id))
+	if err != nil {
+		return Account{}, errors.Wrapf(notFound(err), "unable to get account %s", id)
+	}
+
+	return account, nil
+}
+
+// ListAccounts returns the accounts in orgID.
+func ListAccounts(ctx context.Context, q Querier, orgID uuid.UUID) ([]Account, error) {
+	const sql = `SELECT ` + accountColumns + ` FROM account WHERE org_id = $1 ORDER BY id`
+	var accounts []Account
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		account, err := scanAccount(r)
+		if err != nil {
+			return err
+		}
+		accounts = append(accounts, account)
+		return nil
+	}, sql, orgID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list accounts in org %s", orgID)
+	}
+
+	return accounts, nil
+}
+
+// UpdateAccount updates the name of account.
+func UpdateAccount(ctx context.Context, q Querier, account Account) error {
+	const sql = `UPDATE account SET name = $2 WHERE id = $1`
+	if err := execOne(ctx, q, sql, account.ID, account.Name); err != nil {
+		return errors.Wrapf(err, "unable to update account %s", account.ID)
+	}
+
+	return nil
+}
+
+// DeleteAccount deletes the account identified by id.
+func DeleteAccount(ctx context.Context, q Querier, id uuid.UUID) error {
+	const sql = `DELETE FROM account WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete account %s", id)
+	}
+
+	return nil
+}
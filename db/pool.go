@@ -54,9 +54,13 @@ func New(cfg Config) (*Pool, error) {
 		config: cfg,
 	}
 
-	tlsConfig, err := cfg.TLSConfig()
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to generate a TLS config")
+	var tlsConfig *tls.Config
+	if cfg.UseTLSClientAuth {
+		var err error
+		tlsConfig, err = cfg.TLSConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to generate a TLS config")
+		}
 	}
 
 	const keepAliveTimeout = 5 * time.Minute
@@ -151,7 +155,7 @@ func (p *Pool) STDDB() (*sql.DB, error) {
 	database := p.config.Database
 
 	v := url.Values{}
-	if !p.config.InsecureSkipVerify {
+	if p.config.UseTLSClientAuth && !p.config.InsecureSkipVerify {
 		sslMode := "require"
 
 		v.Set("sslmode", sslMode)
@@ -0,0 +1,147 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/pgtype"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Querier is satisfied by *pgx.ConnPool, *pgx.Conn, and *pgx.Tx so that the
+// data access functions can be used inside or outside of a transaction.
+type Querier interface {
+	ExecEx(ctx context.Context, sql string, options *pgx.QueryExOptions, arguments ...interface{}) (pgx.CommandTag, error)
+	QueryEx(ctx context.Context, sql string, options *pgx.QueryExOptions, args ...interface{}) (*pgx.Rows, error)
+	QueryRowEx(ctx context.Context, sql string, options *pgx.QueryExOptions, args ...interface{}) *pgx.Row
+}
+
+var (
+	_ Querier = (*pgx.ConnPool)(nil)
+	_ Querier = (*pgx.Conn)(nil)
+	_ Querier = (*pgx.Tx)(nil)
+)
+
+// ErrNotFound is returned when a row does not exist.
+var ErrNotFound = errors.New("not found")
+
+// IsNotFound returns true if the cause of err is ErrNotFound.
+func IsNotFound(err error) bool {
+	cause := errors.Cause(err)
+	return cause == ErrNotFound || cause == pgx.ErrNoRows
+}
+
+// IsRetryable returns true if the cause of err is a serialization failure that
+// CockroachDB expects the client to retry.
+func IsRetryable(err error) bool {
+	pgErr, ok := errors.Cause(err).(pgx.PgError)
+	return ok && pgErr.Code == "40001"
+}
+
+// IsUniqueViolation returns true if the cause of err is a violation of a
+// unique constraint, e.g. a duplicate primary key.
+func IsUniqueViolation(err error) bool {
+	pgErr, ok := errors.Cause(err).(pgx.PgError)
+	return ok && pgErr.Code == "23505"
+}
+
+// DefaultExpireAfter is the schema's default expire_after interval for VNIs
+// and account MACs.  It is used when a model's ExpireAfter is zero.
+const DefaultExpireAfter = 90 * 24 * time.Hour
+
+// rowScanner is satisfied by *pgx.Row and *pgx.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// execOne executes a statement that is expected to modify exactly one row and
+// returns ErrNotFound if no rows were modified.
+func execOne(ctx context.Context, q Querier, sql string, args ...interface{}) error {
+	tag, err := q.ExecEx(ctx, sql, nil, args...)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// notFound maps pgx.ErrNoRows to ErrNotFound.
+func notFound(err error) error {
+	if err == pgx.ErrNoRows {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+// queryAll runs a query and calls scan for every row returned.
+func queryAll(ctx context.Context, q Querier, scan func(rowScanner) error, sql string, args ...interface{}) error {
+	rows, err := q.QueryEx(ctx, sql, nil, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// nullID returns a NULL UUID for uuid.Nil so that the database generates the
+// ID of a new row.
+func nullID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+// expireAfter returns d, or DefaultExpireAfter if d is zero.
+func expireAfter(d time.Duration) time.Duration {
+	if d == 0 {
+		return DefaultExpireAfter
+	}
+
+	return d
+}
+
+// intervalDuration converts an INTERVAL to a time.Duration.  pgtype refuses to
+// assign intervals with a day or month component to a time.Duration, so
+// months are treated as 30 days.
+func intervalDuration(i pgtype.Interval) time.Duration {
+	const day = 24 * time.Hour
+	return time.Duration(i.Microseconds)*time.Microsecond +
+		time.Duration(i.Days)*day +
+		time.Duration(i.Months)*30*day
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Region is a row in the region table.
+type Region struct {
+	ID string
+}
+
+// CreateRegion inserts region.
+func CreateRegion(ctx context.Context, q Querier, region Region) error {
+	const sql = `INSERT INTO region (id) VALUES ($1)`
+	if _, err := q.ExecEx(ctx, sql, nil, region.ID); err != nil {
+		return errors.Wrapf(err, "unable to create region %q", region.ID)
+	}
+
+	return nil
+}
+
+// GetRegion returns the region identified by id.
+func GetRegion(ctx context.Context, q Querier, id string) (Region, error) {
+	const sql = `SELECT id FROM region WHERE id = $1`
+	var region Region
+	if err := q.QueryRowEx(ctx, sql, nil, id).Scan(&region.ID); err != nil {
+		return Region{}, errors.Wrapf(notFound(err), "unable to get region %q", id)
+	}
+
+	return region, nil
+}
+
+// ListRegions returns all regions.
+func ListRegions(ctx context.Context, q Querier) ([]Region, error) {
+	const sql = `SELECT id FROM region ORDER BY id`
+	var regions []Region
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		var region Region
+		if err := r.Scan(&region.ID); err != nil {
+			return err
+		}
+		regions = append(regions, region)
+		return nil
+	}, sql)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list regions")
+	}
+
+	return regions, nil
+}
+
+// DeleteRegion deletes the region identified by id.  A region is nothing but
+// its ID so there is no UpdateRegion.
+func DeleteRegion(ctx context.Context, q Querier, id string) error {
+	const sql = `DELETE FROM region WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete region %q", id)
+	}
+
+	return nil
+}
+
+// Facility is a row in the facility table.
+type Facility struct {
+	ID       uuid.UUID
+	Name     string
+	RegionID string
+}
+
+const facilityColumns = `id, name, region_id`
+
+func scanFacility(r rowScanner) (Facility, error) {
+	var facility Facility
+	err := r.Scan(&facility.ID, &facility.Name, &facility.RegionID)
+	return facility, err
+}
+
+// CreateFacility inserts facility.  If facility.ID is uuid.Nil the database
+// generates the ID and facility.ID is updated.
+func CreateFacility(ctx context.Context, q Querier, facility *Facility) error {
+	const sql = `INSERT INTO facility (id, name, region_id) VALUES (COALESCE($1, gen_random_uuid()), $2, $3) RETURNING id`
+	if err := q.QueryRowEx(ctx, sql, nil, nullID(facility.ID), facility.Name, facility.RegionID).Scan(&facility.ID); err != nil {
+		return errors.Wrapf(err, "unable to create facility %q", facility.Name)
+	}
+
+	return nil
+}
+
+// GetFacility returns the facility identified by id.
+func GetFacility(ctx context.Context, q Querier, id uuid.UUID) (Facility, error) {
+	const sql = `SELECT ` + facilityColumns + ` FROM facility WHERE id = $1`
+	facility, err := scanFacility(q.QueryRowEx(ctx, sql, nil, id))
+	if err != nil {
+		return Facility{}, errors.Wrapf(notFound(err), "unable to get facility %s", id)
+	}
+
+	return facility, nil
+}
+
+// GetFacilityByName returns the facility named name.
+func GetFacilityByName(ctx context.Context, q Querier, name string) (Facility, error) {
+	const sql = `SELECT ` + facilityColumns + ` FROM facility WHERE name = $1`
+	facility, err := scanFacility(q.QueryRowEx(ctx, sql, nil, name))
+	if err != nil {
+		return Facility{}, errors.Wrapf(notFound(err), "unable to get facility %q", name)
+	}
+
+	return facility, nil
+}
+
+// ListFacilities returns the facilities in regionID.
+func ListFacilities(ctx context.Context, q Querier, regionID string) ([]Facility, error) {
+	const sql = `SELECT ` + facilityColumns + ` FROM facility WHERE region_id = $1 ORDER BY name`
+	var facilities []Facility
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		facility, err := scanFacility(r)
+		if err != nil {
+			return err
+		}
+		facilities = append(facilities, facility)
+		return nil
+	}, sql, regionID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list facilities in region %q", regionID)
+	}
+
+	return facilities, nil
+}
+
+// UpdateFacility updates the name of facility.
+func UpdateFacility(ctx context.Context, q Querier, facility Facility) error {
+	const sql = `UPDATE facility SET name = $2 WHERE id = $1`
+	if err := execOne(ctx, q, sql, facility.ID, facility.Name); err != nil {
+		return errors.Wrapf(err, "unable to update facility %s", facility.ID)
+	}
+
+	return nil
+}
+
+// DeleteFacility deletes the facility identified by id.
+func DeleteFacility(ctx context.Context, q Querier, id uuid.UUID) error {
+	const sql = `DELETE FROM facility WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete facility %s", id)
+	}
+
+	return nil
+}
+
+// AZ is a row in the az table.  Name is a single letter from "a" to "i".
+type AZ struct {
+	ID       uuid.UUID
+	RegionID string
+	Name     string
+}
+
+const azColumns = `id, region_id, name`
+
+func scanAZ(r rowScanner) (AZ, error) {
+	var az AZ
+	err := r.Scan(&az.ID, &az.RegionID, &az.Name)
+	return az, err
+}
+
+// CreateAZ inserts az.  If az.ID is uuid.Nil the database generates the ID and
+// az.ID is updated.
+func CreateAZ(ctx context.Context, q Querier, az *AZ) error {
+	const sql = `INSERT INTO az (id, region_id, name) VALUES (COALESCE($1, gen_random_uuid()), $2, $3) RETURNING id`
+	if err := q.QueryRowEx(ctx, sql, nil, nullID(az.ID), az.RegionID, az.Name).Scan(&az.ID); err != nil {
+		return errors.Wrapf(err, "unable to create AZ %q in region %q", az.Name, az.RegionID)
+	}
+
+	return nil
+}
+
+// GetAZ returns the AZ identified by id.
+func GetAZ(ctx context.Context, q Querier, id uuid.UUID) (AZ, error) {
+	const sql = `SELECT ` + azColumns + ` FROM az WHERE id = $1`
+	az, err := scanAZ(q.QueryRowEx(ctx, sql, nil, id))
+	if err != nil {
+		return AZ{}, errors.Wrapf(notFound(err), "unable to get AZ %s", id)
+	}
+
+	return az, nil
+}
+
+// ListAZs returns the AZs in regionID.
+func ListAZs(ctx context.Context, q Querier, regionID string) ([]AZ, error) {
+	const sql = `SELECT ` + azColumns + ` FROM az WHERE region_id = $1 ORDER BY name`
+	var azs []AZ
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		az, err := scanAZ(r)
+		if err != nil {
+			return err
+		}
+		azs = append(azs, az)
+		return nil
+	}, sql, regionID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list AZs in region %q", regionID)
+	}
+
+	return azs, nil
+}
+
+// UpdateAZ updates the name of az.
+func UpdateAZ(ctx context.Context, q Querier, az AZ) error {
+	const sql = `UPDATE az SET name = $2 WHERE id = $1`
+	if err := execOne(ctx, q, sql, az.ID, az.Name); err != nil {
+		return errors.Wrapf(err, "unable to update AZ %s", az.ID)
+	}
+
+	return nil
+}
+
+// DeleteAZ deletes the AZ identified by id.
+func DeleteAZ(ctx context.Context, q Querier, id uuid.UUID) error {
+	const sql = `DELETE FROM az WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete AZ %s", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package db
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Router is a row in the router table.
+type Router struct {
+	ID    uuid.UUID
+	VPCID uuid.UUID
+}
+
+const routerColumns = `id, vpc_id`
+
+func scanRouter(r rowScanner) (Router, error) {
+	var router Router
+	err := r.Scan(&router.ID, &router.VPCID)
+	return router, err
+}
+
+// CreateRouter inserts router.  If router.ID is uuid.Nil the database
+// generates the ID and router.ID is updated.
+func CreateRouter(ctx context.Context, q Querier, router *Router) error {
+	const sql = `INSERT INTO router (id, vpc_id) VALUES (COALESCE($1, gen_random_uuid()), $2) RETURNING id`
+	if err := q.QueryRowEx(ctx, sql, nil, nullID(router.ID), router.VPCID).Scan(&router.ID); err != nil {
+		return errors.Wrapf(err, "unable to create router in VPC %s", router.VPCID)
+	}
+
+	return nil
+}
+
+// GetRouter returns the router identified by id.
+func GetRouter(ctx context.Context, q Querier, id uuid.UUID) (Router, error) {
+	const sql = `SELECT ` + routerColumns + ` FROM router WHERE id = $1`
+	router, err := scanRouter(q.QueryRowEx(ctx, sql, nil, id))
+	if err != nil {
+		return Router{}, errors.Wrapf(notFound(err), "unable to get router %s", id)
+	}
+
+	return router, nil
+}
+
+// ListRouters returns the routers in vpcID.
+func ListRouters(ctx context.Context, q Querier, vpcID uuid.UUID) ([]Router, error) {
+	const sql = `SELECT ` + routerColumns + ` FROM router WHERE vpc_id = $1 ORDER BY id`
+	var routers []Router
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		router, err := scanRouter(r)
+		if err != nil {
+			return err
+		}
+		routers = append(routers, router)
+		return nil
+	}, sql, vpcID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list routers in VPC %s", vpcID)
+	}
+
+	return routers, nil
+}
+
+// UpdateRouter moves router to router.VPCID.
+func UpdateRouter(ctx context.Context, q Querier, router Router) error {
+	const sql = `UPDATE router SET vpc_id = $2 WHERE id = $1`
+	if err := execOne(ctx, q, sql, router.ID, router.VPCID); err != nil {
+		return errors.Wrapf(err, "unable to update router %s", router.ID)
+	}
+
+	return nil
+}
+
+// DeleteRouter deletes the router identified by id.
+func DeleteRouter(ctx context.Context, q Querier, id uuid.UUID) error {
+	const sql = `DELETE FROM router WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete router %s", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package db
+
+import (
+	"context"
+	"net"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// SecurityGroup is a row in the security_group table.
+type SecurityGroup struct {
+	ID          uuid.UUID
+	AccountID   uuid.UUID
+	Name        string
+	Description string
+}
+
+const securityGroupColumns = `id, account_id, COALESCE(name, ''), COALESCE(description, '')`
+
+func scanSecurityGroup(r rowScanner) (SecurityGroup, error) {
+	var sg SecurityGroup
+	err := r.Scan(&sg.ID, &sg.AccountID, &sg.Name, &sg.Description)
+	return sg, err
+}
+
+// CreateSecurityGroup inserts sg.  If sg.ID is uuid.Nil the database generates
+// the ID and sg.ID is updated.
+func CreateSecurityGroup(ctx context.Context, q Querier, sg *SecurityGroup) error {
+	const sql = `INSERT INTO security_group (id, account_id, name, description) VALUES (COALESCE($1, gen_random_uuid()), $2, $3, $4) RETURNING id`
+	if err := q.QueryRowEx(ctx, sql, nil, nullID(sg.ID), sg.AccountID, sg.Name, sg.Description).Scan(&sg.ID); err != nil {
+		return errors.Wrapf(err, "unable to create security group in account %s", sg.AccountID)
+	}
+
+	return nil
+}
+
+// GetSecurityGroup returns the security group identified by id.
+func GetSecurityGroup(ctx context.Context, q Querier, id uuid.UUID) (SecurityGroup, error) {
+	const sql = `SELECT ` + securityGroupColumns + ` FROM security_group WHERE id = $1`
+	sg, err := scanSecurityGroup(q.QueryRowEx(ctx, sql, nil, id))
+	if err != nil {
+		return SecurityGroup{}, errors.Wrapf(notFound(err), "unable to get security group %s", id)
+	}
+
+	return sg, nil
+}
+
+// ListSecurityGroups returns the security groups in accountID.
+func ListSecurityGroups(ctx context.Context, q Querier, accountID uuid.UUID) ([]SecurityGroup, error) {
+	const sql = `SELECT ` + securityGroupColumns + ` FROM security_group WHERE account_id = $1 ORDER BY id`
+	var sgs []SecurityGroup
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		sg, err := scanSecurityGroup(r)
+		if err != nil {
+			return err
+		}
+		sgs = append(sgs, sg)
+		return nil
+	}, sql, accountID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list security groups in account %s", accountID)
+	}
+
+	return sgs, nil
+}
+
+// UpdateSecurityGroup updates the name and description of sg.
+func UpdateSecurityGroup(ctx context.Context, q Querier, sg SecurityGroup) error {
+	const sql = `UPDATE security_group SET name = $2, description = $3 WHERE id = $1`
+	if err := execOne(ctx, q, sql, sg.ID, sg.Name, sg.Description); err != nil {
+		return errors.Wrapf(err, "unable to update security group %s", sg.ID)
+	}
+
+	return nil
+}
+
+// DeleteSecurityGroup deletes the security group identified by id.
+func DeleteSecurityGroup(ctx context.Context, q Querier, id uuid.UUID) error {
+	const sql = `DELETE FROM security_group WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete security group %s", id)
+	}
+
+	return nil
+}
+
+// Security group rule directions.
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+// SecurityGroupRule is a row in the security_group_rule table.  Nil pointers
+// and invalid UUIDs are stored as NULL and match anything.  When Protocol is 1
+// (ICMP) SrcPortStart and SrcPortEnd hold the ICMP type and code.
+type SecurityGroupRule struct {
+	ID              uuid.UUID
+	SecurityGroupID uuid.UUID
+	Direction       string
+
+	Protocol     *int32
+	SrcPortStart *int32
+	SrcPortEnd   *int32
+	DstPortStart *int32
+	DstPortEnd   *int32
+
+	SrcCIDR *net.IPNet
+	DstCIDR *net.IPNet
+
+	SrcSecurityGroupID uuid.NullUUID
+	DstSecurityGroupID uuid.NullUUID
+	SrcVPCID           uuid.NullUUID
+	DstVPCID           uuid.NullUUID
+	SrcSubnetID        uuid.NullUUID
+	DstSubnetID        uuid.NullUUID
+	SrcAZID            uuid.NullUUID
+	DstAZID            uuid.NullUUID
+}
+
+const securityGroupRuleColumns = `id, security_group_id, direction, protocol, ` +
+	`src_port_start, src_port_end, dst_port_start, dst_port_end, src_cidr, dst_cidr, ` +
+	`src_security_group_id, dst_security_group_id, src_vpc_id, dst_vpc_id, ` +
+	`src_subnet_id, dst_subnet_id, src_az_id, dst_az_id`
+
+func scanSecurityGroupRule(r rowScanner) (SecurityGroupRule, error) {
+	var rule SecurityGroupRule
+	var srcCIDR, dstCIDR *string
+	err := r.Scan(&rule.ID, &rule.SecurityGroupID, &rule.Direction, &rule.Protocol,
+		&rule.SrcPortStart, &rule.SrcPortEnd, &rule.DstPortStart, &rule.DstPortEnd, &srcCIDR, &dstCIDR,
+		&rule.SrcSecurityGroupID, &rule.DstSecurityGroupID, &rule.SrcVPCID, &rule.DstVPCID,
+		&rule.SrcSubnetID, &rule.DstSubnetID, &rule.SrcAZID, &rule.DstAZID)
+	if err != nil {
+		return SecurityGroupRule{}, err
+	}
+
+	if rule.SrcCIDR, err = parseNullCIDR(srcCIDR); err != nil {
+		return SecurityGroupRule{}, err
+	}
+
+	if rule.DstCIDR, err = parseNullCIDR(dstCIDR); err != nil {
+		return SecurityGroupRule{}, err
+	}
+
+	return rule, nil
+}
+
+func parseNullCIDR(s *string) (*net.IPNet, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(*s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid CIDR %q", *s)
+	}
+
+	return ipNet, nil
+}
+
+func nullCIDR(ipNet *net.IPNet) *string {
+	if ipNet == nil {
+		return nil
+	}
+
+	s := ipNet.String()
+	return &s
+}
+
+// CreateSecurityGroupRule inserts rule.  If rule.ID is uuid.Nil the database
+// generates the ID and rule.ID is updated.  If rule.Direction is empty it
+// defaults to DirectionIn.
+func CreateSecurityGroupRule(ctx context.Context, q Querier, rule *SecurityGroupRule) error {
+	const sql = `INSERT INTO security_group_rule (` + securityGroupRuleColumns + `) VALUES ` +
+		`(COALESCE($1, gen_random_uuid()), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18) RETURNING id`
+	if rule.Direction == "" {
+		rule.Direction = DirectionIn
+	}
+
+	err := q.QueryRowEx(ctx, sql, nil, nullID(rule.ID), rule.SecurityGroupID, rule.Direction, rule.Protocol,
+		rule.SrcPortStart, rule.SrcPortEnd, rule.DstPortStart, rule.DstPortEnd, nullCIDR(rule.SrcCIDR), nullCIDR(rule.DstCIDR),
+		rule.SrcSecurityGroupID, rule.DstSecurityGroupID, rule.SrcVPCID, rule.DstVPCID,
+		rule.SrcSubnetID, rule.DstSubnetID, rule.SrcAZID, rule.DstAZID).Scan(&rule.ID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create rule in security group %s", rule.SecurityGroupID)
+	}
+
+	return nil
+}
+
+// GetSecurityGroupRule returns the rule identified by id.
+func GetSecurityGroupRule(ctx context.Context, q Querier, id uuid.UUID) (SecurityGroupRule, error) {
+	const sql = `SELECT ` + securityGroupRuleColumns + ` FROM security_group_rule WHERE id = $1`
+	rule, err := scanSecurityGroupRule(q.QueryRowEx(ctx, sql, nil, id))
+	if err != nil {
+		return SecurityGroupRule{}, errors.Wrapf(notFound(err), "unable to get security group rule %s", id)
+	}
+
+	return rule, nil
+}
+
+// ListSecurityGroupRules returns the rules in securityGroupID.
+func ListSecurityGroupRules(ctx context.Context, q Querier, securityGroupID uuid.UUID) ([]SecurityGroupRule, error) {
+	const sql = `SELECT ` + securityGroupRuleColumns + ` FROM security_group_rule WHERE security_group_id = $1 ORDER BY id`
+	var rules []SecurityGroupRule
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		rule, err := scanSecurityGroupRule(r)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, rule)
+		return nil
+	}, sql, securityGroupID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list rules in security group %s", securityGroupID)
+	}
+
+	return rules, nil
+}
+
+// UpdateSecurityGroupRule replaces the match criteria of rule.
+func UpdateSecurityGroupRule(ctx context.Context, q Querier, rule SecurityGroupRule) error {
+	const sql = `UPDATE security_group_rule SET direction = $2, protocol = $3, ` +
+		`src_port_start = $4, src_port_end = $5, dst_port_start = $6, dst_port_end = $7, src_cidr = $8, dst_cidr = $9, ` +
+		`src_security_group_id = $10, dst_security_group_id = $11, src_vpc_id = $12, dst_vpc_id = $13, ` +
+		`src_subnet_id = $14, dst_subnet_id = $15, src_az_id = $16, dst_az_id = $17 WHERE id = $1`
+	err := execOne(ctx, q, sql, rule.ID, rule.Direction, rule.Protocol,
+		rule.SrcPortStart, rule.SrcPortEnd, rule.DstPortStart, rule.DstPortEnd, nullCIDR(rule.SrcCIDR), nullCIDR(rule.DstCIDR),
+		rule.SrcSecurityGroupID, rule.DstSecurityGroupID, rule.SrcVPCID, rule.DstVPCID,
+		rule.SrcSubnetID, rule.DstSubnetID, rule.SrcAZID, rule.DstAZID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to update security group rule %s", rule.ID)
+	}
+
+	return nil
+}
+
+// DeleteSecurityGroupRule deletes the rule identified by id.
+func DeleteSecurityGroupRule(ctx context.Context, q Querier, id uuid.UUID) error {
+	const sql = `DELETE FROM security_group_rule WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete security group rule %s", id)
+	}
+
+	return nil
+}
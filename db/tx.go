@@ -0,0 +1,85 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// ExecuteTx runs fn in a transaction using CockroachDB's client-side retry
+// protocol: fn is re-run from the cockroach_restart savepoint for as long as
+// the transaction fails with a retryable error.  fn must be idempotent and
+// must not have side effects outside of the transaction.
+func (p *Pool) ExecuteTx(ctx context.Context, fn func(tx *pgx.Tx) error) (err error) {
+	tx, err := p.pool.BeginEx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to begin transaction")
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		if rbErr := tx.RollbackEx(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Warn().Err(rbErr).Msg("unable to roll back transaction")
+		}
+	}()
+
+	if _, err := tx.ExecEx(ctx, "SAVEPOINT cockroach_restart", nil); err != nil {
+		return errors.Wrap(err, "unable to create transaction savepoint")
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = fn(tx)
+		if err == nil {
+			_, err = tx.ExecEx(ctx, "RELEASE SAVEPOINT cockroach_restart", nil)
+			if err == nil {
+				if err = tx.CommitEx(ctx); err != nil {
+					return errors.Wrap(err, "unable to commit transaction")
+				}
+
+				return nil
+			}
+		}
+
+		if !IsRetryable(err) {
+			return err
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return errors.Wrapf(ctxErr, "transaction aborted after %d attempts", attempt)
+		}
+
+		log.Debug().Err(err).Int("attempt", attempt).Msg("retrying transaction")
+
+		if _, rbErr := tx.ExecEx(ctx, "ROLLBACK TO SAVEPOINT cockroach_restart", nil); rbErr != nil {
+			return errors.Wrap(rbErr, "unable to roll back to transaction savepoint")
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package db
+
+import (
+	"context"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// ErrVNIExhausted is returned when every VNI in a facility is either
+	// leased or tombstoned.
+	ErrVNIExhausted = errors.New("no VNIs available")
+
+	// errVNITaken is returned by lease when the VNI it picked was leased by
+	// a concurrent transaction.
+	errVNITaken = errors.New("VNI leased concurrently")
+)
+
+// VNIAllocator leases VNIs in a facility to VPCs.  Released VNIs are
+// tombstoned and are not handed back out until expired_at + expire_after has
+// passed so that a VNI is not reused before the state of the system
+// converges.
+type VNIAllocator struct {
+	pool *Pool
+
+	// Min and Max are the inclusive bounds of the VNIs handed out.  The vni
+	// table does not permit a VNI of 0, which vpc.VNIMin uses to mean "no
+	// VNI".
+	Min vpc.VNI
+	Max vpc.VNI
+
+	// Attempts is the number of times a lease is retried when the VNI it
+	// picked is leased by a concurrent transaction.
+	Attempts int
+}
+
+// NewVNIAllocator returns a VNIAllocator that allocates from the full range of
+// VNIs permitted by the vni table.
+func NewVNIAllocator(pool *Pool) *VNIAllocator {
+	min := vpc.VNIMin
+	if min < 1 {
+		min = 1
+	}
+
+	return &VNIAllocator{
+		pool:     pool,
+		Min:      min,
+		Max:      vpc.VNIMax,
+		Attempts: 16,
+	}
+}
+
+// Lease atomically leases a VNI in facilityID to vpcID.  Expired tombstones are
+// reused before new VNIs are added to the vni table.  A VNI leased by a
+// concurrent transaction is skipped in favor of the next candidate.
+func (a *VNIAllocator) Lease(ctx context.Context, facilityID, vpcID uuid.UUID) (vni vpc.VNI, err error) {
+	if a.Min < 1 || a.Min > a.Max || a.Max > vpc.VNIMax {
+		return 0, errors.Errorf("invalid VNI range [%d, %d]", a.Min, a.Max)
+	}
+
+	// A failed INSERT aborts the transaction, so the next candidate is
+	// searched for in a new one.
+	for i := 0; i < a.Attempts; i++ {
+		err = a.pool.ExecuteTx(ctx, func(tx *pgx.Tx) error {
+			var err error
+			vni, err = a.lease(ctx, tx, facilityID, vpcID)
+			return err
+		})
+		if errors.Cause(err) != errVNITaken {
+			break
+		}
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to lease a VNI in facility %s", facilityID)
+	}
+
+	return vni, nil
+}
+
+func (a *VNIAllocator) lease(ctx context.Context, tx *pgx.Tx, facilityID, vpcID uuid.UUID) (vpc.VNI, error) {
+	const reuseSQL = `SELECT vni FROM vni ` +
+		`WHERE facility_id = $1 AND vni BETWEEN $2 AND $3 AND vpc_id IS NULL ` +
+		`AND (expired_at IS NULL OR expired_at + expire_after <= now()) ` +
+		`ORDER BY vni LIMIT 1`
+	const claimSQL = `UPDATE vni SET vpc_id = $3, expired_at = NULL ` +
+		`WHERE facility_id = $1 AND vni = $2 AND vpc_id IS NULL ` +
+		`AND (expired_at IS NULL OR expired_at + expire_after <= now())`
+	var reuse int32
+	switch err := tx.QueryRowEx(ctx, reuseSQL, nil, facilityID, int32(a.Min), int32(a.Max)).Scan(&reuse); err {
+	case nil:
+		switch err := execOne(ctx, tx, claimSQL, facilityID, reuse, vpcID); err {
+		case nil:
+			return vpc.VNI(reuse), nil
+		case ErrNotFound:
+			return 0, errVNITaken
+		default:
+			return 0, errors.Wrapf(err, "unable to claim VNI %d", reuse)
+		}
+	case pgx.ErrNoRows:
+	default:
+		return 0, errors.Wrap(err, "unable to search for expired VNIs")
+	}
+
+	// Find the lowest VNI in range that has never been used in this facility.
+	// Candidates are Min and every successor of a VNI already in use.
+	const newSQL = `SELECT c.candidate FROM (` +
+		`SELECT $2::INT AS candidate ` +
+		`UNION ALL ` +
+		`SELECT vni + 1 FROM vni WHERE facility_id = $1 AND vni >= $2 AND vni < $3` +
+		`) AS c ` +
+		`LEFT JOIN vni AS v ON v.facility_id = $1 AND v.vni = c.candidate ` +
+		`WHERE v.vni IS NULL ` +
+		`ORDER BY c.candidate LIMIT 1`
+	var candidate int32
+	switch err := tx.QueryRowEx(ctx, newSQL, nil, facilityID, int32(a.Min), int32(a.Max)).Scan(&candidate); err {
+	case nil:
+	case pgx.ErrNoRows:
+		return 0, ErrVNIExhausted
+	default:
+		return 0, errors.Wrap(err, "unable to search for unused VNIs")
+	}
+
+	err := CreateVNI(ctx, tx, VNI{
+		FacilityID: facilityID,
+		VNI:        candidate,
+		VPCID:      uuid.NullUUID{UUID: vpcID, Valid: true},
+	})
+	switch {
+	case err == nil:
+		return vpc.VNI(candidate), nil
+	case IsUniqueViolation(err):
+		return 0, errVNITaken
+	default:
+		return 0, err
+	}
+}
+
+// Release returns vni in facilityID to the allocator.  The VNI is tombstoned
+// and will not be leased again until its expire_after interval has passed.
+// ErrNotFound is returned if vni is not leased.
+func (a *VNIAllocator) Release(ctx context.Context, facilityID uuid.UUID, vni vpc.VNI) error {
+	const sql = `UPDATE vni SET vpc_id = NULL, expired_at = now() ` +
+		`WHERE facility_id = $1 AND vni = $2 AND vpc_id IS NOT NULL`
+	err := a.pool.ExecuteTx(ctx, func(tx *pgx.Tx) error {
+		return execOne(ctx, tx, sql, facilityID, int32(vni))
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to release VNI %d in facility %s", vni, facilityID)
+	}
+
+	return nil
+}
+
+// Cancel returns a vni that was leased but never used, e.g. because the switch
+// it was leased for could not be created, to the allocator.  Unlike Release
+// the VNI is not tombstoned and can be leased again immediately.
+// ErrNotFound is returned if vni is not leased.
+func (a *VNIAllocator) Cancel(ctx context.Context, facilityID uuid.UUID, vni vpc.VNI) error {
+	const sql = `DELETE FROM vni WHERE facility_id = $1 AND vni = $2 AND vpc_id IS NOT NULL`
+	err := a.pool.ExecuteTx(ctx, func(tx *pgx.Tx) error {
+		return execOne(ctx, tx, sql, facilityID, int32(vni))
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to cancel the lease of VNI %d in facility %s", vni, facilityID)
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package db
+
+import (
+	"context"
+	"net"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// VNIC is a row in the vnic table.  ObjID and ObjType are valid when the VNIC
+// is attached to an object.  ObjType references the obj_type table, see
+// GetObjTypeID.
+type VNIC struct {
+	ID        uuid.UUID
+	AccountID uuid.UUID
+	ObjID     uuid.NullUUID
+	ObjType   uuid.NullUUID
+}
+
+const vnicColumns = `id, account_id, obj_id, obj_type`
+
+func scanVNIC(r rowScanner) (VNIC, error) {
+	var vnic VNIC
+	err := r.Scan(&vnic.ID, &vnic.AccountID, &vnic.ObjID, &vnic.ObjType)
+	return vnic, err
+}
+
+// GetObjTypeID returns the ID of the obj_type row named name (e.g. "vm" or
+// "router").
+func GetObjTypeID(ctx context.Context, q Querier, name string) (uuid.UUID, error) {
+	const sql = `SELECT id FROM obj_type WHERE name = $1`
+	var id uuid.UUID
+	if err := q.QueryRowEx(ctx, sql, nil, name).Scan(&id); err != nil {
+		return uuid.Nil, errors.Wrapf(notFound(err), "unable to get object type %q", name)
+	}
+
+	return id, nil
+}
+
+// CreateVNIC inserts vnic.  If vnic.ID is uuid.Nil the database generates the
+// ID and vnic.ID is updated.
+func CreateVNIC(ctx context.Context, q Querier, vnic *VNIC) error {
+	const sql = `INSERT INTO vnic (id, account_id, obj_id, obj_type) VALUES (COALESCE($1, gen_random_uuid()), $2, $3, $4) RETURNING id`
+	if err := q.QueryRowEx(ctx, sql, nil, nullID(vnic.ID), vnic.AccountID, vnic.ObjID, vnic.ObjType).Scan(&vnic.ID); err != nil {
+		return errors.Wrapf(err, "unable to create VNIC in account %s", vnic.AccountID)
+	}
+
+	return nil
+}
+
+// GetVNIC returns the VNIC identified by id.
+func GetVNIC(ctx context.Context, q Querier, id uuid.UUID) (VNIC, error) {
+	const sql = `SELECT ` + vnicColumns + ` FROM vnic WHERE id = $1`
+	vnic, err := scanVNIC(q.QueryRowEx(ctx, sql, nil, id))
+	if err != nil {
+		return VNIC{}, errors.Wrapf(notFound(err), "unable to get VNIC %s", id)
+	}
+
+	return vnic, nil
+}
+
+// ListVNICs returns the VNICs in accountID.
+func ListVNICs(ctx context.Context, q Querier, accountID uuid.UUID) ([]VNIC, error) {
+	const sql = `SELECT ` + vnicColumns + ` FROM vnic WHERE account_id = $1 ORDER BY id`
+	var vnics []VNIC
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		vnic, err := scanVNIC(r)
+		if err != nil {
+			return err
+		}
+		vnics = append(vnics, vnic)
+		return nil
+	}, sql, accountID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list VNICs in account %s", accountID)
+	}
+
+	return vnics, nil
+}
+
+// UpdateVNIC updates the object vnic is attached to.
+func UpdateVNIC(ctx context.Context, q Querier, vnic VNIC) error {
+	const sql = `UPDATE vnic SET obj_id = $2, obj_type = $3 WHERE id = $1`
+	if err := execOne(ctx, q, sql, vnic.ID, vnic.ObjID, vnic.ObjType); err != nil {
+		return errors.Wrapf(err, "unable to update VNIC %s", vnic.ID)
+	}
+
+	return nil
+}
+
+// SetVNICMAC records mac as the MAC address of the VNIC identified by id.
+// ErrNotFound is returned if the VNIC does not exist or already has a MAC
+// address.
+func SetVNICMAC(ctx context.Context, q Querier, id uuid.UUID, mac net.HardwareAddr) error {
+	const sql = `UPDATE vnic SET mac = $2 WHERE id = $1 AND mac IS NULL`
+	if err := execOne(ctx, q, sql, id, mac.String()); err != nil {
+		return errors.Wrapf(err, "unable to set MAC %s of VNIC %s", mac, id)
+	}
+
+	return nil
+}
+
+// DeleteVNIC deletes the VNIC identified by id.
+func DeleteVNIC(ctx context.Context, q Querier, id uuid.UUID) error {
+	const sql = `DELETE FROM vnic WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete VNIC %s", id)
+	}
+
+	return nil
+}
+
+// VNICIP is a row in the vnic_ip table.  IPID references SubnetIP.ID.
+type VNICIP struct {
+	VNICID  uuid.UUID
+	IPID    uuid.UUID
+	IPIndex int
+}
+
+const vnicIPColumns = `vnic_id, ip_id, ip_index`
+
+func scanVNICIP(r rowScanner) (VNICIP, error) {
+	var ip VNICIP
+	err := r.Scan(&ip.VNICID, &ip.IPID, &ip.IPIndex)
+	return ip, err
+}
+
+// CreateVNICIP inserts ip.
+func CreateVNICIP(ctx context.Context, q Querier, ip VNICIP) error {
+	const sql = `INSERT INTO vnic_ip (vnic_id, ip_id, ip_index) VALUES ($1, $2, $3)`
+	if _, err := q.ExecEx(ctx, sql, nil, ip.VNICID, ip.IPID, ip.IPIndex); err != nil {
+		return errors.Wrapf(err, "unable to add IP %s to VNIC %s", ip.IPID, ip.VNICID)
+	}
+
+	return nil
+}
+
+// GetVNICIP returns the VNIC IP identified by vnicID and ipID.
+func GetVNICIP(ctx context.Context, q Querier, vnicID, ipID uuid.UUID) (VNICIP, error) {
+	const sql = `SELECT ` + vnicIPColumns + ` FROM vnic_ip WHERE vnic_id = $1 AND ip_id = $2`
+	ip, err := scanVNICIP(q.QueryRowEx(ctx, sql, nil, vnicID, ipID))
+	if err != nil {
+		return VNICIP{}, errors.Wrapf(notFound(err), "unable to get IP %s on VNIC %s", ipID, vnicID)
+	}
+
+	return ip, nil
+}
+
+// ListVNICIPs returns the IPs of vnicID ordered by index.
+func ListVNICIPs(ctx context.Context, q Querier, vnicID uuid.UUID) ([]VNICIP, error) {
+	const sql = `SELECT ` + vnicIPColumns + ` FROM vnic_ip WHERE vnic_id = $1 ORDER BY ip_index`
+	var ips []VNICIP
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		ip, err := scanVNICIP(r)
+		if err != nil {
+			return err
+		}
+		ips = append(ips, ip)
+		return nil
+	}, sql, vnicID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list IPs on VNIC %s", vnicID)
+	}
+
+	return ips, nil
+}
+
+// UpdateVNICIP updates the index of ip.
+func UpdateVNICIP(ctx context.Context, q Querier, ip VNICIP) error {
+	const sql = `UPDATE vnic_ip SET ip_index = $3 WHERE vnic_id = $1 AND ip_id = $2`
+	if err := execOne(ctx, q, sql, ip.VNICID, ip.IPID, ip.IPIndex); err != nil {
+		return errors.Wrapf(err, "unable to update IP %s on VNIC %s", ip.IPID, ip.VNICID)
+	}
+
+	return nil
+}
+
+// DeleteVNICIP deletes the VNIC IP identified by vnicID and ipID.
+func DeleteVNICIP(ctx context.Context, q Querier, vnicID, ipID uuid.UUID) error {
+	const sql = `DELETE FROM vnic_ip WHERE vnic_id = $1 AND ip_id = $2`
+	if err := execOne(ctx, q, sql, vnicID, ipID); err != nil {
+		return errors.Wrapf(err, "unable to remove IP %s from VNIC %s", ipID, vnicID)
+	}
+
+	return nil
+}
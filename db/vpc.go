@@ -0,0 +1,528 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package db
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/jackc/pgx/pgtype"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// VPC is a row in the vpc table.
+type VPC struct {
+	ID        uuid.UUID
+	AccountID uuid.UUID
+	Name      string
+}
+
+const vpcColumns = `id, account_id, COALESCE(name, '')`
+
+func scanVPC(r rowScanner) (VPC, error) {
+	var vpc VPC
+	err := r.Scan(&vpc.ID, &vpc.AccountID, &vpc.Name)
+	return vpc, err
+}
+
+// CreateVPC inserts vpc.  If vpc.ID is uuid.Nil the database generates the ID
+// and vpc.ID is updated.
+func CreateVPC(ctx context.Context, q Querier, vpc *VPC) error {
+	const sql = `INSERT INTO vpc (id, account_id, name) VALUES (COALESCE($1, gen_random_uuid()), $2, $3) RETURNING id`
+	if err := q.QueryRowEx(ctx, sql, nil, nullID(vpc.ID), vpc.AccountID, vpc.Name).Scan(&vpc.ID); err != nil {
+		return errors.Wrapf(err, "unable to create VPC in account %s", vpc.AccountID)
+	}
+
+	return nil
+}
+
+// GetVPC returns the VPC identified by id.
+func GetVPC(ctx context.Context, q Querier, id uuid.UUID) (VPC, error) {
+	const sql = `SELECT ` + vpcColumns + ` FROM vpc WHERE id = $1`
+	vpc, err := scanVPC(q.QueryRowEx(ctx, sql, nil, id))
+	if err != nil {
+		return VPC{}, errors.Wrapf(notFound(err), "unable to get VPC %s", id)
+	}
+
+	return vpc, nil
+}
+
+// ListVPCs returns the VPCs in accountID.
+func ListVPCs(ctx context.Context, q Querier, accountID uuid.UUID) ([]VPC, error) {
+	const sql = `SELECT ` + vpcColumns + ` FROM vpc WHERE account_id = $1 ORDER BY id`
+	var vpcs []VPC
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		vpc, err := scanVPC(r)
+		if err != nil {
+			return err
+		}
+		vpcs = append(vpcs, vpc)
+		return nil
+	}, sql, accountID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list VPCs in account %s", accountID)
+	}
+
+	return vpcs, nil
+}
+
+// UpdateVPC updates the name of vpc.
+func UpdateVPC(ctx context.Context, q Querier, vpc VPC) error {
+	const sql = `UPDATE vpc SET name = $2 WHERE id = $1`
+	if err := execOne(ctx, q, sql, vpc.ID, vpc.Name); err != nil {
+		return errors.Wrapf(err, "unable to update VPC %s", vpc.ID)
+	}
+
+	return nil
+}
+
+// DeleteVPC deletes the VPC identified by id.
+func DeleteVPC(ctx context.Context, q Querier, id uuid.UUID) error {
+	const sql = `DELETE FROM vpc WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete VPC %s", id)
+	}
+
+	return nil
+}
+
+// VNI is a row in the vni table.  A VNI is available for reuse when VPCID is
+// not valid and ExpiredAt is either nil or older than ExpireAfter.
+type VNI struct {
+	FacilityID  uuid.UUID
+	VNI         int32
+	VPCID       uuid.NullUUID
+	ExpiredAt   *time.Time
+	ExpireAfter time.Duration
+}
+
+const vniColumns = `facility_id, vni, vpc_id, expired_at, expire_after`
+
+func scanVNI(r rowScanner) (VNI, error) {
+	var vni VNI
+	var expireAfter pgtype.Interval
+	if err := r.Scan(&vni.FacilityID, &vni.VNI, &vni.VPCID, &vni.ExpiredAt, &expireAfter); err != nil {
+		return VNI{}, err
+	}
+	vni.ExpireAfter = intervalDuration(expireAfter)
+
+	return vni, nil
+}
+
+// CreateVNI inserts vni.  DefaultExpireAfter is used if vni.ExpireAfter is
+// zero.
+func CreateVNI(ctx context.Context, q Querier, vni VNI) error {
+	const sql = `INSERT INTO vni (facility_id, vni, vpc_id, expired_at, expire_after) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := q.ExecEx(ctx, sql, nil, vni.FacilityID, vni.VNI, vni.VPCID, vni.ExpiredAt, expireAfter(vni.ExpireAfter)); err != nil {
+		return errors.Wrapf(err, "unable to create VNI %d in facility %s", vni.VNI, vni.FacilityID)
+	}
+
+	return nil
+}
+
+// GetVNI returns the VNI identified by facilityID and vni.
+func GetVNI(ctx context.Context, q Querier, facilityID uuid.UUID, vni int32) (VNI, error) {
+	const sql = `SELECT ` + vniColumns + ` FROM vni WHERE facility_id = $1 AND vni = $2`
+	v, err := scanVNI(q.QueryRowEx(ctx, sql, nil, facilityID, vni))
+	if err != nil {
+		return VNI{}, errors.Wrapf(notFound(err), "unable to get VNI %d in facility %s", vni, facilityID)
+	}
+
+	return v, nil
+}
+
+// ListVNIs returns the VNIs in facilityID.
+func ListVNIs(ctx context.Context, q Querier, facilityID uuid.UUID) ([]VNI, error) {
+	const sql = `SELECT ` + vniColumns + ` FROM vni WHERE facility_id = $1 ORDER BY vni`
+	var vnis []VNI
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		vni, err := scanVNI(r)
+		if err != nil {
+			return err
+		}
+		vnis = append(vnis, vni)
+		return nil
+	}, sql, facilityID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list VNIs in facility %s", facilityID)
+	}
+
+	return vnis, nil
+}
+
+// UpdateVNI updates the owner and expiry of vni.
+func UpdateVNI(ctx context.Context, q Querier, vni VNI) error {
+	const sql = `UPDATE vni SET vpc_id = $3, expired_at = $4, expire_after = $5 WHERE facility_id = $1 AND vni = $2`
+	if err := execOne(ctx, q, sql, vni.FacilityID, vni.VNI, vni.VPCID, vni.ExpiredAt, expireAfter(vni.ExpireAfter)); err != nil {
+		return errors.Wrapf(err, "unable to update VNI %d in facility %s", vni.VNI, vni.FacilityID)
+	}
+
+	return nil
+}
+
+// DeleteVNI deletes the VNI identified by facilityID and vni.
+func DeleteVNI(ctx context.Context, q Querier, facilityID uuid.UUID, vni int32) error {
+	const sql = `DELETE FROM vni WHERE facility_id = $1 AND vni = $2`
+	if err := execOne(ctx, q, sql, facilityID, vni); err != nil {
+		return errors.Wrapf(err, "unable to delete VNI %d in facility %s", vni, facilityID)
+	}
+
+	return nil
+}
+
+// Subnet is a row in the subnet table.  The address_type, network, and
+// prefix_len columns are derived from Network.
+type Subnet struct {
+	ID      uuid.UUID
+	VPCID   uuid.UUID
+	Network net.IPNet
+}
+
+// AddressType returns the value of the subnet's address_type column.
+func (s Subnet) AddressType() string {
+	if s.Network.IP.To4() != nil {
+		return "IPv4"
+	}
+
+	return "IPv6"
+}
+
+const subnetColumns = `id, vpc_id, network, prefix_len`
+
+func scanSubnet(r rowScanner) (Subnet, error) {
+	var subnet Subnet
+	var network string
+	var prefixLen int
+	if err := r.Scan(&subnet.ID, &subnet.VPCID, &network, &prefixLen); err != nil {
+		return Subnet{}, err
+	}
+
+	ip := net.ParseIP(network)
+	if ip == nil {
+		return Subnet{}, errors.Errorf("invalid network %q", network)
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	subnet.Network = net.IPNet{IP: ip, Mask: net.CIDRMask(prefixLen, bits)}
+
+	return subnet, nil
+}
+
+func subnetArgs(subnet Subnet) (addressType, network string, prefixLen int) {
+	prefixLen, _ = subnet.Network.Mask.Size()
+	return subnet.AddressType(), subnet.Network.IP.Mask(subnet.Network.Mask).String(), prefixLen
+}
+
+// CreateSubnet inserts subnet.  If subnet.ID is uuid.Nil the database
+// generates the ID and subnet.ID is updated.
+func CreateSubnet(ctx context.Context, q Querier, subnet *Subnet) error {
+	const sql = `INSERT INTO subnet (id, vpc_id, address_type, network, prefix_len) VALUES (COALESCE($1, gen_random_uuid()), $2, $3, $4, $5) RETURNING id`
+	addressType, network, prefixLen := subnetArgs(*subnet)
+	if err := q.QueryRowEx(ctx, sql, nil, nullID(subnet.ID), subnet.VPCID, addressType, network, prefixLen).Scan(&subnet.ID); err != nil {
+		return errors.Wrapf(err, "unable to create subnet %s in VPC %s", subnet.Network.String(), subnet.VPCID)
+	}
+
+	return nil
+}
+
+// GetSubnet returns the subnet identified by id.
+func GetSubnet(ctx context.Context, q Querier, id uuid.UUID) (Subnet, error) {
+	const sql = `SELECT ` + subnetColumns + ` FROM subnet WHERE id = $1`
+	subnet, err := scanSubnet(q.QueryRowEx(ctx, sql, nil, id))
+	if err != nil {
+		return Subnet{}, errors.Wrapf(notFound(err), "unable to get subnet %s", id)
+	}
+
+	return subnet, nil
+}
+
+// ListSubnets returns the subnets in vpcID.
+func ListSubnets(ctx context.Context, q Querier, vpcID uuid.UUID) ([]Subnet, error) {
+	const sql = `SELECT ` + subnetColumns + ` FROM subnet WHERE vpc_id = $1 ORDER BY network`
+	var subnets []Subnet
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		subnet, err := scanSubnet(r)
+		if err != nil {
+			return err
+		}
+		subnets = append(subnets, subnet)
+		return nil
+	}, sql, vpcID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list subnets in VPC %s", vpcID)
+	}
+
+	return subnets, nil
+}
+
+// UpdateSubnet updates the network of subnet.
+func UpdateSubnet(ctx context.Context, q Querier, subnet Subnet) error {
+	const sql = `UPDATE subnet SET address_type = $2, network = $3, prefix_len = $4 WHERE id = $1`
+	addressType, network, prefixLen := subnetArgs(subnet)
+	if err := execOne(ctx, q, sql, subnet.ID, addressType, network, prefixLen); err != nil {
+		return errors.Wrapf(err, "unable to update subnet %s", subnet.ID)
+	}
+
+	return nil
+}
+
+// DeleteSubnet deletes the subnet identified by id.
+func DeleteSubnet(ctx context.Context, q Querier, id uuid.UUID) error {
+	const sql = `DELETE FROM subnet WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete subnet %s", id)
+	}
+
+	return nil
+}
+
+// AccountMAC is a row in the account_mac table.
+type AccountMAC struct {
+	ID          uuid.UUID
+	AccountID   uuid.UUID
+	MAC         net.HardwareAddr
+	VPCID       uuid.UUID
+	SubnetID    uuid.UUID
+	ExpiredAt   *time.Time
+	ExpireAfter time.Duration
+}
+
+const accountMACColumns = `id, account_id, mac, vpc_id, subnet_id, expired_at, expire_after`
+
+func scanAccountMAC(r rowScanner) (AccountMAC, error) {
+	var mac AccountMAC
+	var hwAddr string
+	var expireAfter pgtype.Interval
+	if err := r.Scan(&mac.ID, &mac.AccountID, &hwAddr, &mac.VPCID, &mac.SubnetID, &mac.ExpiredAt, &expireAfter); err != nil {
+		return AccountMAC{}, err
+	}
+	mac.ExpireAfter = intervalDuration(expireAfter)
+
+	var err error
+	if mac.MAC, err = net.ParseMAC(hwAddr); err != nil {
+		return AccountMAC{}, errors.Wrapf(err, "invalid MAC %q", hwAddr)
+	}
+
+	return mac, nil
+}
+
+// CreateAccountMAC inserts mac.  If mac.ID is uuid.Nil the database generates
+// the ID and mac.ID is updated.  DefaultExpireAfter is used if mac.ExpireAfter
+// is zero.
+func CreateAccountMAC(ctx context.Context, q Querier, mac *AccountMAC) error {
+	const sql = `INSERT INTO account_mac (id, account_id, mac, vpc_id, subnet_id, expired_at, expire_after) VALUES (COALESCE($1, gen_random_uuid()), $2, $3, $4, $5, $6, $7) RETURNING id`
+	if err := q.QueryRowEx(ctx, sql, nil, nullID(mac.ID), mac.AccountID, mac.MAC.String(), mac.VPCID, mac.SubnetID, mac.ExpiredAt, expireAfter(mac.ExpireAfter)).Scan(&mac.ID); err != nil {
+		return errors.Wrapf(err, "unable to create MAC %s in account %s", mac.MAC, mac.AccountID)
+	}
+
+	return nil
+}
+
+// GetAccountMAC returns the MAC identified by accountID and mac.
+func GetAccountMAC(ctx context.Context, q Querier, accountID uuid.UUID, mac net.HardwareAddr) (AccountMAC, error) {
+	const sql = `SELECT ` + accountMACColumns + ` FROM account_mac WHERE account_id = $1 AND mac = $2`
+	m, err := scanAccountMAC(q.QueryRowEx(ctx, sql, nil, accountID, mac.String()))
+	if err != nil {
+		return AccountMAC{}, errors.Wrapf(notFound(err), "unable to get MAC %s in account %s", mac, accountID)
+	}
+
+	return m, nil
+}
+
+// ListAccountMACs returns the MACs in accountID.
+func ListAccountMACs(ctx context.Context, q Querier, accountID uuid.UUID) ([]AccountMAC, error) {
+	const sql = `SELECT ` + accountMACColumns + ` FROM account_mac WHERE account_id = $1 ORDER BY mac`
+	var macs []AccountMAC
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		mac, err := scanAccountMAC(r)
+		if err != nil {
+			return err
+		}
+		macs = append(macs, mac)
+		return nil
+	}, sql, accountID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list MACs in account %s", accountID)
+	}
+
+	return macs, nil
+}
+
+// UpdateAccountMAC updates the subnet and expiry of mac.
+func UpdateAccountMAC(ctx context.Context, q Querier, mac AccountMAC) error {
+	const sql = `UPDATE account_mac SET vpc_id = $3, subnet_id = $4, expired_at = $5, expire_after = $6 WHERE account_id = $1 AND mac = $2`
+	if err := execOne(ctx, q, sql, mac.AccountID, mac.MAC.String(), mac.VPCID, mac.SubnetID, mac.ExpiredAt, expireAfter(mac.ExpireAfter)); err != nil {
+		return errors.Wrapf(err, "unable to update MAC %s in account %s", mac.MAC, mac.AccountID)
+	}
+
+	return nil
+}
+
+// DeleteAccountMAC deletes the MAC identified by accountID and mac.
+func DeleteAccountMAC(ctx context.Context, q Querier, accountID uuid.UUID, mac net.HardwareAddr) error {
+	const sql = `DELETE FROM account_mac WHERE account_id = $1 AND mac = $2`
+	if err := execOne(ctx, q, sql, accountID, mac.String()); err != nil {
+		return errors.Wrapf(err, "unable to delete MAC %s in account %s", mac, accountID)
+	}
+
+	return nil
+}
+
+// SubnetIP is a row in the subnet_ip table.
+type SubnetIP struct {
+	ID       uuid.UUID
+	VPCID    uuid.UUID
+	SubnetID uuid.UUID
+	IP       net.IP
+}
+
+const subnetIPColumns = `id, vpc_id, subnet_id, ip`
+
+func scanSubnetIP(r rowScanner) (SubnetIP, error) {
+	var ip SubnetIP
+	var addr string
+	if err := r.Scan(&ip.ID, &ip.VPCID, &ip.SubnetID, &addr); err != nil {
+		return SubnetIP{}, err
+	}
+
+	if ip.IP = net.ParseIP(addr); ip.IP == nil {
+		return SubnetIP{}, errors.Errorf("invalid IP %q", addr)
+	}
+
+	return ip, nil
+}
+
+// CreateSubnetIP inserts ip.  If ip.ID is uuid.Nil the database generates the
+// ID and ip.ID is updated.
+func CreateSubnetIP(ctx context.Context, q Querier, ip *SubnetIP) error {
+	const sql = `INSERT INTO subnet_ip (id, vpc_id, subnet_id, ip) VALUES (COALESCE($1, gen_random_uuid()), $2, $3, $4) RETURNING id`
+	if err := q.QueryRowEx(ctx, sql, nil, nullID(ip.ID), ip.VPCID, ip.SubnetID, ip.IP.String()).Scan(&ip.ID); err != nil {
+		return errors.Wrapf(err, "unable to create IP %s in subnet %s", ip.IP, ip.SubnetID)
+	}
+
+	return nil
+}
+
+// GetSubnetIP returns the IP identified by id.
+func GetSubnetIP(ctx context.Context, q Querier, id uuid.UUID) (SubnetIP, error) {
+	const sql = `SELECT ` + subnetIPColumns + ` FROM subnet_ip WHERE id = $1`
+	ip, err := scanSubnetIP(q.QueryRowEx(ctx, sql, nil, id))
+	if err != nil {
+		return SubnetIP{}, errors.Wrapf(notFound(err), "unable to get subnet IP %s", id)
+	}
+
+	return ip, nil
+}
+
+// ListSubnetIPs returns the IPs in subnetID.
+func ListSubnetIPs(ctx context.Context, q Querier, subnetID uuid.UUID) ([]SubnetIP, error) {
+	const sql = `SELECT ` + subnetIPColumns + ` FROM subnet_ip WHERE subnet_id = $1 ORDER BY ip`
+	var ips []SubnetIP
+	err := queryAll(ctx, q, func(r rowScanner) error {
+		ip, err := scanSubnetIP(r)
+		if err != nil {
+			return err
+		}
+		ips = append(ips, ip)
+		return nil
+	}, sql, subnetID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list IPs in subnet %s", subnetID)
+	}
+
+	return ips, nil
+}
+
+// UpdateSubnetIP updates the address of ip.
+func UpdateSubnetIP(ctx context.Context, q Querier, ip SubnetIP) error {
+	const sql = `UPDATE subnet_ip SET ip = $2 WHERE id = $1`
+	if err := execOne(ctx, q, sql, ip.ID, ip.IP.String()); err != nil {
+		return errors.Wrapf(err, "unable to update subnet IP %s", ip.ID)
+	}
+
+	return nil
+}
+
+// DeleteSubnetIP deletes the IP identified by id.
+func DeleteSubnetIP(ctx context.Context, q Querier, id uuid.UUID) error {
+	const sql = `DELETE FROM subnet_ip WHERE id = $1`
+	if err := execOne(ctx, q, sql, id); err != nil {
+		return errors.Wrapf(err, "unable to delete subnet IP %s", id)
+	}
+
+	return nil
+}
+
+// SubnetVNIVLAN is a row in the subnet_vni_vlan table and maps a subnet to the
+// VNI and VLAN it uses in a facility.
+type SubnetVNIVLAN struct {
+	FacilityID uuid.UUID
+	SubnetID   uuid.UUID
+	VNI        int32
+	VLANID     int32
+}
+
+const subnetVNIVLANColumns = `facility_id, subnet_id, vni, vlan_id`
+
+func scanSubnetVNIVLAN(r rowScanner) (SubnetVNIVLAN, error) {
+	var m SubnetVNIVLAN
+	err := r.Scan(&m.FacilityID, &m.SubnetID, &m.VNI, &m.VLANID)
+	return m, err
+}
+
+// CreateSubnetVNIVLAN inserts m.  The VNI must already exist in the facility.
+func CreateSubnetVNIVLAN(ctx context.Context, q Querier, m SubnetVNIVLAN) error {
+	const sql = `INSERT INTO subnet_vni_vlan (facility_id, subnet_id, vni, vlan_id) VALUES ($1, $2, $3, $4)`
+	if _, err := q.ExecEx(ctx, sql, nil, m.FacilityID, m.SubnetID, m.VNI, m.VLANID); err != nil {
+		return errors.Wrapf(err, "unable to map subnet %s to VNI %d in facility %s", m.SubnetID, m.VNI, m.FacilityID)
+	}
+
+	return nil
+}
+
+// GetSubnetVNIVLAN returns the VNI and VLAN of subnetID in facilityID.
+func GetSubnetVNIVLAN(ctx context.Context, q Querier, facilityID, subnetID uuid.UUID) (SubnetVNIVLAN, error) {
+	const sql = `SELECT ` + subnetVNIVLANColumns + ` FROM subnet_vni_vlan WHERE facility_id = $1 AND subnet_id = $2`
+	m, err := scanSubnetVNIVLAN(q.QueryRowEx(ctx, sql, nil, facilityID, subnetID))
+	if err != nil {
+		return SubnetVNIVLAN{}, errors.Wrapf(notFound(err), "unable to get VNI of subnet %s in facility %s", subnetID, facilityID)
+	}
+
+	return m, nil
+}
+
+// DeleteSubnetVNIVLAN deletes the mapping of subnetID in facilityID.
+func DeleteSubnetVNIVLAN(ctx context.Context, q Querier, facilityID, subnetID uuid.UUID) error {
+	const sql = `DELETE FROM subnet_vni_vlan WHERE facility_id = $1 AND subnet_id = $2`
+	if err := execOne(ctx, q, sql, facilityID, subnetID); err != nil {
+		return errors.Wrapf(err, "unable to delete VNI mapping of subnet %s in facility %s", subnetID, facilityID)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package flag
+
+import (
+	"github.com/joyent/freebsd-vpc/internal/command"
+)
+
+// AddMACAllocation adds the account and subnet ID flags used to lease a
+// command's MAC address from the account's MAC allocator.  Commands using
+// these flags must also call db.SetDefaultViperOptions.
+func AddMACAllocation(cmd *command.Command, accountKey, subnetKey string) error {
+	if err := AddUUID(cmd, UUIDCfg{
+		Name:        accountKey,
+		LongName:    "account-id",
+		Description: "lease the MAC address from this account's MAC allocator",
+	}); err != nil {
+		return err
+	}
+
+	if err := AddUUID(cmd, UUIDCfg{
+		Name:        subnetKey,
+		LongName:    "subnet-id",
+		Description: "subnet the leased MAC address is used in (requires --account-id)",
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
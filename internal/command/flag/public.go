@@ -30,6 +30,7 @@ import (
 	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
 	"github.com/joyent/freebsd-vpc/internal/command"
 	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
 	"github.com/spf13/viper"
 )
 
@@ -239,6 +240,48 @@ func AddVNI(cmd *command.Command, cfg VNICfg) error {
 	return nil
 }
 
+// UUIDCfg describes a flag that takes the UUID of a database object.
+type UUIDCfg struct {
+	Name        string
+	LongName    string
+	Description string
+	Required    bool
+}
+
+// AddUUID adds a database object UUID flag to a given command.
+func AddUUID(cmd *command.Command, cfg UUIDCfg) error {
+	key := cfg.Name
+	const (
+		shortName    = ""
+		defaultValue = ""
+	)
+
+	flags := cmd.Cobra.Flags()
+	flags.StringP(cfg.LongName, shortName, defaultValue, cfg.Description)
+	if cfg.Required {
+		cmd.Cobra.MarkFlagRequired(cfg.LongName)
+	}
+
+	viper.BindPFlag(key, flags.Lookup(cfg.LongName))
+	viper.SetDefault(key, defaultValue)
+
+	return nil
+}
+
+// GetUUID returns the database object UUID found in the Viper key.
+func GetUUID(v *viper.Viper, key string) (id uuid.UUID, err error) {
+	idStr := v.GetString(key)
+	if idStr == "" {
+		return uuid.Nil, errors.Errorf("missing %s", key)
+	}
+
+	if id, err = uuid.FromString(idStr); err != nil {
+		return uuid.Nil, errors.Wrapf(err, "unable to parse UUID %q", idStr)
+	}
+
+	return id, nil
+}
+
 // GetID returns the VPC ID address found in the Viper key.
 func GetID(v *viper.Viper, key string) (id vpc.ID, err error) {
 	if idStr := v.GetString(key); idStr != "" {
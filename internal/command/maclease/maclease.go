@@ -0,0 +1,117 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Package maclease leases and releases the MAC addresses of interfaces
+// created from the command line using the database configured in Viper.
+package maclease
+
+import (
+	"context"
+	"net"
+
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/joyent/freebsd-vpc/internal/command/flag"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// Lease leases mac from the MAC allocator of the account found in the
+// accountKey Viper key.  If mac is nil a unique MAC is generated.  finish must
+// be called once the caller is done with the MAC: if created is false the
+// interface was never created and the lease is deleted.
+func Lease(v *viper.Viper, accountKey, subnetKey string, mac net.HardwareAddr) (leased net.HardwareAddr, finish func(created bool), err error) {
+	accountID, err := flag.GetUUID(v, accountKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to get account ID")
+	}
+
+	subnetID, err := flag.GetUUID(v, subnetKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to get subnet ID")
+	}
+
+	dbPool, err := newPool()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	if leased, err = db.NewMACAllocator(dbPool).Lease(ctx, accountID, subnetID, mac); err != nil {
+		dbPool.Close()
+		return nil, nil, err
+	}
+	log.Info().Str("account-id", accountID.String()).Str("mac", leased.String()).Msg("leased MAC address")
+
+	finish = func(created bool) {
+		defer dbPool.Close()
+
+		if created {
+			return
+		}
+
+		if err := db.DeleteAccountMAC(ctx, dbPool.Pool(), accountID, leased); err != nil {
+			log.Warn().Err(err).Str("mac", leased.String()).Msg("unable to delete MAC address lease")
+		}
+	}
+
+	return leased, finish, nil
+}
+
+// Release releases mac to the MAC allocator of the account found in the
+// accountKey Viper key once its interface has been destroyed.  The MAC is
+// tombstoned and is not leased again until it expires.
+func Release(v *viper.Viper, accountKey string, mac net.HardwareAddr) error {
+	accountID, err := flag.GetUUID(v, accountKey)
+	if err != nil {
+		return errors.Wrap(err, "unable to get account ID")
+	}
+
+	dbPool, err := newPool()
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	if err := db.NewMACAllocator(dbPool).Release(context.Background(), accountID, mac); err != nil {
+		return err
+	}
+	log.Info().Str("account-id", accountID.String()).Str("mac", mac.String()).Msg("released MAC address")
+
+	return nil
+}
+
+func newPool() (*db.Pool, error) {
+	dbConfig, err := db.ViperConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dbPool, err := db.New(dbConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create database pool")
+	}
+
+	return dbPool, nil
+}
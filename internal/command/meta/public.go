@@ -0,0 +1,181 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Package meta provides the flags and handlers shared by the get and set
+// commands of every VPC object type for the attributes common to all VPC
+// objects (i.e. the MAC address and MTU).
+package meta
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/joyent/freebsd-vpc/internal/command"
+	"github.com/joyent/freebsd-vpc/internal/command/output"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Object is a VPC object that supports the meta operations.
+type Object interface {
+	MAC() (net.HardwareAddr, error)
+	MTU() (uint32, error)
+	SetMAC(mac net.HardwareAddr) error
+	SetMTU(mtu uint32) error
+}
+
+// Keys are the Viper keys of the meta flags of a given command.
+type Keys struct {
+	MAC string
+	MTU string
+}
+
+// Attrs is the machine-readable record of the meta attributes of a VPC object.
+// Attrs is intended to be embedded in the record of a get command.
+type Attrs struct {
+	MAC string  `json:"mac,omitempty" yaml:"mac,omitempty"`
+	MTU *uint32 `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+}
+
+// AddGetFlags adds the flags used to select the meta attributes reported by a
+// get command.
+func AddGetFlags(cmd *command.Command, keys Keys) error {
+	{
+		const (
+			longName     = "mac"
+			shortName    = "m"
+			defaultValue = true
+			description  = "get the MAC address"
+		)
+
+		flags := cmd.Cobra.Flags()
+		flags.BoolP(longName, shortName, defaultValue, description)
+
+		viper.BindPFlag(keys.MAC, flags.Lookup(longName))
+		viper.SetDefault(keys.MAC, defaultValue)
+	}
+
+	{
+		const (
+			longName     = "mtu"
+			shortName    = ""
+			defaultValue = true
+			description  = "get the MTU"
+		)
+
+		flags := cmd.Cobra.Flags()
+		flags.BoolP(longName, shortName, defaultValue, description)
+
+		viper.BindPFlag(keys.MTU, flags.Lookup(longName))
+		viper.SetDefault(keys.MTU, defaultValue)
+	}
+
+	return nil
+}
+
+// AddSetFlags adds the flags used to change the meta attributes of a VPC
+// object to a set command.
+func AddSetFlags(cmd *command.Command, keys Keys) error {
+	{
+		const (
+			longName     = "mac"
+			shortName    = "m"
+			defaultValue = ""
+			description  = "set the MAC address"
+		)
+
+		flags := cmd.Cobra.Flags()
+		flags.StringP(longName, shortName, defaultValue, description)
+
+		viper.BindPFlag(keys.MAC, flags.Lookup(longName))
+		viper.SetDefault(keys.MAC, defaultValue)
+	}
+
+	{
+		const (
+			longName     = "mtu"
+			shortName    = ""
+			defaultValue = 0
+			description  = "set the MTU"
+		)
+
+		flags := cmd.Cobra.Flags()
+		flags.UintP(longName, shortName, defaultValue, description)
+
+		viper.BindPFlag(keys.MTU, flags.Lookup(longName))
+		viper.SetDefault(keys.MTU, defaultValue)
+	}
+
+	return nil
+}
+
+// Get reads the meta attributes selected by the get flags from obj and appends
+// them to table.
+func Get(v *viper.Viper, keys Keys, id vpc.ID, obj Object, table *output.Table) (attrs Attrs, err error) {
+	if v.GetBool(keys.MAC) {
+		mac, err := obj.MAC()
+		if err != nil {
+			return Attrs{}, errors.Wrap(err, "unable to get the MAC address")
+		}
+
+		attrs.MAC = mac.String()
+		table.Append(id.String(), "mac", attrs.MAC)
+	}
+
+	if v.GetBool(keys.MTU) {
+		mtu, err := obj.MTU()
+		if err != nil {
+			return Attrs{}, errors.Wrap(err, "unable to get the MTU")
+		}
+
+		attrs.MTU = &mtu
+		table.Append(id.String(), "mtu", strconv.FormatUint(uint64(mtu), 10))
+	}
+
+	return attrs, nil
+}
+
+// Set applies the meta attributes passed to the set flags to obj.  Attributes
+// whose flags were not specified are left unchanged.
+func Set(v *viper.Viper, keys Keys, obj Object) error {
+	if macStr := v.GetString(keys.MAC); macStr != "" {
+		mac, err := net.ParseMAC(macStr)
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse MAC %q", macStr)
+		}
+
+		if err := obj.SetMAC(mac); err != nil {
+			return errors.Wrap(err, "unable to set the MAC address")
+		}
+	}
+
+	if mtu := v.GetInt(keys.MTU); mtu != 0 {
+		if err := obj.SetMTU(uint32(mtu)); err != nil {
+			return errors.Wrap(err, "unable to set the MTU")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package output
+
+import (
+	"net"
+)
+
+// Interface is the machine-readable record for an OS network interface.
+type Interface struct {
+	Name  string `json:"name" yaml:"name"`
+	Index int    `json:"index" yaml:"index"`
+	MTU   int    `json:"mtu" yaml:"mtu"`
+	MAC   string `json:"mac" yaml:"mac"`
+	Flags string `json:"flags" yaml:"flags"`
+}
+
+// NewInterface creates an Interface record from a net.Interface.
+func NewInterface(iface net.Interface) Interface {
+	return Interface{
+		Name:  iface.Name,
+		Index: iface.Index,
+		MTU:   iface.MTU,
+		MAC:   iface.HardwareAddr.String(),
+		Flags: iface.Flags.String(),
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/joyent/freebsd-vpc/internal/config"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Format is the format used to render the output of a command.
+type Format uint
+
+const (
+	FormatTable Format = iota
+	FormatJSON
+	FormatYAML
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatTable:
+		return "table"
+	case FormatJSON:
+		return "json"
+	case FormatYAML:
+		return "yaml"
+	default:
+		panic(fmt.Sprintf("unknown output format: %d", f))
+	}
+}
+
+// Formats returns the list of supported output formats.
+func Formats() []Format {
+	return []Format{FormatTable, FormatJSON, FormatYAML}
+}
+
+// FormatsStr returns the supported output formats as a comma separated list.
+func FormatsStr() string {
+	formats := Formats()
+	strs := make([]string, 0, len(formats))
+	for _, f := range formats {
+		strs = append(strs, f.String())
+	}
+
+	return strings.Join(strs, ", ")
+}
+
+// GetFormat returns the output format configured in the Viper key
+// config.KeyOutputFormat.
+func GetFormat(v *viper.Viper) (Format, error) {
+	switch format := strings.ToLower(v.GetString(config.KeyOutputFormat)); format {
+	case "", "table":
+		return FormatTable, nil
+	case "json":
+		return FormatJSON, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	default:
+		return FormatTable, errors.Errorf("unsupported output format: %q (supported formats: %s)", format, FormatsStr())
+	}
+}
+
+// Table describes how records are rendered when the output format is
+// FormatTable.  The Footer is only rendered in tables and is not part of the
+// machine-readable output.
+type Table struct {
+	Header          []string
+	ColumnAlignment []int
+	Rows            [][]string
+	Footer          []string
+}
+
+// Append adds a row to the Table.
+func (t *Table) Append(row ...string) {
+	t.Rows = append(t.Rows, row)
+}
+
+// Write renders records to w using the output format configured in v.  For
+// FormatJSON and FormatYAML, records is marshaled as-is and must use a stable
+// schema (i.e. a struct or slice of structs with json and yaml tags).  For
+// FormatTable, table is rendered instead of records.
+func Write(w io.Writer, v *viper.Viper, records interface{}, table Table) error {
+	format, err := GetFormat(v)
+	if err != nil {
+		return errors.Wrap(err, "unable to get output format")
+	}
+
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(records); err != nil {
+			return errors.Wrap(err, "unable to encode JSON output")
+		}
+	case FormatYAML:
+		b, err := yaml.Marshal(records)
+		if err != nil {
+			return errors.Wrap(err, "unable to encode YAML output")
+		}
+
+		if _, err := w.Write(b); err != nil {
+			return errors.Wrap(err, "unable to write YAML output")
+		}
+	case FormatTable:
+		tw := tablewriter.NewWriter(w)
+		tw.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		tw.SetHeaderLine(false)
+		tw.SetAutoFormatHeaders(true)
+
+		tw.SetColumnAlignment(table.ColumnAlignment)
+		tw.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+		tw.SetCenterSeparator("")
+		tw.SetColumnSeparator("")
+		tw.SetRowSeparator("")
+
+		tw.SetHeader(table.Header)
+		tw.AppendBulk(table.Rows)
+		if table.Footer != nil {
+			tw.SetFooter(table.Footer)
+		}
+
+		tw.Render()
+	default:
+		return errors.Errorf("unsupported output format: %q", format)
+	}
+
+	return nil
+}
@@ -31,27 +31,67 @@ const (
 	DefaultMarkdownDir       = "./docs/md"
 	DefaultMarkdownURLPrefix = "/command"
 
+	DefaultAgentCNIDFile     = "/var/db/vpc/cn-id"
+	DefaultAgentInternalAddr = "/tmp/vpc-agent.sock"
+	DefaultAgentInternalMode = "0660"
+	DefaultAgentOwnedFile    = "/var/db/vpc/owned"
+
+	KeyAgentCNID               = "agent.cn-id"
+	KeyAgentCNIDFile           = "agent.cn-id-file"
+	KeyAgentFacility           = "agent.facility"
+	KeyAgentHealthLive         = "agent.health.live"
+	KeyAgentInternalAddr       = "agent.addresses.internal"
+	KeyAgentInternalGroup      = "agent.addresses.internal-group"
+	KeyAgentInternalMode       = "agent.addresses.internal-mode"
+	KeyAgentMetricsAddr        = "agent.addresses.metrics"
+	KeyAgentReconcileDryRun    = "agent.reconcile.dry-run"
+	KeyAgentReconcileInterval  = "agent.reconcile.interval"
+	KeyAgentReconcileOwnedFile = "agent.reconcile.owned-file"
+	KeyAgentShutdownTimeout    = "agent.shutdown-timeout"
+	KeyAgentUnderlayInterfaces = "agent.underlay-interfaces"
+
+	KeyApplyDryRun   = "apply.dry-run"
+	KeyApplyFilename = "apply.filename"
+	KeyApplyPrune    = "apply.prune"
+
+	KeyDBMigrateDownYes   = "db.migrate.down.yes"
+	KeyDBMigrateTargetYes = "db.migrate.goto.yes"
+
 	KeyDocManDir            = "doc.mandir"
 	KeyDocMarkdownDir       = "doc.markdown-dir"
 	KeyDocMarkdownURLPrefix = "doc.markdown-url-prefix"
 
 	KeyEthLinkConnectID     = "ethlink.connect.id"
+	KeyEthLinkConnectClone  = "ethlink.connect.clone"
 	KeyEthLinkConnectL2Name = "ethlink.connect.l2-name"
 	KeyEthLinkCreateID      = "ethlink.create.id"
 	KeyEthLinkDestroyID     = "ethlink.destroy.ethlink-id"
+	KeyEthLinkDisconnectID  = "ethlink.disconnect.ethlink-id"
+	KeyEthLinkGetEthLinkID  = "ethlink.get.ethlink-id"
+	KeyEthLinkGetMAC        = "ethlink.get.mac"
+	KeyEthLinkGetMTU        = "ethlink.get.mtu"
 	KeyEthLinkListSortBy    = "ethlink.list.sort-by"
+	KeyEthLinkSetEthLinkID  = "ethlink.set.ethlink-id"
+	KeyEthLinkSetMAC        = "ethlink.set.mac"
+	KeyEthLinkSetMTU        = "ethlink.set.mtu"
 	KeyEthLinkVTagID        = "ethlink.vtag.ethlink-id"
 	KeyEthLinkGetVTag       = "ethlink.vtag.get-vtag"
 	KeyEthLinkSetVTag       = "ethlink.vtag.set-vtag"
 
+	KeyGraphFormat = "graph.format"
+
+	KeyIntfListAll = "intf.list.all"
+
 	KeyListObjCounts = "list.obj-counts"
 	KeyListObjSortBy = "list.sort-by"
 	KeyListObjType   = "list.type"
 
-	KeyLogFormat    = "log.format"
-	KeyLogLevel     = "log.level"
-	KeyLogStats     = "log.stats"
-	KeyLogTermColor = "log.use-color"
+	KeyLogFormat          = "log.format"
+	KeyLogLevel           = "log.level"
+	KeyLogStats           = "log.stats"
+	KeyLogTermColor       = "log.use-color"
+	KeyLogTraceKBI        = "log.trace-kbi"
+	KeyLogTraceKBIPayload = "log.trace-kbi-payload"
 
 	KeyPGDatabase = "db.name"
 	KeyPGUser     = "db.username"
@@ -59,16 +99,39 @@ const (
 	KeyPGHost     = "db.host"
 	KeyPGPort     = "db.port"
 
-	KeyHostifCreateID  = "hostif.create.id"
-	KeyHostifDestroyID = "hostif.destroy.id"
+	KeyHostifCreateAccountID  = "hostif.create.account-id"
+	KeyHostifCreateID         = "hostif.create.id"
+	KeyHostifCreateSubnetID   = "hostif.create.subnet-id"
+	KeyHostifDestroyAccountID = "hostif.destroy.account-id"
+	KeyHostifDestroyID        = "hostif.destroy.id"
+	KeyHostifGetHostifID      = "hostif.get.hostif-id"
+	KeyHostifGetMAC           = "hostif.get.mac"
+	KeyHostifGetMTU           = "hostif.get.mtu"
+	KeyHostifSetHostifID      = "hostif.set.hostif-id"
+	KeyHostifSetMAC           = "hostif.set.mac"
+	KeyHostifSetMTU           = "hostif.set.mtu"
 
 	KeyMuxConnectInterfaceID = "mux.connect.interface-id"
 	KeyMuxConnectMuxID       = "mux.connect.mux-id"
 	KeyMuxCreateMuxID        = "mux.create.mux-id"
 	KeyMuxDestroyMuxID       = "mux.destroy.mux-id"
 	KeyMuxDisconnectMuxID    = "mux.disconnect.mux-id"
+	KeyMuxFTEAddMAC          = "mux.fte.add.mac"
+	KeyMuxFTEAddMuxID        = "mux.fte.add.mux-id"
+	KeyMuxFTEAddUnderlayAddr = "mux.fte.add.underlay-addr"
+	KeyMuxFTEAddVNI          = "mux.fte.add.vni"
+	KeyMuxFTEDelMAC          = "mux.fte.del.mac"
+	KeyMuxFTEDelMuxID        = "mux.fte.del.mux-id"
+	KeyMuxFTEDelVNI          = "mux.fte.del.vni"
+	KeyMuxFTEListMuxID       = "mux.fte.list.mux-id"
+	KeyMuxGetMAC             = "mux.get.mac"
+	KeyMuxGetMTU             = "mux.get.mtu"
+	KeyMuxGetMuxID           = "mux.get.mux-id"
 	KeyMuxListenAddr         = "mux.listen.addr"
 	KeyMuxListenMuxID        = "mux.listen.mux-id"
+	KeyMuxSetMAC             = "mux.set.mac"
+	KeyMuxSetMTU             = "mux.set.mtu"
+	KeyMuxSetMuxID           = "mux.set.mux-id"
 	KeyMuxShowMuxID          = "mux.show.mux-id"
 
 	KeySWPortAddEthLinkID          = "switch.port.add.ethlink-id"
@@ -80,7 +143,13 @@ const (
 	KeySWPortConnectPortID         = "switch.port.connect.port-id"
 	KeySWPortDisconnectInterfaceID = "switch.port.disconnect.interface-id"
 	KeySWPortDisconnectPortID      = "switch.port.disconnect.port-id"
+	KeySWPortGetMAC                = "switch.port.get.mac"
+	KeySWPortGetMTU                = "switch.port.get.mtu"
+	KeySWPortGetPortID             = "switch.port.get.port-id"
+	KeySWPortSetMAC                = "switch.port.set.mac"
+	KeySWPortSetMTU                = "switch.port.set.mtu"
 	KeySWPortSetPortID             = "switch.port.set.port-id"
+	KeySWPortSetVLAN               = "switch.port.set.vlan"
 	KeySWPortSetVNI                = "switch.port.set.vni"
 	KeySWPortUplinkPortID          = "switch.port.uplink.port-id"
 	KeySWPortUplinkSwitchID        = "switch.port.uplink.switch-id"
@@ -90,22 +159,56 @@ const (
 
 	KeyShellAutoCompBashDir = "shell.autocomplete.bash-dir"
 
-	KeySWCreateSwitchID  = "switch.create.switch-id"
-	KeySWCreateSwitchMAC = "switch.create.switch-mac"
-	KeySWCreateVNI       = "switch.create.vni"
-	KeySWDestroySwitchID = "switch.destroy.switch-id"
-
+	KeySubnetCreateCIDR  = "subnet.create.cidr"
+	KeySubnetCreateVPCID = "subnet.create.vpc-id"
+	KeySubnetDestroyID   = "subnet.destroy.subnet-id"
+	KeySubnetListVPCID   = "subnet.list.vpc-id"
+
+	KeySWCreateFacilityID  = "switch.create.facility-id"
+	KeySWCreateSwitchID    = "switch.create.switch-id"
+	KeySWCreateSwitchMAC   = "switch.create.switch-mac"
+	KeySWCreateVNI         = "switch.create.vni"
+	KeySWCreateVPCID       = "switch.create.vpc-id"
+	KeySWDestroyFacilityID = "switch.destroy.facility-id"
+	KeySWDestroySwitchID   = "switch.destroy.switch-id"
+	KeySWDestroyVNI        = "switch.destroy.vni"
+	KeySWGetMAC            = "switch.get.mac"
+	KeySWGetMTU            = "switch.get.mtu"
+	KeySWGetSwitchID       = "switch.get.switch-id"
+	KeySWResetSwitchID     = "switch.reset.switch-id"
+	KeySWSetDown           = "switch.set.down"
+	KeySWSetMAC            = "switch.set.mac"
+	KeySWSetMTU            = "switch.set.mtu"
+	KeySWSetSwitchID       = "switch.set.switch-id"
+	KeySWSetUp             = "switch.set.up"
+
+	KeyOutputFormat   = "general.output"
 	KeyUseGoogleAgent = "general.enable-agent"
 	KeyUsePager       = "general.use-pager"
 	KeyUseUTC         = "general.utc"
-
-	KeyVMNICCreateID    = "vmnic.create.id"
-	KeyVMNICCreateMAC   = "vmnic.create.mac"
-	KeyVMNICDestroyID   = "vmnic.destroy.id"
-	KeyVMNICGetNQueues  = "vmnic.get.num-queues"
-	KeyVMNICGetVMNICID  = "vmnic.get.vmnic-id"
-	KeyVMNICSetFreeze   = "vmnic.set.freeze"
-	KeyVMNICSetNQueues  = "vmnic.set.num-queues"
-	KeyVMNICSetUnfreeze = "vmnic.set.unfreeze"
-	KeyVMNICSetVMNICID  = "vmnic.set.vmnic-id"
+	KeyViaAgent       = "general.via-agent"
+
+	KeyVMNICCreateAccountID  = "vmnic.create.account-id"
+	KeyVMNICCreateID         = "vmnic.create.id"
+	KeyVMNICCreateMAC        = "vmnic.create.mac"
+	KeyVMNICCreateSubnetID   = "vmnic.create.subnet-id"
+	KeyVMNICDestroyAccountID = "vmnic.destroy.account-id"
+	KeyVMNICDestroyID        = "vmnic.destroy.id"
+	KeyVMNICGetMAC           = "vmnic.get.mac"
+	KeyVMNICGetMTU           = "vmnic.get.mtu"
+	KeyVMNICGetNQueues       = "vmnic.get.num-queues"
+	KeyVMNICGetVMNICID       = "vmnic.get.vmnic-id"
+	KeyVMNICSetFreeze        = "vmnic.set.freeze"
+	KeyVMNICSetMAC           = "vmnic.set.mac"
+	KeyVMNICSetMTU           = "vmnic.set.mtu"
+	KeyVMNICSetNQueues       = "vmnic.set.num-queues"
+	KeyVMNICSetUnfreeze      = "vmnic.set.unfreeze"
+	KeyVMNICSetVMNICID       = "vmnic.set.vmnic-id"
+
+	KeyVNICIPAddIP        = "vnic.ip.add.ip"
+	KeyVNICIPAddSubnetID  = "vnic.ip.add.subnet-id"
+	KeyVNICIPAddVNICID    = "vnic.ip.add.vnic-id"
+	KeyVNICIPListVNICID   = "vnic.ip.list.vnic-id"
+	KeyVNICIPRemoveIPID   = "vnic.ip.remove.ip-id"
+	KeyVNICIPRemoveVNICID = "vnic.ip.remove.vnic-id"
 )
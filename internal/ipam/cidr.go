@@ -0,0 +1,147 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+// Package ipam manages the subnets of a VPC and the assignment of subnet
+// addresses to VNICs.
+package ipam
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// Limits on subnet prefix lengths.  Every subnet must have room for its network
+// address, gateway, broadcast address (IPv4 only), and at least one host.
+const (
+	MaxPrefixLenIPv4 = 30
+	MaxPrefixLenIPv6 = 126
+)
+
+var (
+	// ErrExhausted is returned when a subnet has no free addresses.
+	ErrExhausted = errors.New("no free addresses in subnet")
+
+	// ErrOverlap is returned when a subnet overlaps another subnet in the same
+	// VPC.
+	ErrOverlap = errors.New("subnet overlaps an existing subnet")
+
+	// ErrReserved is returned when a reserved address is requested.
+	ErrReserved = errors.New("address is reserved")
+)
+
+// ParseSubnet parses a CIDR and verifies it is usable as a subnet: the host
+// bits must be zero and the prefix must leave room for at least one host.
+func ParseSubnet(cidr string) (net.IPNet, error) {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return net.IPNet{}, errors.Wrapf(err, "unable to parse subnet %q", cidr)
+	}
+
+	if !ip.Equal(network.IP) {
+		return net.IPNet{}, errors.Errorf("subnet %q has host bits set, did you mean %q?", cidr, network.String())
+	}
+
+	if err := ValidateSubnet(*network); err != nil {
+		return net.IPNet{}, err
+	}
+
+	return *network, nil
+}
+
+// ValidateSubnet returns an error if network's prefix length is out of range
+// for its address family.
+func ValidateSubnet(network net.IPNet) error {
+	ones, bits := network.Mask.Size()
+	maxPrefixLen := MaxPrefixLenIPv6
+	if bits == 8*net.IPv4len {
+		maxPrefixLen = MaxPrefixLenIPv4
+	}
+
+	if ones < 1 || ones > maxPrefixLen {
+		return errors.Errorf("prefix length of subnet %s must be between 1 and %d", network.String(), maxPrefixLen)
+	}
+
+	return nil
+}
+
+// Overlaps returns true if a and b have any address in common.
+func Overlaps(a, b net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// Gateway returns the address reserved for network's gateway: the first
+// address after the network address.
+func Gateway(network net.IPNet) net.IP {
+	return nextIP(network.IP.Mask(network.Mask))
+}
+
+// IsReserved returns true if ip is network's network address, gateway, or
+// (for IPv4) broadcast address.
+func IsReserved(network net.IPNet, ip net.IP) bool {
+	if ip.Equal(network.IP.Mask(network.Mask)) || ip.Equal(Gateway(network)) {
+		return true
+	}
+
+	return network.IP.To4() != nil && ip.Equal(lastIP(network))
+}
+
+// NextFree returns the lowest address in network that is neither reserved nor
+// used.
+func NextFree(network net.IPNet, used func(net.IP) bool) (net.IP, error) {
+	for ip := nextIP(Gateway(network)); network.Contains(ip); ip = nextIP(ip) {
+		if IsReserved(network, ip) || used(ip) {
+			continue
+		}
+
+		return ip, nil
+	}
+
+	return nil, ErrExhausted
+}
+
+// nextIP returns ip + 1.  The address wraps around on overflow.
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+
+	return next
+}
+
+// lastIP returns the last address in network.
+func lastIP(network net.IPNet) net.IP {
+	ip := network.IP.Mask(network.Mask)
+	last := make(net.IP, len(ip))
+	for i := range ip {
+		last[i] = ip[i] | ^network.Mask[i]
+	}
+
+	return last
+}
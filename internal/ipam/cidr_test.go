@@ -0,0 +1,136 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package ipam
+
+import (
+	"net"
+	"testing"
+)
+
+func mustParseSubnet(t *testing.T, cidr string) net.IPNet {
+	network, err := ParseSubnet(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return network
+}
+
+func TestParseSubnet(t *testing.T) {
+	tests := []struct {
+		cidr string
+		ok   bool
+	}{
+		{"10.0.0.0/24", true},
+		{"10.0.0.0/30", true},
+		{"10.0.0.0/31", false},
+		{"10.0.0.1/24", false},
+		{"0.0.0.0/0", false},
+		{"fd00::/64", true},
+		{"fd00::/126", true},
+		{"fd00::/127", false},
+		{"fd00::", false},
+	}
+
+	for _, test := range tests {
+		if _, err := ParseSubnet(test.cidr); (err == nil) != test.ok {
+			t.Errorf("ParseSubnet(%q) error = %v, want ok = %t", test.cidr, err, test.ok)
+		}
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"10.0.0.0/24", "10.0.1.0/24", false},
+		{"10.0.0.0/16", "10.0.1.0/24", true},
+		{"10.0.1.0/24", "10.0.0.0/16", true},
+		{"10.0.0.0/24", "10.0.0.0/24", true},
+		{"fd00::/64", "fd00:0:0:1::/64", false},
+		{"fd00::/48", "fd00:0:0:1::/64", true},
+		{"10.0.0.0/8", "fd00::/8", false},
+	}
+
+	for _, test := range tests {
+		a, b := mustParseSubnet(t, test.a), mustParseSubnet(t, test.b)
+		if got := Overlaps(a, b); got != test.want {
+			t.Errorf("Overlaps(%s, %s) = %t, want %t", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestNextFree(t *testing.T) {
+	tests := []struct {
+		cidr string
+		used []string
+		want string
+	}{
+		{"10.0.0.0/24", nil, "10.0.0.2"},
+		{"10.0.0.0/24", []string{"10.0.0.2", "10.0.0.4"}, "10.0.0.3"},
+		{"10.0.0.0/30", nil, "10.0.0.2"},
+		{"10.0.0.0/30", []string{"10.0.0.2"}, ""},
+		{"10.0.0.252/30", []string{"10.0.0.254"}, ""},
+		{"10.0.0.248/29", []string{"10.0.0.250", "10.0.0.251", "10.0.0.252", "10.0.0.253"}, "10.0.0.254"},
+		{"10.0.0.248/29", []string{"10.0.0.250", "10.0.0.251", "10.0.0.252", "10.0.0.253", "10.0.0.254"}, ""},
+		{"fd00::/64", []string{"fd00::2"}, "fd00::3"},
+		{"fd00::/126", []string{"fd00::2"}, "fd00::3"},
+	}
+
+	for _, test := range tests {
+		network := mustParseSubnet(t, test.cidr)
+		used := make(map[string]bool)
+		for _, ip := range test.used {
+			used[net.ParseIP(ip).String()] = true
+		}
+
+		got, err := NextFree(network, func(ip net.IP) bool { return used[ip.String()] })
+		switch {
+		case test.want == "" && err != ErrExhausted:
+			t.Errorf("NextFree(%s, %v) = %v, %v; want ErrExhausted", test.cidr, test.used, got, err)
+		case test.want != "" && (err != nil || !got.Equal(net.ParseIP(test.want))):
+			t.Errorf("NextFree(%s, %v) = %v, %v; want %s", test.cidr, test.used, got, err, test.want)
+		}
+	}
+}
+
+func TestIsReserved(t *testing.T) {
+	network := mustParseSubnet(t, "192.0.2.0/24")
+	for _, ip := range []string{"192.0.2.0", "192.0.2.1", "192.0.2.255"} {
+		if !IsReserved(network, net.ParseIP(ip)) {
+			t.Errorf("%s is not reserved in %s", ip, network.String())
+		}
+	}
+
+	if IsReserved(network, net.ParseIP("192.0.2.2")) {
+		t.Errorf("192.0.2.2 is reserved in %s", network.String())
+	}
+
+	network = mustParseSubnet(t, "2001:db8::/64")
+	if IsReserved(network, net.ParseIP("2001:db8::ffff:ffff:ffff:ffff")) {
+		t.Error("IPv6 subnets do not have a broadcast address")
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package ipam
+
+import (
+	"context"
+	"net"
+
+	"github.com/jackc/pgx"
+	"github.com/joyent/freebsd-vpc/db"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// IPAM manages subnets and address assignments stored in the database.  Every
+// operation runs in its own transaction.
+type IPAM struct {
+	pool *db.Pool
+}
+
+// New returns an IPAM backed by pool.
+func New(pool *db.Pool) *IPAM {
+	return &IPAM{pool: pool}
+}
+
+// Assignment is an address assigned to a VNIC.  Index orders the addresses of
+// a VNIC; the address with the lowest index is the VNIC's primary address.
+type Assignment struct {
+	VNICID uuid.UUID
+	Index  int
+	IP     db.SubnetIP
+}
+
+// CreateSubnet adds network to vpcID.  ErrOverlap is returned if network
+// overlaps a subnet already in the VPC.
+func (m *IPAM) CreateSubnet(ctx context.Context, vpcID uuid.UUID, network net.IPNet) (subnet db.Subnet, err error) {
+	if err := ValidateSubnet(network); err != nil {
+		return db.Subnet{}, err
+	}
+
+	err = m.pool.ExecuteTx(ctx, func(tx *pgx.Tx) error {
+		subnets, err := db.ListSubnets(ctx, tx, vpcID)
+		if err != nil {
+			return err
+		}
+
+		for _, existing := range subnets {
+			if Overlaps(existing.Network, network) {
+				return errors.Wrapf(ErrOverlap, "%s overlaps subnet %s (%s)", network.String(), existing.ID, existing.Network.String())
+			}
+		}
+
+		subnet = db.Subnet{VPCID: vpcID, Network: network}
+		return db.CreateSubnet(ctx, tx, &subnet)
+	})
+	if err != nil {
+		return db.Subnet{}, errors.Wrapf(err, "unable to create subnet %s in VPC %s", network.String(), vpcID)
+	}
+
+	return subnet, nil
+}
+
+// ListSubnets returns the subnets in vpcID.
+func (m *IPAM) ListSubnets(ctx context.Context, vpcID uuid.UUID) ([]db.Subnet, error) {
+	return db.ListSubnets(ctx, m.pool.Pool(), vpcID)
+}
+
+// DeleteSubnet deletes subnetID.  The subnet must not have any addresses
+// allocated.
+func (m *IPAM) DeleteSubnet(ctx context.Context, subnetID uuid.UUID) error {
+	err := m.pool.ExecuteTx(ctx, func(tx *pgx.Tx) error {
+		ips, err := db.ListSubnetIPs(ctx, tx, subnetID)
+		if err != nil {
+			return err
+		}
+
+		if len(ips) > 0 {
+			return errors.Errorf("subnet %s has %d addresses allocated", subnetID, len(ips))
+		}
+
+		return db.DeleteSubnet(ctx, tx, subnetID)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to delete subnet %s", subnetID)
+	}
+
+	return nil
+}
+
+// AssignIP allocates an address in subnetID and assigns it to vnicID after any
+// addresses the VNIC already has.  If ip is nil the lowest free address in the
+// subnet is used.
+func (m *IPAM) AssignIP(ctx context.Context, vnicID, subnetID uuid.UUID, ip net.IP) (a Assignment, err error) {
+	err = m.pool.ExecuteTx(ctx, func(tx *pgx.Tx) error {
+		subnet, err := db.GetSubnet(ctx, tx, subnetID)
+		if err != nil {
+			return err
+		}
+
+		ips, err := db.ListSubnetIPs(ctx, tx, subnetID)
+		if err != nil {
+			return err
+		}
+		used := make(map[string]bool, len(ips))
+		for _, ip := range ips {
+			used[ip.IP.String()] = true
+		}
+
+		addr := ip
+		switch {
+		case addr == nil:
+			addr, err = NextFree(subnet.Network, func(ip net.IP) bool { return used[ip.String()] })
+			if err != nil {
+				return err
+			}
+		case !subnet.Network.Contains(addr):
+			return errors.Errorf("%s is not in subnet %s", addr, subnet.Network.String())
+		case IsReserved(subnet.Network, addr):
+			return errors.Wrapf(ErrReserved, "%s", addr)
+		case used[addr.String()]:
+			return errors.Errorf("%s is already allocated", addr)
+		}
+
+		vnicIPs, err := db.ListVNICIPs(ctx, tx, vnicID)
+		if err != nil {
+			return err
+		}
+		index := 0
+		if n := len(vnicIPs); n > 0 {
+			index = vnicIPs[n-1].IPIndex + 1
+		}
+
+		subnetIP := db.SubnetIP{VPCID: subnet.VPCID, SubnetID: subnetID, IP: addr}
+		if err := db.CreateSubnetIP(ctx, tx, &subnetIP); err != nil {
+			return err
+		}
+
+		a = Assignment{VNICID: vnicID, Index: index, IP: subnetIP}
+		return db.CreateVNICIP(ctx, tx, db.VNICIP{VNICID: vnicID, IPID: subnetIP.ID, IPIndex: index})
+	})
+	if err != nil {
+		return Assignment{}, errors.Wrapf(err, "unable to assign an address in subnet %s to VNIC %s", subnetID, vnicID)
+	}
+
+	return a, nil
+}
+
+// ListAssignments returns the addresses assigned to vnicID ordered by index.
+func (m *IPAM) ListAssignments(ctx context.Context, vnicID uuid.UUID) (assignments []Assignment, err error) {
+	err = m.pool.ExecuteTx(ctx, func(tx *pgx.Tx) error {
+		vnicIPs, err := db.ListVNICIPs(ctx, tx, vnicID)
+		if err != nil {
+			return err
+		}
+
+		assignments = make([]Assignment, 0, len(vnicIPs))
+		for _, vnicIP := range vnicIPs {
+			ip, err := db.GetSubnetIP(ctx, tx, vnicIP.IPID)
+			if err != nil {
+				return err
+			}
+			assignments = append(assignments, Assignment{VNICID: vnicID, Index: vnicIP.IPIndex, IP: ip})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list addresses of VNIC %s", vnicID)
+	}
+
+	return assignments, nil
+}
+
+// UnassignIP removes ipID from vnicID and frees the address.
+func (m *IPAM) UnassignIP(ctx context.Context, vnicID, ipID uuid.UUID) error {
+	err := m.pool.ExecuteTx(ctx, func(tx *pgx.Tx) error {
+		if err := db.DeleteVNICIP(ctx, tx, vnicID, ipID); err != nil {
+			return err
+		}
+
+		return db.DeleteSubnetIP(ctx, tx, ipID)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to unassign address %s from VNIC %s", ipID, vnicID)
+	}
+
+	return nil
+}
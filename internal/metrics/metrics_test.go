@@ -0,0 +1,124 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+
+	calls := r.NewCounter("vpc_calls_total", "Calls made.", "type", "result")
+	calls.Inc("vpcsw", "ok")
+	calls.Inc("vpcsw", "ok")
+	calls.Add(3, "vmnic", "error")
+
+	objects := r.NewGauge("vpc_objects", "Objects by\ntype.", "type")
+	collected := 0
+	r.OnCollect(func() {
+		collected++
+		objects.Set(4, `a"b\c`)
+	})
+
+	latency := r.NewHistogram("vpc_latency_seconds", "Latency.", []float64{0.1, 1})
+	latency.Observe(0.05)
+	latency.Observe(0.1)
+	latency.Observe(2)
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+
+	const want = `# HELP vpc_calls_total Calls made.
+# TYPE vpc_calls_total counter
+vpc_calls_total{type="vmnic",result="error"} 3
+vpc_calls_total{type="vpcsw",result="ok"} 2
+# HELP vpc_latency_seconds Latency.
+# TYPE vpc_latency_seconds histogram
+vpc_latency_seconds_bucket{le="0.1"} 2
+vpc_latency_seconds_bucket{le="1"} 2
+vpc_latency_seconds_bucket{le="+Inf"} 3
+vpc_latency_seconds_sum 2.15
+vpc_latency_seconds_count 3
+# HELP vpc_objects Objects by\ntype.
+# TYPE vpc_objects gauge
+vpc_objects{type="a\"b\\c"} 4
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteText:\n%s\nwant:\n%s", got, want)
+	}
+	if collected != 1 {
+		t.Errorf("collector called %d times, want 1", collected)
+	}
+
+	objects.Reset()
+	buf.Reset()
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("vpc_objects{")) {
+		t.Errorf("collector did not repopulate the gauge after Reset")
+	}
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("vpc_calls_total", "Calls made.").Inc()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusOK)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("content type %q, want %q", ct, ContentType)
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte("vpc_calls_total 1\n")) {
+		t.Errorf("unexpected body:\n%s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestRegistry_DuplicateName(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering a metric twice did not panic")
+		}
+	}()
+
+	r := NewRegistry()
+	r.NewGauge("vpc_objects", "Objects.")
+	r.NewCounter("vpc_objects", "Objects.")
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package topology
+
+import (
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/ethlink"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/hostif"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mgmt"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mux"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vmnic"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcp"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcsw"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpctxn"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Apply executes the Steps of the Plan in order within a single vpctxn.Txn.  If
+// a Step fails, the Steps that have already been applied are undone in reverse
+// order.  Destroyed objects can not be restored, which is why the destroy Steps
+// of a Plan come last.  If non-nil, progress is called before each Step is
+// applied.
+func (p *Plan) Apply(progress func(i int, s Step)) (err error) {
+	txn := vpctxn.New()
+	defer txn.Finish(&err)
+
+	for i, step := range p.Steps {
+		if progress != nil {
+			progress(i, step)
+		}
+
+		if err := step.apply(txn); err != nil {
+			log.Error().Err(err).Str("step", step.String()).Msg("topology apply failed")
+			return errors.Wrapf(err, "unable to apply step %d (%s)", i+1, step)
+		}
+	}
+
+	return nil
+}
+
+// apply performs the Step as part of txn, which records how to revert it.
+func (s Step) apply(txn *vpctxn.Txn) error {
+	switch s.Action {
+	case ActionDestroy:
+		if s.ID.ObjType == vpc.ObjTypeSwitchPort {
+			return destroyPort(s.ID)
+		}
+
+		return destroy(s.ID)
+	case ActionCreate:
+		return create(txn, s)
+	case ActionEthLinkConnect:
+		el, err := txn.OpenEthLink(ethlink.Config{ID: s.ID, Writeable: true})
+		if err != nil {
+			return err
+		}
+
+		return txn.EthLinkConnect(el, s.Addr)
+	case ActionPortAdd:
+		sw, err := txn.OpenSwitch(vpcsw.Config{ID: s.Peer, Writeable: true})
+		if err != nil {
+			return err
+		}
+
+		return txn.PortAdd(sw, s.ID, s.MAC)
+	case ActionUplinkSet:
+		sw, err := txn.OpenSwitch(vpcsw.Config{ID: s.Peer, Writeable: true})
+		if err != nil {
+			return err
+		}
+
+		return txn.SwitchUplinkSet(sw, s.ID, s.MAC)
+	case ActionVNISet:
+		port, err := txn.OpenPort(vpcp.Config{ID: s.ID, Writeable: true})
+		if err != nil {
+			return err
+		}
+
+		return txn.PortSetVNI(port, s.VNI)
+	case ActionVLANSet:
+		port, err := txn.OpenPort(vpcp.Config{ID: s.ID, Writeable: true})
+		if err != nil {
+			return err
+		}
+
+		return txn.PortSetVLAN(port, s.VLAN)
+	case ActionPortDisconnect:
+		port, err := txn.OpenPort(vpcp.Config{ID: s.ID, Writeable: true})
+		if err != nil {
+			return err
+		}
+
+		if err := port.Disconnect(s.Peer); err != nil {
+			return errors.Wrap(err, "unable to disconnect VPC Switch Port")
+		}
+		txn.OnUndo(func() error { return port.Connect(s.Peer) })
+
+		return nil
+	case ActionPortConnect:
+		port, err := txn.OpenPort(vpcp.Config{ID: s.ID, Writeable: true})
+		if err != nil {
+			return err
+		}
+
+		return txn.PortConnect(port, s.Peer)
+	case ActionMuxListen:
+		m, err := txn.OpenMux(mux.Config{ID: s.ID, Writeable: true})
+		if err != nil {
+			return err
+		}
+
+		return txn.MuxListen(m, s.Addr)
+	case ActionMuxConnect:
+		m, err := txn.OpenMux(mux.Config{ID: s.ID, Writeable: true})
+		if err != nil {
+			return err
+		}
+
+		return txn.MuxConnect(m, s.Peer)
+	default:
+		return errors.Errorf("unsupported action %q", s.Action)
+	}
+}
+
+// create creates the VPC object identified by the Step's ID.  The object is
+// committed when txn is committed and destroyed if txn is rolled back.
+func create(txn *vpctxn.Txn, s Step) error {
+	var err error
+	switch s.ID.ObjType {
+	case vpc.ObjTypeSwitch:
+		_, err = txn.CreateSwitch(vpcsw.Config{ID: s.ID, MAC: s.ID.Node[:], VNI: s.VNI})
+	case vpc.ObjTypeHostif:
+		_, err = txn.CreateHostif(hostif.Config{ID: s.ID})
+	case vpc.ObjTypeNICVM:
+		_, err = txn.CreateVMNIC(vmnic.Config{ID: s.ID, MAC: s.MAC})
+	case vpc.ObjTypeLinkEth:
+		_, err = txn.CreateEthLink(ethlink.Config{ID: s.ID})
+	case vpc.ObjTypeMux:
+		_, err = txn.CreateMux(mux.Config{ID: s.ID, Writeable: true})
+	default:
+		return errors.Errorf("unable to create VPC object type %s", s.ID.ObjType)
+	}
+
+	return err
+}
+
+// destroy destroys the VPC object identified by id.
+func destroy(id vpc.ID) error {
+	ht, err := vpc.NewHandleType(vpc.HandleTypeInput{
+		Version: 1,
+		Type:    id.ObjType,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to create a new %s handle type", id.ObjType)
+	}
+
+	h, err := vpc.Open(id, ht, vpc.FlagOpen|vpc.FlagWrite)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %s", id.ObjType)
+	}
+	defer h.Close()
+
+	if err := h.Destroy(); err != nil {
+		return errors.Wrapf(err, "unable to destroy %s", id.ObjType)
+	}
+
+	return nil
+}
+
+// destroyPort disconnects the VPC Switch Port identified by id, if it is
+// connected, and removes it from the VPC Switch it belongs to.  The kernel does
+// not report the switch of a port so every switch is tried in turn.
+func destroyPort(id vpc.ID) error {
+	port, err := vpcp.Open(vpcp.Config{ID: id, Writeable: true})
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %s", id.ObjType)
+	}
+
+	peerID, err := port.PeerID()
+	switch {
+	case err == nil:
+		err = port.Disconnect(peerID)
+	case vpc.IsNotExist(err):
+		err = nil
+	}
+	port.Close()
+	if err != nil {
+		return errors.Wrapf(err, "unable to disconnect %s", id.ObjType)
+	}
+
+	mgr, err := mgmt.New(nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to open VPC Management handle")
+	}
+	defer mgr.Close()
+
+	switches, err := mgr.GetAllIDs(vpc.ObjTypeSwitch)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get %s VPC objects", vpc.ObjTypeSwitch)
+	}
+
+	for _, hdr := range switches {
+		sw, err := vpcsw.Open(vpcsw.Config{ID: hdr.ID(), Writeable: true})
+		if err != nil {
+			return errors.Wrap(err, "unable to open VPC Switch")
+		}
+
+		err = sw.PortRemove(id)
+		sw.Close()
+		switch {
+		case err == nil:
+			return nil
+		case !vpc.IsNotExist(err):
+			return errors.Wrapf(err, "unable to remove %s", id.ObjType)
+		}
+	}
+
+	return errors.Errorf("unable to find the %s of %s %s", vpc.ObjTypeSwitch, id.ObjType, id)
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package topology
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcsw"
+)
+
+// testTopology is a switch with a VLAN tagged port connected to a hostif and an
+// uplink port connected to a listening mux.
+type testTopology struct {
+	doc                                  *Document
+	swID, portID, uplinkID, hifID, muxID vpc.ID
+}
+
+func newTestTopology(t *testing.T) testTopology {
+	t.Helper()
+
+	tt := testTopology{
+		swID:     vpc.GenID(vpc.ObjTypeSwitch),
+		portID:   vpc.GenID(vpc.ObjTypeSwitchPort),
+		uplinkID: vpc.GenID(vpc.ObjTypeSwitchPort),
+		hifID:    vpc.GenID(vpc.ObjTypeHostif),
+		muxID:    vpc.GenID(vpc.ObjTypeMux),
+	}
+
+	vlan := uint16(42)
+	tt.doc = &Document{
+		Switches: []Switch{{
+			ID:  tt.swID.String(),
+			VNI: 123,
+			Ports: []Port{
+				{ID: tt.portID.String(), VLAN: &vlan, Connect: tt.hifID.String()},
+				{ID: tt.uplinkID.String(), Uplink: true, Connect: tt.muxID.String()},
+			},
+		}},
+		Hostifs: []Hostif{{ID: tt.hifID.String()}},
+		Muxes:   []Mux{{ID: tt.muxID.String(), Listen: "192.0.2.1:4789"}},
+	}
+
+	plan, err := NewPlan(tt.doc, State{})
+	if err != nil {
+		t.Fatalf("unable to plan topology: %v", err)
+	}
+
+	if err := plan.Apply(nil); err != nil {
+		t.Fatalf("unable to apply topology: %v", err)
+	}
+
+	return tt
+}
+
+// assertConverged fails the test if the VPC objects on the host do not match
+// doc.
+func assertConverged(t *testing.T, doc *Document) State {
+	t.Helper()
+
+	state, err := ReadState()
+	if err != nil {
+		t.Fatalf("unable to read state: %v", err)
+	}
+
+	wantState := State{}
+	for _, id := range doc.IDs() {
+		wantState[id] = true
+	}
+	if !reflect.DeepEqual(state, wantState) {
+		t.Fatalf("state mismatch:\ngot:  %v\nwant: %v", state, wantState)
+	}
+
+	steps, err := Drift(doc, state)
+	if err != nil {
+		t.Fatalf("unable to compute drift: %v", err)
+	}
+	if len(steps) != 0 {
+		t.Fatalf("expected no drift, got %v", steps)
+	}
+
+	return state
+}
+
+func TestPlan_Apply(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	tt := newTestTopology(t)
+	state := assertConverged(t, tt.doc)
+
+	plan, err := NewPlan(tt.doc, state)
+	if err != nil {
+		t.Fatalf("unable to plan topology: %v", err)
+	}
+	if !plan.Empty() {
+		t.Fatalf("expected an empty plan after apply, got %v", plan.Steps)
+	}
+}
+
+func TestPlan_ApplyRollback(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	tt := newTestTopology(t)
+
+	hifID := vpc.GenID(vpc.ObjTypeHostif)
+	portID := vpc.GenID(vpc.ObjTypeSwitchPort)
+	plan := &Plan{Steps: []Step{
+		{Action: ActionCreate, ID: hifID},
+		{Action: ActionPortAdd, ID: portID, Peer: tt.swID},
+		{Action: ActionUplinkSet, ID: portID, Peer: tt.swID},
+		{Action: ActionVNISet, ID: tt.portID, VNI: 456},
+		{Action: ActionVLANSet, ID: tt.portID, VLAN: 7},
+		{Action: ActionPortDisconnect, ID: tt.portID, Peer: tt.hifID},
+		{Action: ActionPortConnect, ID: portID, Peer: hifID},
+		{Action: ActionMuxListen, ID: tt.muxID, Addr: "192.0.2.2:4789"},
+		{Action: ActionPortConnect, ID: tt.portID, Peer: vpc.GenID(vpc.ObjTypeHostif)},
+	}}
+
+	var applied []Action
+	err := plan.Apply(func(i int, s Step) { applied = append(applied, s.Action) })
+	if err == nil {
+		t.Fatalf("expected the connection to a missing hostif to fail")
+	}
+	if len(applied) != len(plan.Steps) {
+		t.Fatalf("expected %d steps to be attempted, got %d", len(plan.Steps), len(applied))
+	}
+
+	assertConverged(t, tt.doc)
+
+	sw, err := vpcsw.Open(vpcsw.Config{ID: tt.swID})
+	if err != nil {
+		t.Fatalf("unable to open switch: %v", err)
+	}
+	defer sw.Close()
+
+	if id, err := sw.UplinkGet(); err != nil || id != tt.uplinkID {
+		t.Fatalf("expected uplink %s after rollback, got %s (%v)", tt.uplinkID, id, err)
+	}
+}
+
+func TestPlan_ApplyDestroyConnectedPort(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	tt := newTestTopology(t)
+
+	// Drop the port connected to the hostif but keep the hostif
+	tt.doc.Switches[0].Ports = tt.doc.Switches[0].Ports[1:]
+
+	state, err := ReadState()
+	if err != nil {
+		t.Fatalf("unable to read state: %v", err)
+	}
+
+	plan, err := NewPrunePlan(tt.doc, state, func(vpc.ID) bool { return true })
+	if err != nil {
+		t.Fatalf("unable to plan topology: %v", err)
+	}
+
+	wantSteps := []Step{{Action: ActionDestroy, ID: tt.portID}}
+	if !reflect.DeepEqual(plan.Steps, wantSteps) {
+		t.Fatalf("plan mismatch:\ngot:  %v\nwant: %v", plan.Steps, wantSteps)
+	}
+
+	if err := plan.Apply(nil); err != nil {
+		t.Fatalf("unable to apply topology: %v", err)
+	}
+
+	assertConverged(t, tt.doc)
+}
+
+func TestPlan_ApplyPruneRollback(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	tt := newTestTopology(t)
+
+	// Drop the port connected to the hostif and add a new hostif
+	sw := tt.doc.Switches[0]
+	sw.Ports = sw.Ports[1:]
+	pruned := *tt.doc
+	pruned.Switches = []Switch{sw}
+	pruned.Hostifs = append([]Hostif{{ID: vpc.GenID(vpc.ObjTypeHostif).String()}}, tt.doc.Hostifs...)
+
+	state, err := ReadState()
+	if err != nil {
+		t.Fatalf("unable to read state: %v", err)
+	}
+
+	plan, err := NewPrunePlan(&pruned, state, func(vpc.ID) bool { return true })
+	if err != nil {
+		t.Fatalf("unable to plan topology: %v", err)
+	}
+
+	// The uplink port is already connected so this Step fails before the port
+	// is destroyed.
+	plan.Append(Step{Action: ActionPortConnect, ID: tt.uplinkID, Peer: tt.hifID})
+
+	if err := plan.Apply(nil); err == nil {
+		t.Fatalf("expected the connection of a connected port to fail")
+	}
+
+	assertConverged(t, tt.doc)
+}
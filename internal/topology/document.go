@@ -0,0 +1,339 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package topology
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// portInterfaceTypes and muxInterfaceTypes are the types of the VPC
+// interfaces that can be connected to a VPC Switch Port and to a VPC Mux.
+var (
+	portInterfaceTypes = []vpc.ObjType{vpc.ObjTypeHostif, vpc.ObjTypeNICVM, vpc.ObjTypeLinkEth, vpc.ObjTypeMux}
+	muxInterfaceTypes  = []vpc.ObjType{vpc.ObjTypeHostif, vpc.ObjTypeNICVM, vpc.ObjTypeLinkEth}
+)
+
+// Document is the declarative description of the VPC objects that should exist
+// on a host and how they are connected to each other.
+type Document struct {
+	Switches []Switch  `json:"switches,omitempty" yaml:"switches,omitempty"`
+	Hostifs  []Hostif  `json:"hostifs,omitempty" yaml:"hostifs,omitempty"`
+	VMNICs   []VMNIC   `json:"vmnics,omitempty" yaml:"vmnics,omitempty"`
+	EthLinks []EthLink `json:"ethlinks,omitempty" yaml:"ethlinks,omitempty"`
+	Muxes    []Mux     `json:"muxes,omitempty" yaml:"muxes,omitempty"`
+}
+
+// Switch describes a VPC Switch and its ports.  The VNI of the switch is
+// applied to every non-uplink port that does not set its own VNI.
+type Switch struct {
+	ID    string `json:"id" yaml:"id"`
+	VNI   uint32 `json:"vni" yaml:"vni"`
+	Ports []Port `json:"ports,omitempty" yaml:"ports,omitempty"`
+}
+
+// Port describes a VPC Switch Port.  Connect is the VPC ID of the interface
+// (Hostif, VM NIC, EthLink, or Mux) connected to the port.  VLAN is only
+// applied when set.
+type Port struct {
+	ID      string  `json:"id" yaml:"id"`
+	VNI     *uint32 `json:"vni,omitempty" yaml:"vni,omitempty"`
+	VLAN    *uint16 `json:"vlan,omitempty" yaml:"vlan,omitempty"`
+	MAC     string  `json:"mac,omitempty" yaml:"mac,omitempty"`
+	Uplink  bool    `json:"uplink,omitempty" yaml:"uplink,omitempty"`
+	Connect string  `json:"connect,omitempty" yaml:"connect,omitempty"`
+}
+
+// Hostif describes a VPC Hostif NIC.
+type Hostif struct {
+	ID string `json:"id" yaml:"id"`
+}
+
+// VMNIC describes a VPC VM NIC.
+type VMNIC struct {
+	ID  string `json:"id" yaml:"id"`
+	MAC string `json:"mac,omitempty" yaml:"mac,omitempty"`
+}
+
+// EthLink describes a VPC EthLink and the L2 interface it is attached to.
+type EthLink struct {
+	ID     string `json:"id" yaml:"id"`
+	L2Name string `json:"l2-name,omitempty" yaml:"l2-name,omitempty"`
+}
+
+// Mux describes a VPC Mux, its underlay listen address, and the VPC ID of the
+// interface it is connected to.
+type Mux struct {
+	ID      string `json:"id" yaml:"id"`
+	Listen  string `json:"listen,omitempty" yaml:"listen,omitempty"`
+	Connect string `json:"connect,omitempty" yaml:"connect,omitempty"`
+}
+
+// Load reads a Document from the named file.  A filename of "-" reads the
+// Document from stdin.
+func Load(filename string) (*Document, error) {
+	var r io.Reader
+	switch filename {
+	case "-":
+		r = os.Stdin
+	default:
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to open topology %q", filename)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read topology %q", filename)
+	}
+
+	doc, err := Parse(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse topology %q", filename)
+	}
+
+	return doc, nil
+}
+
+// Parse decodes and validates a YAML (or JSON) encoded Document.
+func Parse(b []byte) (*Document, error) {
+	doc := &Document{}
+	if err := yaml.UnmarshalStrict(b, doc); err != nil {
+		return nil, errors.Wrap(err, "unable to decode topology")
+	}
+
+	if err := doc.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid topology")
+	}
+
+	return doc, nil
+}
+
+// Validate checks the Document for malformed IDs, IDs with the wrong object
+// type, duplicate objects, and connections to objects that are not declared
+// in the Document or are not VPC interfaces.
+func (doc *Document) Validate() error {
+	seen := make(map[vpc.ID]bool)
+	declare := func(idStr string, objType vpc.ObjType) (vpc.ID, error) {
+		id, err := parseID(idStr, objType)
+		if err != nil {
+			return vpc.ID{}, err
+		}
+
+		if seen[id] {
+			return vpc.ID{}, errors.Errorf("duplicate %s %q", objType, idStr)
+		}
+		seen[id] = true
+
+		return id, nil
+	}
+
+	for _, sw := range doc.Switches {
+		if _, err := declare(sw.ID, vpc.ObjTypeSwitch); err != nil {
+			return err
+		}
+
+		if vpc.VNI(sw.VNI) > vpc.VNIMax {
+			return errors.Errorf("VNI %d of %s %q exceeds max value", sw.VNI, vpc.ObjTypeSwitch, sw.ID)
+		}
+
+		var numUplinks int
+		for _, port := range sw.Ports {
+			if _, err := declare(port.ID, vpc.ObjTypeSwitchPort); err != nil {
+				return err
+			}
+
+			if port.VNI != nil && vpc.VNI(*port.VNI) > vpc.VNIMax {
+				return errors.Errorf("VNI %d of %s %q exceeds max value", *port.VNI, vpc.ObjTypeSwitchPort, port.ID)
+			}
+
+			if port.VLAN != nil && vpc.VTag(*port.VLAN) > vpc.VTagMax {
+				return errors.Errorf("VLAN %d of %s %q exceeds max value", *port.VLAN, vpc.ObjTypeSwitchPort, port.ID)
+			}
+
+			if port.MAC != "" {
+				if _, err := net.ParseMAC(port.MAC); err != nil {
+					return errors.Wrapf(err, "unable to parse MAC of %s %q", vpc.ObjTypeSwitchPort, port.ID)
+				}
+			}
+
+			if port.Uplink {
+				numUplinks++
+			}
+		}
+
+		if numUplinks > 1 {
+			return errors.Errorf("%s %q has %d uplink ports", vpc.ObjTypeSwitch, sw.ID, numUplinks)
+		}
+	}
+
+	for _, hif := range doc.Hostifs {
+		if _, err := declare(hif.ID, vpc.ObjTypeHostif); err != nil {
+			return err
+		}
+	}
+
+	for _, vmn := range doc.VMNICs {
+		if _, err := declare(vmn.ID, vpc.ObjTypeNICVM); err != nil {
+			return err
+		}
+
+		if vmn.MAC != "" {
+			if _, err := net.ParseMAC(vmn.MAC); err != nil {
+				return errors.Wrapf(err, "unable to parse MAC of %s %q", vpc.ObjTypeNICVM, vmn.ID)
+			}
+		}
+	}
+
+	for _, el := range doc.EthLinks {
+		if _, err := declare(el.ID, vpc.ObjTypeLinkEth); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range doc.Muxes {
+		if _, err := declare(m.ID, vpc.ObjTypeMux); err != nil {
+			return err
+		}
+	}
+
+	// Connections are validated once every object has been declared so that
+	// objects can reference each other regardless of the order they appear in.
+	connected := make(map[vpc.ID]string)
+	connect := func(from, to string, objTypes []vpc.ObjType) error {
+		id, err := vpc.ParseID(to)
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse VPC ID %q connected to %q", to, from)
+		}
+
+		if !seen[id] {
+			return errors.Errorf("%q is connected to %q which is not declared in the topology", from, to)
+		}
+
+		var isInterface bool
+		for _, objType := range objTypes {
+			if id.ObjType == objType {
+				isInterface = true
+				break
+			}
+		}
+		if !isInterface {
+			return errors.Errorf("%q is connected to %s %q which is not a VPC interface", from, id.ObjType, to)
+		}
+
+		if prev, found := connected[id]; found {
+			return errors.Errorf("%q is connected to both %q and %q", to, prev, from)
+		}
+		connected[id] = from
+
+		return nil
+	}
+
+	for _, sw := range doc.Switches {
+		for _, port := range sw.Ports {
+			if port.Connect == "" {
+				continue
+			}
+
+			if err := connect(port.ID, port.Connect, portInterfaceTypes); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, m := range doc.Muxes {
+		if m.Connect == "" {
+			continue
+		}
+
+		if err := connect(m.ID, m.Connect, muxInterfaceTypes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IDs returns the VPC IDs of every object declared in a validated Document.
+func (doc *Document) IDs() []vpc.ID {
+	var ids []vpc.ID
+	for _, sw := range doc.Switches {
+		ids = append(ids, mustParseID(sw.ID))
+		for _, port := range sw.Ports {
+			ids = append(ids, mustParseID(port.ID))
+		}
+	}
+	for _, hif := range doc.Hostifs {
+		ids = append(ids, mustParseID(hif.ID))
+	}
+	for _, vmn := range doc.VMNICs {
+		ids = append(ids, mustParseID(vmn.ID))
+	}
+	for _, el := range doc.EthLinks {
+		ids = append(ids, mustParseID(el.ID))
+	}
+	for _, m := range doc.Muxes {
+		ids = append(ids, mustParseID(m.ID))
+	}
+
+	return ids
+}
+
+// parseID parses idStr and verifies the object type encoded in the VPC ID.
+func parseID(idStr string, objType vpc.ObjType) (vpc.ID, error) {
+	if idStr == "" {
+		return vpc.ID{}, errors.Errorf("missing %s ID", objType)
+	}
+
+	id, err := vpc.ParseID(idStr)
+	if err != nil {
+		return vpc.ID{}, errors.Wrapf(err, "unable to parse %s ID %q", objType, idStr)
+	}
+
+	if id.ObjType != objType {
+		return vpc.ID{}, errors.Errorf("VPC ID %q is a %s, not a %s", idStr, id.ObjType, objType)
+	}
+
+	return id, nil
+}
+
+// mustParseID parses an ID that has already been checked by Validate.
+func mustParseID(idStr string) vpc.ID {
+	id, err := vpc.ParseID(idStr)
+	if err != nil {
+		panic(err)
+	}
+
+	return id
+}
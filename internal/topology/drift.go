@@ -0,0 +1,175 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package topology
+
+import (
+	"net"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mux"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcp"
+	"github.com/pkg/errors"
+)
+
+// Drift returns the Steps required to reconfigure the objects in state that
+// are declared in doc but whose configuration no longer matches it.  The
+// VNI, VLAN, and peer of switch ports and the listen address and peer of
+// muxes are compared.  Objects missing from state are ignored: NewPlan
+// configures them when they are created.
+func Drift(doc *Document, state State) ([]Step, error) {
+	if err := doc.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid topology")
+	}
+
+	var steps []Step
+	for _, sw := range doc.Switches {
+		for _, port := range sw.Ports {
+			portID := mustParseID(port.ID)
+			if !state[portID] {
+				continue
+			}
+
+			portSteps, err := portDrift(sw, port, portID)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read %s %s", vpc.ObjTypeSwitchPort, portID)
+			}
+			steps = append(steps, portSteps...)
+		}
+	}
+
+	for _, m := range doc.Muxes {
+		muxID := mustParseID(m.ID)
+		if !state[muxID] {
+			continue
+		}
+
+		muxSteps, err := muxDrift(m, muxID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read %s %s", vpc.ObjTypeMux, muxID)
+		}
+		steps = append(steps, muxSteps...)
+	}
+
+	return steps, nil
+}
+
+func portDrift(sw Switch, port Port, portID vpc.ID) ([]Step, error) {
+	p, err := vpcp.Open(vpcp.Config{ID: portID})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open VPC Switch Port")
+	}
+	defer p.Close()
+
+	var steps []Step
+	if !port.Uplink {
+		wantVNI := vpc.VNI(sw.VNI)
+		if port.VNI != nil {
+			wantVNI = vpc.VNI(*port.VNI)
+		}
+
+		vni, err := p.GetVNI()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to get VPC Switch Port VNI")
+		}
+
+		if vni != wantVNI {
+			steps = append(steps, Step{Action: ActionVNISet, ID: portID, VNI: wantVNI})
+		}
+
+		if port.VLAN != nil {
+			vlan, err := p.VLAN()
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to get VPC Switch Port VLAN")
+			}
+
+			if wantVLAN := vpc.VTag(*port.VLAN); vlan != wantVLAN {
+				steps = append(steps, Step{Action: ActionVLANSet, ID: portID, VLAN: wantVLAN})
+			}
+		}
+	}
+
+	peerID, err := p.PeerID()
+	connected := true
+	switch {
+	case vpc.IsNotExist(err):
+		connected = false
+	case err != nil:
+		return nil, errors.Wrap(err, "unable to get VPC Switch Port peer")
+	}
+
+	var wantPeer vpc.ID
+	if port.Connect != "" {
+		wantPeer = mustParseID(port.Connect)
+	}
+
+	if connected && (port.Connect == "" || peerID != wantPeer) {
+		steps = append(steps, Step{Action: ActionPortDisconnect, ID: portID, Peer: peerID})
+		connected = false
+	}
+
+	if !connected && port.Connect != "" {
+		steps = append(steps, Step{Action: ActionPortConnect, ID: portID, Peer: wantPeer})
+	}
+
+	return steps, nil
+}
+
+func muxDrift(m Mux, muxID vpc.ID) ([]Step, error) {
+	vm, err := mux.Open(mux.Config{ID: muxID})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open VPC Mux")
+	}
+	defer vm.Close()
+
+	var steps []Step
+	if m.Listen != "" {
+		want, err := net.ResolveUDPAddr("udp", m.Listen)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to resolve listen address %q", m.Listen)
+		}
+
+		listenAddr, err := vm.ListenAddr()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to get VPC Mux listen address")
+		}
+
+		if listenAddr == nil || listenAddr.String() != want.String() {
+			steps = append(steps, Step{Action: ActionMuxListen, ID: muxID, Addr: m.Listen})
+		}
+	}
+
+	if m.Connect != "" {
+		wantPeer := mustParseID(m.Connect)
+		peerID, err := vm.ConnectedID()
+		switch {
+		case vpc.IsNotExist(err), err == nil && peerID != wantPeer:
+			steps = append(steps, Step{Action: ActionMuxConnect, ID: muxID, Peer: wantPeer})
+		case err != nil:
+			return nil, errors.Wrap(err, "unable to get VPC Mux connected interface")
+		}
+	}
+
+	return steps, nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package topology
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcp"
+)
+
+const _DriftTopology = `
+switches:
+  - id: da64c3f3-095d-91e5-df01-5aabcfc52468
+    vni: 123
+    ports:
+      - id: fd436f9c-1f77-11e8-8002-0cc47a6c7d1e
+        vlan: 42
+        connect: 1b0bd9d8-2a8c-11e8-800b-0cc47a6c7d1e
+hostifs:
+  - id: 1b0bd9d8-2a8c-11e8-800b-0cc47a6c7d1e
+muxes:
+  - id: 07f95a11-6788-11e8-8005-0cc47a6c7d1e
+    listen: 192.0.2.1:4789
+`
+
+func TestDrift_Simulator(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	doc, err := Parse([]byte(_DriftTopology))
+	if err != nil {
+		t.Fatalf("unable to parse topology: %v", err)
+	}
+
+	plan, err := NewPlan(doc, State{})
+	if err != nil {
+		t.Fatalf("unable to plan topology: %v", err)
+	}
+
+	if err := plan.Apply(nil); err != nil {
+		t.Fatalf("unable to apply topology: %v", err)
+	}
+
+	state, err := ReadState()
+	if err != nil {
+		t.Fatalf("unable to read state: %v", err)
+	}
+
+	steps, err := Drift(doc, state)
+	if err != nil {
+		t.Fatalf("unable to compute drift: %v", err)
+	}
+	if len(steps) != 0 {
+		t.Fatalf("expected no drift after apply, got %v", steps)
+	}
+
+	// Reconfigure the port behind the topology's back
+	portID := mustParseID("fd436f9c-1f77-11e8-8002-0cc47a6c7d1e")
+	hostifID := mustParseID("1b0bd9d8-2a8c-11e8-800b-0cc47a6c7d1e")
+	port, err := vpcp.Open(vpcp.Config{ID: portID, Writeable: true})
+	if err != nil {
+		t.Fatalf("unable to open port: %v", err)
+	}
+	if err := port.SetVNI(456); err != nil {
+		t.Fatalf("unable to set VNI: %v", err)
+	}
+	if err := port.SetVLAN(7); err != nil {
+		t.Fatalf("unable to set VLAN: %v", err)
+	}
+	if err := port.Disconnect(hostifID); err != nil {
+		t.Fatalf("unable to disconnect port: %v", err)
+	}
+	port.Close()
+
+	steps, err = Drift(doc, state)
+	if err != nil {
+		t.Fatalf("unable to compute drift: %v", err)
+	}
+
+	wantSteps := []Step{
+		{Action: ActionVNISet, ID: portID, VNI: 123},
+		{Action: ActionVLANSet, ID: portID, VLAN: 42},
+		{Action: ActionPortConnect, ID: portID, Peer: hostifID},
+	}
+	if !reflect.DeepEqual(steps, wantSteps) {
+		t.Fatalf("drift mismatch:\ngot:  %v\nwant: %v", steps, wantSteps)
+	}
+
+	if err := (&Plan{Steps: steps}).Apply(nil); err != nil {
+		t.Fatalf("unable to apply drift: %v", err)
+	}
+
+	steps, err = Drift(doc, state)
+	if err != nil {
+		t.Fatalf("unable to compute drift: %v", err)
+	}
+	if len(steps) != 0 {
+		t.Fatalf("expected no drift after reconfiguring, got %v", steps)
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package topology
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/ethlink"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mgmt"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mux"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcp"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/vpcsw"
+	"github.com/pkg/errors"
+)
+
+// EdgeKind describes how two Nodes in a Graph are connected.
+type EdgeKind string
+
+const (
+	// EdgeUplink connects a VPC Switch to its uplink VPC Switch Port.
+	EdgeUplink EdgeKind = "uplink"
+
+	// EdgePeer connects a VPC Switch Port to the interface connected to it.
+	EdgePeer EdgeKind = "peer"
+
+	// EdgeConnect connects a VPC Mux to the interface connected to it.
+	EdgeConnect EdgeKind = "connect"
+)
+
+// Node is a VPC object in a Graph.  VNI and VLAN are only set on VPC Switch
+// Ports, L2Name on VPC EthLinks, and Listen on VPC Muxes.
+type Node struct {
+	ID       string  `json:"id"`
+	Type     string  `json:"type"`
+	UnitName string  `json:"unit-name"`
+	VNI      *uint32 `json:"vni,omitempty"`
+	VLAN     *uint16 `json:"vlan,omitempty"`
+	L2Name   string  `json:"l2-name,omitempty"`
+	Listen   string  `json:"listen,omitempty"`
+}
+
+// Edge is a directed connection between two Nodes in a Graph.
+type Edge struct {
+	From string   `json:"from"`
+	To   string   `json:"to"`
+	Kind EdgeKind `json:"kind"`
+}
+
+// Graph describes how the VPC objects on a host are connected to each other.
+// Nodes are sorted by type and ID and Edges by their endpoints.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// ReadGraph walks the VPC objects on this host and returns the Graph
+// describing how they are connected.  Connections that are not established
+// (i.e. a VPC Switch without an uplink) are omitted.
+func ReadGraph() (*Graph, error) {
+	mgr, err := mgmt.New(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open VPC Management handle")
+	}
+	defer mgr.Close()
+
+	g := &Graph{
+		Nodes: []Node{},
+		Edges: []Edge{},
+	}
+
+	for _, objType := range managedTypes {
+		objHeaders, err := mgr.GetAllIDs(objType)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get %s VPC objects", objType)
+		}
+
+		for _, hdr := range objHeaders {
+			node := Node{
+				ID:       hdr.ID().String(),
+				Type:     objType.String(),
+				UnitName: hdr.UnitName(),
+			}
+
+			edges, err := readNode(hdr.ID(), &node)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read %s %s", objType, hdr.ID())
+			}
+
+			g.Nodes = append(g.Nodes, node)
+			g.Edges = append(g.Edges, edges...)
+		}
+	}
+
+	g.sort()
+
+	return g, nil
+}
+
+// readNode populates the attributes of node and returns the Edges originating
+// from the VPC object identified by id.
+func readNode(id vpc.ID, node *Node) ([]Edge, error) {
+	switch id.ObjType {
+	case vpc.ObjTypeSwitch:
+		sw, err := vpcsw.Open(vpcsw.Config{ID: id})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to open VPC Switch")
+		}
+		defer sw.Close()
+
+		uplinkID, err := sw.UplinkGet()
+		switch {
+		case vpc.IsNotExist(err):
+			return nil, nil
+		case err != nil:
+			return nil, errors.Wrap(err, "unable to get VPC Switch uplink")
+		}
+
+		return []Edge{{From: node.ID, To: uplinkID.String(), Kind: EdgeUplink}}, nil
+	case vpc.ObjTypeSwitchPort:
+		port, err := vpcp.Open(vpcp.Config{ID: id})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to open VPC Switch Port")
+		}
+		defer port.Close()
+
+		vni, err := port.GetVNI()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to get VPC Switch Port VNI")
+		}
+		vniVal := uint32(vni)
+		node.VNI = &vniVal
+
+		vlan, err := port.VLAN()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to get VPC Switch Port VLAN")
+		}
+		vlanVal := uint16(vlan)
+		node.VLAN = &vlanVal
+
+		peerID, err := port.PeerID()
+		switch {
+		case vpc.IsNotExist(err):
+			return nil, nil
+		case err != nil:
+			return nil, errors.Wrap(err, "unable to get VPC Switch Port peer")
+		}
+
+		return []Edge{{From: node.ID, To: peerID.String(), Kind: EdgePeer}}, nil
+	case vpc.ObjTypeMux:
+		m, err := mux.Open(mux.Config{ID: id})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to open VPC Mux")
+		}
+		defer m.Close()
+
+		listenAddr, err := m.ListenAddr()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to get VPC Mux listen address")
+		}
+		if listenAddr != nil {
+			node.Listen = listenAddr.String()
+		}
+
+		peerID, err := m.ConnectedID()
+		switch {
+		case vpc.IsNotExist(err):
+			return nil, nil
+		case err != nil:
+			return nil, errors.Wrap(err, "unable to get VPC Mux connected interface")
+		}
+
+		return []Edge{{From: node.ID, To: peerID.String(), Kind: EdgeConnect}}, nil
+	case vpc.ObjTypeLinkEth:
+		el, err := ethlink.Open(ethlink.Config{ID: id})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to open VPC EthLink")
+		}
+		defer el.Close()
+
+		l2Name, err := el.ConnectedName()
+		switch {
+		case vpc.IsNotExist(err):
+		case err != nil:
+			return nil, errors.Wrap(err, "unable to get VPC EthLink L2 interface")
+		default:
+			node.L2Name = l2Name
+		}
+
+		return nil, nil
+	default:
+		return nil, nil
+	}
+}
+
+// sort orders the Nodes and Edges of the Graph so that its rendering is
+// stable.
+func (g *Graph) sort() {
+	sort.SliceStable(g.Nodes, func(i, j int) bool {
+		if g.Nodes[i].Type != g.Nodes[j].Type {
+			return g.Nodes[i].Type < g.Nodes[j].Type
+		}
+		return g.Nodes[i].ID < g.Nodes[j].ID
+	})
+
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+}
+
+// WriteJSON renders the Graph as JSON.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(g); err != nil {
+		return errors.Wrap(err, "unable to encode graph as JSON")
+	}
+
+	return nil
+}
+
+// WriteDOT renders the Graph in the Graphviz DOT language.  VPC Switch Ports
+// with a non-zero VNI are clustered by VNI.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var buf bytes.Buffer
+
+	buf.WriteString("digraph vpc {\n")
+	buf.WriteString("\trankdir=LR;\n")
+	buf.WriteString("\tnode [shape=box];\n")
+
+	portsByVNI := make(map[uint32][]Node)
+	var vnis []uint32
+	for _, node := range g.Nodes {
+		if node.VNI != nil && *node.VNI != 0 {
+			if _, found := portsByVNI[*node.VNI]; !found {
+				vnis = append(vnis, *node.VNI)
+			}
+			portsByVNI[*node.VNI] = append(portsByVNI[*node.VNI], node)
+			continue
+		}
+
+		fmt.Fprintf(&buf, "\t%s;\n", dotNode(node))
+	}
+
+	sort.Slice(vnis, func(i, j int) bool { return vnis[i] < vnis[j] })
+	for _, vni := range vnis {
+		fmt.Fprintf(&buf, "\tsubgraph \"cluster_vni_%d\" {\n", vni)
+		fmt.Fprintf(&buf, "\t\tlabel=%s;\n", dotQuote("vni "+strconv.FormatUint(uint64(vni), 10)))
+		for _, node := range portsByVNI[vni] {
+			fmt.Fprintf(&buf, "\t\t%s;\n", dotNode(node))
+		}
+		buf.WriteString("\t}\n")
+	}
+
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&buf, "\t%s -> %s [label=%s];\n", dotQuote(edge.From), dotQuote(edge.To), dotQuote(string(edge.Kind)))
+	}
+
+	buf.WriteString("}\n")
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return errors.Wrap(err, "unable to write DOT graph")
+	}
+
+	return nil
+}
+
+// dotNode returns the DOT statement for a Node.  The label includes the unit
+// name, the VPC ID, and the Node's attributes.
+func dotNode(node Node) string {
+	lines := []string{node.UnitName, node.ID}
+	if node.VLAN != nil && *node.VLAN != 0 {
+		lines = append(lines, "vlan "+strconv.FormatUint(uint64(*node.VLAN), 10))
+	}
+	if node.L2Name != "" {
+		lines = append(lines, "l2 "+node.L2Name)
+	}
+	if node.Listen != "" {
+		lines = append(lines, "listen "+node.Listen)
+	}
+
+	return fmt.Sprintf("%s [label=%s]", dotQuote(node.ID), dotQuote(strings.Join(lines, "\n")))
+}
+
+// dotQuote returns s as a quoted DOT ID.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package topology
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+)
+
+const _GraphTopology = `
+switches:
+  - id: da64c3f3-095d-91e5-df01-5aabcfc52468
+    vni: 123
+    ports:
+      - id: fd436f9c-1f77-11e8-8002-0cc47a6c7d1e
+        connect: 1b0bd9d8-2a8c-11e8-800b-0cc47a6c7d1e
+      - id: 0ebf50e1-1f79-11e8-8002-0cc47a6c7d1e
+        uplink: true
+        connect: 5c4acd32-1b8d-11e8-b408-0cc47a6c7d1e
+hostifs:
+  - id: 1b0bd9d8-2a8c-11e8-800b-0cc47a6c7d1e
+ethlinks:
+  - id: 5c4acd32-1b8d-11e8-b408-0cc47a6c7d1e
+    l2-name: em0
+`
+
+func TestGraph_Simulator(t *testing.T) {
+	prev := vpc.SetBackend(vpc.NewSimulator())
+	defer vpc.SetBackend(prev)
+
+	doc, err := Parse([]byte(_GraphTopology))
+	if err != nil {
+		t.Fatalf("unable to parse topology: %v", err)
+	}
+
+	plan, err := NewPlan(doc, State{})
+	if err != nil {
+		t.Fatalf("unable to plan topology: %v", err)
+	}
+
+	if err := plan.Apply(nil); err != nil {
+		t.Fatalf("unable to apply topology: %v", err)
+	}
+
+	g, err := ReadGraph()
+	if err != nil {
+		t.Fatalf("unable to read graph: %v", err)
+	}
+
+	if len(g.Nodes) != 5 {
+		t.Fatalf("expected 5 nodes, got %d: %+v", len(g.Nodes), g.Nodes)
+	}
+
+	wantEdges := []Edge{
+		{From: "0ebf50e1-1f79-11e8-8002-0cc47a6c7d1e", To: "5c4acd32-1b8d-11e8-b408-0cc47a6c7d1e", Kind: EdgePeer},
+		{From: "da64c3f3-095d-91e5-df01-5aabcfc52468", To: "0ebf50e1-1f79-11e8-8002-0cc47a6c7d1e", Kind: EdgeUplink},
+		{From: "fd436f9c-1f77-11e8-8002-0cc47a6c7d1e", To: "1b0bd9d8-2a8c-11e8-800b-0cc47a6c7d1e", Kind: EdgePeer},
+	}
+	if !reflect.DeepEqual(g.Edges, wantEdges) {
+		t.Fatalf("edge mismatch:\ngot:  %+v\nwant: %+v", g.Edges, wantEdges)
+	}
+
+	for _, node := range g.Nodes {
+		switch node.ID {
+		case "5c4acd32-1b8d-11e8-b408-0cc47a6c7d1e":
+			if node.L2Name != "em0" {
+				t.Errorf("expected ethlink L2 name %q, got %q", "em0", node.L2Name)
+			}
+		case "fd436f9c-1f77-11e8-8002-0cc47a6c7d1e":
+			if node.VNI == nil || *node.VNI != 123 {
+				t.Errorf("expected port VNI 123, got %v", node.VNI)
+			}
+		}
+	}
+
+	var dot bytes.Buffer
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatalf("unable to render DOT: %v", err)
+	}
+
+	for _, want := range []string{
+		`digraph vpc {`,
+		`subgraph "cluster_vni_123" {`,
+		`"da64c3f3-095d-91e5-df01-5aabcfc52468" -> "0ebf50e1-1f79-11e8-8002-0cc47a6c7d1e" [label="uplink"];`,
+		`\nl2 em0"`,
+	} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot.String())
+		}
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package topology
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc/mgmt"
+	"github.com/pkg/errors"
+)
+
+// Action is the kind of change performed by a Step.
+type Action uint
+
+const (
+	ActionInvalid Action = iota
+	ActionDestroy
+	ActionCreate
+	ActionEthLinkConnect
+	ActionPortAdd
+	ActionUplinkSet
+	ActionVNISet
+	ActionVLANSet
+	ActionPortDisconnect
+	ActionPortConnect
+	ActionMuxListen
+	ActionMuxConnect
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionDestroy:
+		return "destroy"
+	case ActionCreate:
+		return "create"
+	case ActionEthLinkConnect:
+		return "ethlink-connect"
+	case ActionPortAdd:
+		return "port-add"
+	case ActionUplinkSet:
+		return "uplink-set"
+	case ActionVNISet:
+		return "vni-set"
+	case ActionVLANSet:
+		return "vlan-set"
+	case ActionPortDisconnect:
+		return "port-disconnect"
+	case ActionPortConnect:
+		return "port-connect"
+	case ActionMuxListen:
+		return "mux-listen"
+	case ActionMuxConnect:
+		return "mux-connect"
+	default:
+		return "invalid"
+	}
+}
+
+// Step is a single change to the VPC objects on a host.  ID is the object the
+// Step operates on.  Peer is the switch for ActionPortAdd and
+// ActionUplinkSet and the interface for ActionPortConnect,
+// ActionPortDisconnect, and ActionMuxConnect.  Addr is the L2 interface name for ActionEthLinkConnect
+// and the underlay address for ActionMuxListen.
+type Step struct {
+	Action Action
+	ID     vpc.ID
+	Peer   vpc.ID
+	VNI    vpc.VNI
+	VLAN   vpc.VTag
+	MAC    net.HardwareAddr
+	Addr   string
+}
+
+// Detail returns a human-readable description of the Step's arguments.
+func (s Step) Detail() string {
+	switch s.Action {
+	case ActionCreate, ActionDestroy:
+		return s.ID.ObjType.String()
+	case ActionEthLinkConnect:
+		return "l2-name=" + s.Addr
+	case ActionPortAdd, ActionUplinkSet:
+		return "switch=" + s.Peer.String()
+	case ActionVNISet:
+		return "vni=" + strconv.FormatInt(int64(s.VNI), 10)
+	case ActionVLANSet:
+		return "vlan=" + strconv.FormatInt(int64(s.VLAN), 10)
+	case ActionPortConnect, ActionPortDisconnect, ActionMuxConnect:
+		return "interface=" + s.Peer.String()
+	case ActionMuxListen:
+		return "listen=" + s.Addr
+	default:
+		return ""
+	}
+}
+
+func (s Step) String() string {
+	return fmt.Sprintf("%s %s %s", s.Action, s.ID, s.Detail())
+}
+
+// Plan is the ordered list of Steps required to converge a host on a
+// Document.  New objects are created in dependency order: interfaces, switches
+// and muxes, then switch ports and finally the connections between them.
+// Objects that are pruned are destroyed last, once every Step that can be
+// undone has been applied.
+type Plan struct {
+	Steps []Step
+}
+
+// Empty returns true when the host already matches the Document.
+func (p *Plan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+// Append adds steps to the Plan ahead of its destroy Steps so that they are
+// undone if a later Step fails.
+func (p *Plan) Append(steps ...Step) {
+	i := len(p.Steps)
+	for i > 0 && p.Steps[i-1].Action == ActionDestroy {
+		i--
+	}
+
+	tail := append([]Step(nil), p.Steps[i:]...)
+	p.Steps = append(append(p.Steps[:i], steps...), tail...)
+}
+
+// State is the set of VPC objects that exist on a host.
+type State map[vpc.ID]bool
+
+// managedTypes are the VPC object types managed by a Document, in the order
+// they are destroyed.
+var managedTypes = []vpc.ObjType{
+	vpc.ObjTypeSwitchPort,
+	vpc.ObjTypeMux,
+	vpc.ObjTypeSwitch,
+	vpc.ObjTypeNICVM,
+	vpc.ObjTypeHostif,
+	vpc.ObjTypeLinkEth,
+}
+
+// ReadState returns the VPC objects of the types managed by a Document that
+// exist on this host.
+func ReadState() (State, error) {
+	mgr, err := mgmt.New(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open VPC Management handle")
+	}
+	defer mgr.Close()
+
+	state := make(State)
+	for _, objType := range managedTypes {
+		objHeaders, err := mgr.GetAllIDs(objType)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get %s VPC objects", objType)
+		}
+
+		for _, hdr := range objHeaders {
+			state[hdr.ID()] = true
+		}
+	}
+
+	return state, nil
+}
+
+// NewPlan computes the Plan required to converge state on doc.  Objects that
+// already exist are left untouched: their configuration and connections are
+// only applied when they are created (see Drift).  Objects in state that are
+// not declared in doc are left alone.
+func NewPlan(doc *Document, state State) (*Plan, error) {
+	return NewPrunePlan(doc, state, nil)
+}
+
+// NewPrunePlan is like NewPlan but also destroys the undeclared objects for
+// which prune returns true.  Destroyed objects can not be restored, so they are
+// destroyed after every other Step of the Plan.
+func NewPrunePlan(doc *Document, state State, prune func(id vpc.ID) bool) (*Plan, error) {
+	if err := doc.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid topology")
+	}
+
+	declared := make(map[vpc.ID]bool)
+	for _, id := range doc.IDs() {
+		declared[id] = true
+	}
+
+	plan := &Plan{}
+
+	// 1) Create interfaces, switches, and muxes
+	for _, el := range doc.EthLinks {
+		id := mustParseID(el.ID)
+		if state[id] {
+			continue
+		}
+
+		plan.Steps = append(plan.Steps, Step{Action: ActionCreate, ID: id})
+		if el.L2Name != "" {
+			plan.Steps = append(plan.Steps, Step{Action: ActionEthLinkConnect, ID: id, Addr: el.L2Name})
+		}
+	}
+
+	for _, hif := range doc.Hostifs {
+		if id := mustParseID(hif.ID); !state[id] {
+			plan.Steps = append(plan.Steps, Step{Action: ActionCreate, ID: id})
+		}
+	}
+
+	for _, vmn := range doc.VMNICs {
+		id := mustParseID(vmn.ID)
+		if state[id] {
+			continue
+		}
+
+		var mac net.HardwareAddr
+		if vmn.MAC != "" {
+			mac, _ = net.ParseMAC(vmn.MAC)
+		}
+		plan.Steps = append(plan.Steps, Step{Action: ActionCreate, ID: id, MAC: mac})
+	}
+
+	for _, sw := range doc.Switches {
+		id := mustParseID(sw.ID)
+		if !state[id] {
+			plan.Steps = append(plan.Steps, Step{Action: ActionCreate, ID: id, VNI: vpc.VNI(sw.VNI)})
+		}
+	}
+
+	var muxConnects []Step
+	for _, m := range doc.Muxes {
+		id := mustParseID(m.ID)
+		if state[id] {
+			continue
+		}
+
+		plan.Steps = append(plan.Steps, Step{Action: ActionCreate, ID: id})
+		if m.Listen != "" {
+			plan.Steps = append(plan.Steps, Step{Action: ActionMuxListen, ID: id, Addr: m.Listen})
+		}
+		if m.Connect != "" {
+			muxConnects = append(muxConnects, Step{Action: ActionMuxConnect, ID: id, Peer: mustParseID(m.Connect)})
+		}
+	}
+
+	// 2) Add ports to switches
+	var portConnects []Step
+	for _, sw := range doc.Switches {
+		switchID := mustParseID(sw.ID)
+		for _, port := range sw.Ports {
+			portID := mustParseID(port.ID)
+			if state[portID] {
+				continue
+			}
+
+			mac := net.HardwareAddr(portID.Node[:])
+			if port.MAC != "" {
+				mac, _ = net.ParseMAC(port.MAC)
+			}
+
+			plan.Steps = append(plan.Steps, Step{Action: ActionPortAdd, ID: portID, Peer: switchID, MAC: mac})
+
+			switch {
+			case port.Uplink:
+				plan.Steps = append(plan.Steps, Step{Action: ActionUplinkSet, ID: portID, Peer: switchID, MAC: mac})
+			case port.VNI != nil:
+				plan.Steps = append(plan.Steps, Step{Action: ActionVNISet, ID: portID, VNI: vpc.VNI(*port.VNI)})
+			default:
+				plan.Steps = append(plan.Steps, Step{Action: ActionVNISet, ID: portID, VNI: vpc.VNI(sw.VNI)})
+			}
+
+			if port.VLAN != nil && !port.Uplink {
+				plan.Steps = append(plan.Steps, Step{Action: ActionVLANSet, ID: portID, VLAN: vpc.VTag(*port.VLAN)})
+			}
+
+			if port.Connect != "" {
+				portConnects = append(portConnects, Step{Action: ActionPortConnect, ID: portID, Peer: mustParseID(port.Connect)})
+			}
+		}
+	}
+
+	// 3) Connect ports and muxes once every endpoint exists
+	plan.Steps = append(plan.Steps, portConnects...)
+	plan.Steps = append(plan.Steps, muxConnects...)
+
+	// 4) Destroy pruned objects, dependents first
+	if prune == nil {
+		return plan, nil
+	}
+
+	for _, objType := range managedTypes {
+		var ids []vpc.ID
+		for id := range state {
+			if id.ObjType == objType && !declared[id] && prune(id) {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i].Bytes(), ids[j].Bytes()) < 0 })
+
+		for _, id := range ids {
+			plan.Steps = append(plan.Steps, Step{Action: ActionDestroy, ID: id})
+		}
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2018 Joyent, Inc.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE AUTHOR AND CONTRIBUTORS ``AS IS'' AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED.  IN NO EVENT SHALL THE AUTHOR OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+// OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+// HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+// OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// SUCH DAMAGE.
+
+package topology
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/freebsd/freebsd/libexec/go/src/go.freebsd.org/sys/vpc"
+)
+
+func TestNewPlan_Order(t *testing.T) {
+	var (
+		elID        = vpc.GenID(vpc.ObjTypeLinkEth)
+		hifID       = vpc.GenID(vpc.ObjTypeHostif)
+		vmnID       = vpc.GenID(vpc.ObjTypeNICVM)
+		swID        = vpc.GenID(vpc.ObjTypeSwitch)
+		portID      = vpc.GenID(vpc.ObjTypeSwitchPort)
+		uplinkID    = vpc.GenID(vpc.ObjTypeSwitchPort)
+		muxID       = vpc.GenID(vpc.ObjTypeMux)
+		stalePortID = vpc.GenID(vpc.ObjTypeSwitchPort)
+		staleHifID  = vpc.GenID(vpc.ObjTypeHostif)
+		vlan        = uint16(42)
+	)
+
+	doc := &Document{
+		Switches: []Switch{{
+			ID:  swID.String(),
+			VNI: 123,
+			Ports: []Port{
+				{ID: portID.String(), VLAN: &vlan, Connect: hifID.String()},
+				{ID: uplinkID.String(), Uplink: true, Connect: muxID.String()},
+			},
+		}},
+		Hostifs:  []Hostif{{ID: hifID.String()}},
+		VMNICs:   []VMNIC{{ID: vmnID.String(), MAC: "02:00:00:00:00:01"}},
+		EthLinks: []EthLink{{ID: elID.String(), L2Name: "em0"}},
+		Muxes:    []Mux{{ID: muxID.String(), Listen: "192.0.2.1:4789", Connect: elID.String()}},
+	}
+
+	plan, err := NewPrunePlan(doc, State{stalePortID: true, staleHifID: true}, func(vpc.ID) bool { return true })
+	if err != nil {
+		t.Fatalf("unable to plan topology: %v", err)
+	}
+
+	vmnMAC, _ := net.ParseMAC("02:00:00:00:00:01")
+	wantSteps := []Step{
+		{Action: ActionCreate, ID: elID},
+		{Action: ActionEthLinkConnect, ID: elID, Addr: "em0"},
+		{Action: ActionCreate, ID: hifID},
+		{Action: ActionCreate, ID: vmnID, MAC: vmnMAC},
+		{Action: ActionCreate, ID: swID, VNI: 123},
+		{Action: ActionCreate, ID: muxID},
+		{Action: ActionMuxListen, ID: muxID, Addr: "192.0.2.1:4789"},
+		{Action: ActionPortAdd, ID: portID, Peer: swID, MAC: net.HardwareAddr(portID.Node[:])},
+		{Action: ActionVNISet, ID: portID, VNI: 123},
+		{Action: ActionVLANSet, ID: portID, VLAN: 42},
+		{Action: ActionPortAdd, ID: uplinkID, Peer: swID, MAC: net.HardwareAddr(uplinkID.Node[:])},
+		{Action: ActionUplinkSet, ID: uplinkID, Peer: swID, MAC: net.HardwareAddr(uplinkID.Node[:])},
+		{Action: ActionPortConnect, ID: portID, Peer: hifID},
+		{Action: ActionPortConnect, ID: uplinkID, Peer: muxID},
+		{Action: ActionMuxConnect, ID: muxID, Peer: elID},
+		{Action: ActionDestroy, ID: stalePortID},
+		{Action: ActionDestroy, ID: staleHifID},
+	}
+	if !reflect.DeepEqual(plan.Steps, wantSteps) {
+		t.Fatalf("plan mismatch:\ngot:  %v\nwant: %v", plan.Steps, wantSteps)
+	}
+
+	// Existing objects are left untouched and only pruned objects are
+	// destroyed
+	state := State{stalePortID: true, staleHifID: true}
+	for _, id := range doc.IDs() {
+		state[id] = true
+	}
+
+	plan, err = NewPlan(doc, state)
+	if err != nil {
+		t.Fatalf("unable to plan topology: %v", err)
+	}
+	if !plan.Empty() {
+		t.Fatalf("expected undeclared objects to be left alone, got %v", plan.Steps)
+	}
+
+	plan, err = NewPrunePlan(doc, state, func(id vpc.ID) bool { return id == stalePortID })
+	if err != nil {
+		t.Fatalf("unable to plan topology: %v", err)
+	}
+
+	wantSteps = []Step{{Action: ActionDestroy, ID: stalePortID}}
+	if !reflect.DeepEqual(plan.Steps, wantSteps) {
+		t.Fatalf("pruned plan mismatch:\ngot:  %v\nwant: %v", plan.Steps, wantSteps)
+	}
+
+	// Drift is applied before the pruned objects are destroyed
+	drift := Step{Action: ActionVNISet, ID: portID, VNI: 456}
+	plan.Append(drift)
+
+	wantSteps = []Step{drift, {Action: ActionDestroy, ID: stalePortID}}
+	if !reflect.DeepEqual(plan.Steps, wantSteps) {
+		t.Fatalf("appended plan mismatch:\ngot:  %v\nwant: %v", plan.Steps, wantSteps)
+	}
+}
+
+func TestDocument_Validate(t *testing.T) {
+	swID := vpc.GenID(vpc.ObjTypeSwitch).String()
+	portID := vpc.GenID(vpc.ObjTypeSwitchPort).String()
+	hifID := vpc.GenID(vpc.ObjTypeHostif).String()
+	bigVLAN := uint16(vpc.VTagMax) + 1
+
+	tests := []struct {
+		name    string
+		doc     Document
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			doc: Document{
+				Switches: []Switch{{ID: swID, VNI: 123, Ports: []Port{{ID: portID, Connect: hifID}}}},
+				Hostifs:  []Hostif{{ID: hifID}},
+			},
+		},
+		{
+			name:    "missing ID",
+			doc:     Document{Hostifs: []Hostif{{}}},
+			wantErr: true,
+		},
+		{
+			name:    "wrong object type",
+			doc:     Document{Switches: []Switch{{ID: hifID}}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate",
+			doc:     Document{Hostifs: []Hostif{{ID: hifID}, {ID: hifID}}},
+			wantErr: true,
+		},
+		{
+			name:    "VNI exceeds max",
+			doc:     Document{Switches: []Switch{{ID: swID, VNI: uint32(vpc.VNIMax) + 1}}},
+			wantErr: true,
+		},
+		{
+			name:    "VLAN exceeds max",
+			doc:     Document{Switches: []Switch{{ID: swID, Ports: []Port{{ID: portID, VLAN: &bigVLAN}}}}},
+			wantErr: true,
+		},
+		{
+			name:    "bad MAC",
+			doc:     Document{Switches: []Switch{{ID: swID, Ports: []Port{{ID: portID, MAC: "bogus"}}}}},
+			wantErr: true,
+		},
+		{
+			name: "multiple uplinks",
+			doc: Document{Switches: []Switch{{ID: swID, Ports: []Port{
+				{ID: portID, Uplink: true},
+				{ID: vpc.GenID(vpc.ObjTypeSwitchPort).String(), Uplink: true},
+			}}}},
+			wantErr: true,
+		},
+		{
+			name:    "undeclared peer",
+			doc:     Document{Switches: []Switch{{ID: swID, Ports: []Port{{ID: portID, Connect: hifID}}}}},
+			wantErr: true,
+		},
+		{
+			name: "peer is not an interface",
+			doc: Document{Switches: []Switch{{ID: swID, Ports: []Port{
+				{ID: portID, Connect: swID},
+			}}}},
+			wantErr: true,
+		},
+		{
+			name: "mux connected to a port",
+			doc: Document{
+				Switches: []Switch{{ID: swID, Ports: []Port{{ID: portID}}}},
+				Muxes:    []Mux{{ID: vpc.GenID(vpc.ObjTypeMux).String(), Connect: portID}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "peer connected twice",
+			doc: Document{
+				Switches: []Switch{{ID: swID, Ports: []Port{
+					{ID: portID, Connect: hifID},
+					{ID: vpc.GenID(vpc.ObjTypeSwitchPort).String(), Connect: hifID},
+				}}},
+				Hostifs: []Hostif{{ID: hifID}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			err := test.doc.Validate()
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("expected error %t, got %v", test.wantErr, err)
+			}
+		})
+	}
+}